@@ -4,12 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/haswell/bcscan/internal/cache"
+	"github.com/haswell/bcscan/internal/models"
 	"github.com/haswell/bcscan/internal/repository"
 	"github.com/haswell/bcscan/internal/ruleengine"
 	_ "github.com/lib/pq"
@@ -21,6 +25,7 @@ type Config struct {
 	Port        string
 	RedisAddr   string
 	RulesPath   string
+	RulesWatch  bool // 监视规则目录，文件变化后自动重新导入、加载并通知 RDS
 }
 
 func loadConfig() *Config {
@@ -29,6 +34,7 @@ func loadConfig() *Config {
 		Port:        getEnv("PORT", "8080"),
 		RedisAddr:   getEnv("REDIS_ADDR", "localhost:6379"),
 		RulesPath:   getEnv("RULES_PATH", "./rules/builtin"),
+		RulesWatch:  getEnv("RULES_WATCH", "false") == "true",
 	}
 }
 
@@ -58,7 +64,28 @@ func main() {
 	// Initialize Redis, Repository and RuleManager
 	redis := cache.NewRedisClient(cfg.RedisAddr)
 	riskRepo := repository.NewRiskEventRepository(db, redis, logger)
-	ruleManager := ruleengine.NewRuleManager(cfg.RulesPath, redis, logger)
+	ruleRepo := repository.NewRuleRepository(db, logger)
+	ruleRevisionRepo := repository.NewRuleRevisionRepository(db, logger)
+	ruleManager := ruleengine.NewRuleManager(cfg.RulesPath, ruleRepo, ruleRevisionRepo,
+		repository.NewMonitoredContractRepository(db, logger), redis, logger)
+	ruleHealth := ruleengine.NewRuleHealth(redis, logger, 0)
+	shadowRepo := repository.NewShadowResultRepository(db, logger)
+	addressListRepo := repository.NewAddressListRepository(db, logger)
+	addressLists := ruleengine.NewAddressLists(addressListRepo, redis, logger)
+
+	// 规则目录导入数据库并加载规则，失败不影响启动
+	ctx := context.Background()
+	if err := ruleManager.SeedRules(ctx); err != nil {
+		logger.Warn("Failed to seed rules", zap.Error(err))
+	}
+	if err := ruleManager.LoadFromStore(ctx); err != nil {
+		logger.Warn("Failed to load rules", zap.Error(err))
+	}
+	if cfg.RulesWatch {
+		if err := ruleManager.WatchFiles(ctx); err != nil {
+			logger.Warn("Failed to watch rule files", zap.Error(err))
+		}
+	}
 
 	logger.Info("API Gateway starting", zap.String("port", cfg.Port))
 
@@ -72,8 +99,25 @@ func main() {
 	api.HandleFunc("/stats", getStats(riskRepo)).Methods("GET")
 
 	// Rule management routes
-	api.HandleFunc("/rules", getRules(ruleManager)).Methods("GET")
+	api.HandleFunc("/rules", getRules(ruleManager, ruleHealth)).Methods("GET")
 	api.HandleFunc("/rules/reload", reloadRules(ruleManager)).Methods("POST")
+	api.HandleFunc("/rules/version", getRulesVersion(ruleManager)).Methods("GET")
+	api.HandleFunc("/rules/{name}", getRule(ruleRepo)).Methods("GET")
+	api.HandleFunc("/rules/{name}", createRule(ruleManager)).Methods("POST")
+	api.HandleFunc("/rules/{name}", updateRule(ruleManager)).Methods("PUT")
+	api.HandleFunc("/rules/{name}", patchRule(ruleManager)).Methods("PATCH")
+	api.HandleFunc("/rules/{name}", deleteRule(ruleManager)).Methods("DELETE")
+	api.HandleFunc("/rules/{name}/enable", enableRule(ruleHealth)).Methods("POST")
+	api.HandleFunc("/rules/{name}/revisions", getRuleRevisions(ruleRevisionRepo)).Methods("GET")
+	api.HandleFunc("/rules/{name}/revisions/{revision}", getRuleRevision(ruleRevisionRepo)).Methods("GET")
+	api.HandleFunc("/rules/{name}/diff", diffRuleRevisions(ruleRevisionRepo)).Methods("GET")
+	api.HandleFunc("/rules/{name}/rollback", rollbackRule(ruleManager)).Methods("POST")
+	api.HandleFunc("/rules/{name}/shadow", compareShadowRule(ruleManager, shadowRepo)).Methods("GET")
+
+	// Global address list routes
+	api.HandleFunc("/address-lists", getAddressLists(addressListRepo)).Methods("GET")
+	api.HandleFunc("/address-lists", createAddressListEntry(addressListRepo, addressLists)).Methods("POST")
+	api.HandleFunc("/address-lists/{id}", deleteAddressListEntry(addressListRepo, addressLists)).Methods("DELETE")
 
 	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
 		logger.Fatal("Server failed", zap.Error(err))
@@ -83,7 +127,7 @@ func main() {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -149,18 +193,53 @@ func getStats(repo *repository.RiskEventRepository) http.HandlerFunc {
 	}
 }
 
-func getRules(rm *ruleengine.RuleManager) http.HandlerFunc {
+// ruleWithStatus 规则及其运行状态
+type ruleWithStatus struct {
+	*ruleengine.Rule
+	Status ruleengine.RuleStatus `json:"status"`
+}
+
+func getRules(rm *ruleengine.RuleManager, health *ruleengine.RuleHealth) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := health.Load(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		rules := rm.GetRules()
+		result := make([]ruleWithStatus, 0, len(rules))
+		for _, rule := range rules {
+			result = append(result, ruleWithStatus{
+				Rule:   rule,
+				Status: health.Status(rule.Metadata.Name),
+			})
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(rules)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func enableRule(health *ruleengine.RuleHealth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		if err := health.Enable(r.Context(), name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"status":  health.Status(name),
+		})
 	}
 }
 
 func reloadRules(rm *ruleengine.RuleManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.Background()
-		if err := rm.LoadRules(ctx); err != nil {
+		if err := rm.LoadFromStore(ctx); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -170,10 +249,410 @@ func reloadRules(rm *ruleengine.RuleManager) http.HandlerFunc {
 			return
 		}
 
+		snapshot := rm.Snapshot()
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
-			"count":   len(rm.GetRules()),
+			"count":   len(snapshot.Rules),
+			"version": snapshot.Version,
+		})
+	}
+}
+
+// getRulesVersion 返回当前规则版本与各实例采用的版本，synced 表示所有实例已采用当前版本
+func getRulesVersion(rm *ruleengine.RuleManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instances, err := rm.Instances(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		snapshot := rm.Snapshot()
+		synced := true
+		for _, instance := range instances {
+			if instance.Version != snapshot.Version || instance.Error != "" {
+				synced = false
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"version":   snapshot.Version,
+			"loaded_at": snapshot.LoadedAt,
+			"synced":    synced,
+			"instances": instances,
+		})
+	}
+}
+
+func getRuleRevisions(repo *repository.RuleRevisionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := repo.List(r.Context(), mux.Vars(r)["name"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(revisions)
+	}
+}
+
+func getRuleRevision(repo *repository.RuleRevisionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		revision, err := strconv.Atoi(vars["revision"])
+		if err != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+
+		rev, err := repo.Get(r.Context(), vars["name"], revision)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Revision not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rev)
+	}
+}
+
+// diffRuleRevisions 比较规则的两个修订：GET /api/rules/{name}/diff?from=1&to=3，to 省略时为最新修订
+func diffRuleRevisions(repo *repository.RuleRevisionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		from, err := strconv.Atoi(r.URL.Query().Get("from"))
+		if err != nil {
+			http.Error(w, "Invalid from revision", http.StatusBadRequest)
+			return
+		}
+		to := 0
+		if t := r.URL.Query().Get("to"); t != "" {
+			if to, err = strconv.Atoi(t); err != nil {
+				http.Error(w, "Invalid to revision", http.StatusBadRequest)
+				return
+			}
+		} else {
+			revisions, err := repo.List(r.Context(), name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(revisions) > 0 {
+				to = revisions[0].Revision
+			}
+		}
+
+		var pair [2]*models.RuleRevision
+		for i, revision := range []int{from, to} {
+			pair[i], err = repo.Get(r.Context(), name, revision)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, fmt.Sprintf("Revision %d not found", revision), http.StatusNotFound)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"rule": name,
+			"from": from,
+			"to":   to,
+			"diff": ruleengine.UnifiedDiff(
+				fmt.Sprintf("%s@%d", name, from), fmt.Sprintf("%s@%d", name, to),
+				pair[0].Content, pair[1].Content),
 		})
 	}
 }
+
+// compareShadowRule 比较影子规则与线上规则的命中：GET /api/rules/{name}/shadow?from=...&to=...&live=...
+// from、to 为 RFC 3339 时间，默认为最近 24 小时；live 默认为影子规则的 shadow_of
+func compareShadowRule(rm *ruleengine.RuleManager, repo *repository.ShadowResultRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		query := r.URL.Query()
+
+		to := time.Now()
+		from := to.Add(-24 * time.Hour)
+		for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+			if value := query.Get(key); value != "" {
+				parsed, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Invalid %s time", key), http.StatusBadRequest)
+					return
+				}
+				*t = parsed
+			}
+		}
+		if !from.Before(to) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+
+		live := query.Get("live")
+		if live == "" {
+			for _, rule := range rm.GetRules() {
+				if rule.Metadata.Name == name {
+					live = rule.Config.ShadowOf
+					break
+				}
+			}
+		}
+
+		cmp, err := repo.Compare(r.Context(), name, live, from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cmp)
+	}
+}
+
+// ruleRequest 创建、替换规则的请求体，content 为规则 YAML
+type ruleRequest struct {
+	Content string `json:"content"`
+	Author  string `json:"author"`
+}
+
+func getRule(repo *repository.RuleRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := repo.Get(r.Context(), mux.Vars(r)["name"])
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	}
+}
+
+// createRule 创建规则：POST /api/rules/{name} {"content": "<规则 YAML>", "author": "alice"}
+func createRule(rm *ruleengine.RuleManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ruleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Content == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		rule, err := rm.CreateRule(ctx, mux.Vars(r)["name"], []byte(req.Content), req.Author)
+		writeRuleChange(ctx, w, rm, rule, err, http.StatusCreated)
+	}
+}
+
+// updateRule 替换规则内容：PUT /api/rules/{name}，请求体同 createRule
+func updateRule(rm *ruleengine.RuleManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ruleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Content == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		rule, err := rm.UpdateRule(ctx, mux.Vars(r)["name"], []byte(req.Content), req.Author)
+		writeRuleChange(ctx, w, rm, rule, err, http.StatusOK)
+	}
+}
+
+// patchRule 修改规则的部分字段：PATCH /api/rules/{name} {"patch": {"metadata": {"enabled": false}}, "author": "alice"}
+// patch 按 JSON Merge Patch 合并到规则 YAML，null 删除字段
+func patchRule(rm *ruleengine.RuleManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Patch  map[string]interface{} `json:"patch"`
+			Author string                 `json:"author"`
+		}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil || len(req.Patch) == 0 {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		rule, err := rm.PatchRule(ctx, mux.Vars(r)["name"], req.Patch, req.Author)
+		writeRuleChange(ctx, w, rm, rule, err, http.StatusOK)
+	}
+}
+
+// deleteRule 删除规则：DELETE /api/rules/{name}?author=alice
+func deleteRule(rm *ruleengine.RuleManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.Background()
+		err := rm.DeleteRule(ctx, mux.Vars(r)["name"], r.URL.Query().Get("author"))
+		writeRuleChange(ctx, w, rm, nil, err, http.StatusOK)
+	}
+}
+
+// rollbackRule 回滚规则：POST /api/rules/{name}/rollback {"revision": 3, "author": "alice"}
+func rollbackRule(rm *ruleengine.RuleManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Revision int    `json:"revision"`
+			Author   string `json:"author"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Revision <= 0 {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		rule, err := rm.Rollback(ctx, mux.Vars(r)["name"], req.Revision, req.Author)
+		writeRuleChange(ctx, w, rm, rule, err, http.StatusOK)
+	}
+}
+
+// writeRuleChange 规则写入成功后通知 RDS 重新加载并返回写入的规则（删除时 rule 为空）
+// 写入成功但本实例重新加载失败时仍返回成功，失败原因放在 reload_error 中
+func writeRuleChange(ctx context.Context, w http.ResponseWriter, rm *ruleengine.RuleManager, rule *models.DetectionRule, err error, status int) {
+	var reloadErr *ruleengine.ReloadError
+	if err != nil && !errors.As(err, &reloadErr) {
+		writeRuleError(w, err)
+		return
+	}
+	// 本实例未能加载修改时通知不带版本，其他实例从数据库加载
+	publish := rm.PublishUpdate
+	if reloadErr != nil {
+		publish = rm.PublishReload
+	}
+	if err := publish(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"success": true,
+		"version": rm.Version(),
+	}
+	if rule != nil {
+		resp["rule"] = rule
+	}
+	if reloadErr != nil {
+		resp["reload_error"] = reloadErr.Err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeRuleError 将规则操作的错误映射为状态码，校验失败时以 JSON 返回诊断信息
+func writeRuleError(w http.ResponseWriter, err error) {
+	var invalid *ruleengine.ValidationError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Rule or revision not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrRuleExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &invalid):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       fmt.Sprintf("rule %q is invalid", invalid.Rule),
+			"diagnostics": invalid.Diagnostics,
+		})
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func getAddressLists(repo *repository.AddressListRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listType := r.URL.Query().Get("type")
+		if listType != "" && listType != ruleengine.ListTypeAllow && listType != ruleengine.ListTypeDeny {
+			http.Error(w, "type must be allow or deny", http.StatusBadRequest)
+			return
+		}
+
+		entries, err := repo.List(r.Context(), listType)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+func createAddressListEntry(repo *repository.AddressListRepository, lists *ruleengine.AddressLists) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var entry models.AddressListEntry
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if entry.ListType != ruleengine.ListTypeAllow && entry.ListType != ruleengine.ListTypeDeny {
+			http.Error(w, "list_type must be allow or deny", http.StatusBadRequest)
+			return
+		}
+		address, err := ruleengine.NormalizeAddress(entry.Address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		entry.Address = address
+
+		if err := repo.Create(r.Context(), &entry); err != nil {
+			writeAddressListError(w, err)
+			return
+		}
+		if err := lists.PublishUpdate(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(entry)
+	}
+}
+
+func deleteAddressListEntry(repo *repository.AddressListRepository, lists *ruleengine.AddressLists) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repo.Delete(r.Context(), id); err != nil {
+			writeAddressListError(w, err)
+			return
+		}
+		if err := lists.PublishUpdate(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	}
+}
+
+// writeAddressListError 将名单操作的错误映射为状态码
+func writeAddressListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Entry not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrAddressListed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
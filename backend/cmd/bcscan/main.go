@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// 退出码
+const (
+	exitOK       = 0 // 没有问题
+	exitProblems = 1 // 发现问题（规则检查或测试用例失败）
+	exitUsage    = 2 // 参数错误或文件无法读取
+)
+
+const usage = `Usage: bcscan <command> [arguments]
+
+Commands:
+  rules lint [-dir <rules dir>] [-format text|json] [-strict] [path ...]
+        严格解析、校验并编译规则文件
+  rules test [-dir <rules dir>] [-run <regexp>] [-format text|json] [-v] [dir ...]
+        执行规则测试用例（规则的 tests 与 *_test.yaml）
+  rules schema
+        输出规则文件的 JSON Schema
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) < 2 || args[0] != "rules" {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	switch args[1] {
+	case "lint":
+		return lintRules(args[2:])
+	case "test":
+		return testRules(args[2:])
+	case "schema":
+		return printSchema()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: rules %s\n\n%s", args[1], usage)
+		return exitUsage
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/haswell/bcscan/internal/ruleengine"
+	"go.uber.org/zap"
+)
+
+// lintRules 检查规则：参数为目录时检查目录下的所有规则（共享列表取自该目录），
+// 为文件时以 -dir 作为规则目录；没有参数时检查 -dir
+// 有错误（-strict 时包括警告）返回 exitProblems
+func lintRules(args []string) int {
+	fs := flag.NewFlagSet("rules lint", flag.ContinueOnError)
+	dir := fs.String("dir", getEnv("RULES_PATH", "./rules/builtin"), "rules directory, shared lists are loaded from <dir>/lists")
+	format := fs.String("format", "text", "output format: text or json")
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected text or json\n", *format)
+		return exitUsage
+	}
+
+	var dirs, files []string
+	for _, path := range fs.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, path)
+		}
+	}
+	if len(dirs) == 0 && len(files) == 0 {
+		dirs = append(dirs, *dir)
+	}
+
+	report := &ruleengine.LintReport{Diagnostics: []ruleengine.Diagnostic{}}
+	merge := func(loader *ruleengine.RuleLoader, files ...string) error {
+		r, err := loader.Lint(files...)
+		if err != nil {
+			return err
+		}
+		report.Files += r.Files
+		report.Rules += r.Rules
+		report.Errors += r.Errors
+		report.Warnings += r.Warnings
+		report.Diagnostics = append(report.Diagnostics, r.Diagnostics...)
+		return nil
+	}
+	for _, d := range dirs {
+		if err := merge(ruleengine.NewRuleLoader(d, zap.NewNop())); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	if len(files) > 0 {
+		if err := merge(ruleengine.NewRuleLoader(*dir, zap.NewNop()), files...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	} else {
+		for _, d := range report.Diagnostics {
+			fmt.Println(d.String())
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s), %d rule(s): %d error(s), %d warning(s)\n",
+			report.Files, report.Rules, report.Errors, report.Warnings)
+	}
+
+	if report.Errors > 0 || (*strict && report.Warnings > 0) {
+		return exitProblems
+	}
+	return exitOK
+}
+
+func printSchema() int {
+	schema, err := ruleengine.RuleJSONSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	fmt.Println(string(schema))
+	return exitOK
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles 在临时目录中写入规则文件
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLintRulesExitCode(t *testing.T) {
+	clean := writeFiles(t, map[string]string{"large.yaml": `metadata:
+  name: "large"
+config:
+  hooks: [contract_function_call]
+triggers:
+  conditions:
+    - expression: "value > 1 ether"
+`})
+	warning := writeFiles(t, map[string]string{"unhooked.yaml": `metadata:
+  name: "unhooked"
+`})
+	broken := writeFiles(t, map[string]string{"typo.yaml": `metadata:
+  name: "typo"
+config:
+  severty: "high"
+`})
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"clean directory", []string{"rules", "lint", clean}, exitOK},
+		{"single file", []string{"rules", "lint", "-dir", clean, filepath.Join(clean, "large.yaml")}, exitOK},
+		{"warnings only", []string{"rules", "lint", warning}, exitOK},
+		{"strict warnings", []string{"rules", "lint", "-strict", warning}, exitProblems},
+		{"errors", []string{"rules", "lint", "-format", "json", broken}, exitProblems},
+		{"missing path", []string{"rules", "lint", filepath.Join(clean, "missing.yaml")}, exitUsage},
+		{"unknown format", []string{"rules", "lint", "-format", "xml", clean}, exitUsage},
+		{"unknown command", []string{"rules", "fmt"}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.args); got != tt.want {
+				t.Errorf("run(%v) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
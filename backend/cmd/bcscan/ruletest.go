@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/haswell/bcscan/internal/ruleengine/ruletest"
+)
+
+// testRules 执行规则测试用例：参数为规则目录，没有参数时执行 -dir
+// 有用例失败或规则、测试文件有错误时返回 exitProblems
+func testRules(args []string) int {
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	dir := fs.String("dir", getEnv("RULES_PATH", "./rules/builtin"), "rules directory")
+	run := fs.String("run", "", "run only cases whose <rule>/<case> name matches the regexp")
+	format := fs.String("format", "text", "output format: text or json")
+	verbose := fs.Bool("v", false, "also list passing cases")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected text or json\n", *format)
+		return exitUsage
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{*dir}
+	}
+
+	report := &ruletest.Report{}
+	for _, d := range dirs {
+		r, err := ruletest.RunDir(d, filter)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		report.Passed += r.Passed
+		report.Failed += r.Failed
+		report.Diagnostics = append(report.Diagnostics, r.Diagnostics...)
+		report.Results = append(report.Results, r.Results...)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	} else {
+		for _, d := range report.Diagnostics {
+			fmt.Println(d.String())
+		}
+		for _, result := range report.Results {
+			switch {
+			case result.Passed:
+				if *verbose {
+					fmt.Printf("ok   %s\n", result.Name())
+				}
+			case result.Error != "":
+				fmt.Printf("FAIL %s (%s)\n    %s\n", result.Name(), result.File, result.Error)
+			default:
+				fmt.Printf("FAIL %s (%s)\n", result.Name(), result.File)
+				for _, failure := range result.Failures {
+					fmt.Printf("    %s\n", strings.ReplaceAll(failure, "\n", "\n    "))
+				}
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d passed, %d failed\n", report.Passed, report.Failed)
+	}
+
+	if !report.OK() {
+		return exitProblems
+	}
+	return exitOK
+}
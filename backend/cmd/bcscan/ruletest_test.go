@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestTestRulesExitCode(t *testing.T) {
+	failing := writeFiles(t, map[string]string{"large.yaml": `metadata:
+  name: "large"
+config:
+  hooks: [contract_function_call]
+triggers:
+  conditions:
+    - expression: "value > 1 ether"
+tests:
+  - name: "small"
+    transaction: {tx_hash: "0x1", value: "1"}
+    expect: {match: true}
+  - name: "large"
+    transaction: {tx_hash: "0x2", value: "2000000000000000000"}
+    expect: {match: true}
+`})
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"builtin rules", []string{"rules", "test", "../../rules/builtin"}, exitOK},
+		{"failing case", []string{"rules", "test", "-v", failing}, exitProblems},
+		{"failing case filtered out", []string{"rules", "test", "-run", "large/large", failing}, exitOK},
+		{"json", []string{"rules", "test", "-format", "json", failing}, exitProblems},
+		{"invalid run", []string{"rules", "test", "-run", "(", failing}, exitUsage},
+		{"unknown format", []string{"rules", "test", "-format", "xml", failing}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.args); got != tt.want {
+				t.Errorf("run(%v) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
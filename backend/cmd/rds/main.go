@@ -4,8 +4,11 @@ import (
 	"database/sql"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
+	"github.com/haswell/bcscan/internal/ruleengine"
+
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 )
@@ -49,6 +52,10 @@ type Config struct {
 	KafkaTopic  string
 	RulesPath   string
 	RedisAddr   string
+	RulesWatch  bool // 监视规则目录，文件变化后自动重新导入并加载
+
+	// MaxRuleFailures 规则出错超过该次数后自动隔离
+	MaxRuleFailures int
 }
 
 // loadConfig 加载配置
@@ -59,6 +66,9 @@ func loadConfig() *Config {
 		KafkaTopic:  getEnv("KAFKA_TOPIC", "blockchain.transactions"),
 		RulesPath:   getEnv("RULES_PATH", "./rules/builtin"),
 		RedisAddr:   getEnv("REDIS_ADDR", "localhost:6379"),
+		RulesWatch:  getEnv("RULES_WATCH", "false") == "true",
+
+		MaxRuleFailures: getEnvInt("RULE_MAX_FAILURES", ruleengine.DefaultMaxRuleFailures),
 	}
 }
 
@@ -70,6 +80,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt 获取整数环境变量，不存在或无法解析时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 // connectDatabase 连接数据库
 func connectDatabase(cfg *Config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.DatabaseURL)
@@ -7,7 +7,6 @@ import (
 
 	"github.com/haswell/bcscan/internal/cache"
 	"github.com/haswell/bcscan/internal/kafka"
-	"github.com/haswell/bcscan/internal/models"
 	"github.com/haswell/bcscan/internal/repository"
 	"github.com/haswell/bcscan/internal/ruleengine"
 	"github.com/haswell/bcscan/internal/ruleengine/hooks"
@@ -23,8 +22,13 @@ type RDSService struct {
 	kafkaConsumer *kafka.Consumer
 	hookManager   *hooks.Manager
 	ruleManager   *ruleengine.RuleManager
+	ruleHealth    *ruleengine.RuleHealth
+	addressLists  *ruleengine.AddressLists
+	throttler     *ruleengine.Throttler
+	windows       *ruleengine.WindowStore
 	scorer        *ruleengine.Scorer
 	executor      *ruleengine.Executor
+	shadows       *ruleengine.ShadowRecorder
 	running       bool
 }
 
@@ -32,15 +36,25 @@ type RDSService struct {
 func NewRDSService(db *sql.DB, cfg *Config, logger *zap.Logger) *RDSService {
 	redis := cache.NewRedisClient(cfg.RedisAddr)
 	repo := repository.NewRiskEventRepository(db, redis, logger)
+	ruleRepo := repository.NewRuleRepository(db, logger)
+	ruleRevisionRepo := repository.NewRuleRevisionRepository(db, logger)
+	ruleManager := ruleengine.NewRuleManager(cfg.RulesPath, ruleRepo, ruleRevisionRepo,
+		repository.NewMonitoredContractRepository(db, logger), redis, logger)
+	addressListRepo := repository.NewAddressListRepository(db, logger)
 	return &RDSService{
-		db:          db,
-		cfg:         cfg,
-		logger:      logger,
-		hookManager: hooks.NewManager(),
-		ruleManager: ruleengine.NewRuleManager(cfg.RulesPath, redis, logger),
-		scorer:      ruleengine.NewScorer(),
-		executor:    ruleengine.NewExecutor(repo),
-		running:     false,
+		db:           db,
+		cfg:          cfg,
+		logger:       logger,
+		hookManager:  hooks.NewManager(),
+		ruleManager:  ruleManager,
+		ruleHealth:   ruleengine.NewRuleHealth(redis, logger, cfg.MaxRuleFailures),
+		addressLists: ruleengine.NewAddressLists(addressListRepo, redis, logger),
+		throttler:    ruleengine.NewThrottler(redis, logger),
+		windows:      ruleengine.NewWindowStore(redis, logger),
+		scorer:       ruleengine.NewScorer(ruleManager.Programs()),
+		executor:     ruleengine.NewExecutor(repo),
+		shadows:      ruleengine.NewShadowRecorder(repository.NewShadowResultRepository(db, logger)),
+		running:      false,
 	}
 }
 
@@ -64,8 +78,15 @@ func (s *RDSService) Start() error {
 		s.logger,
 	)
 
-	// 4. 启动规则热加载
+	// 4. 启动规则热加载与规则状态同步
 	go s.ruleManager.SubscribeUpdates(context.Background())
+	go s.ruleHealth.SubscribeUpdates(context.Background())
+	go s.addressLists.SubscribeUpdates(context.Background())
+	if s.cfg.RulesWatch {
+		if err := s.ruleManager.WatchFiles(context.Background()); err != nil {
+			s.logger.Warn("Failed to watch rule files", zap.Error(err))
+		}
+	}
 
 	// 5. 启动消息处理
 	go s.processMessages()
@@ -80,6 +101,9 @@ func (s *RDSService) Start() error {
 // Stop 停止服务
 func (s *RDSService) Stop() {
 	s.running = false
+	if err := s.ruleManager.Deregister(context.Background()); err != nil {
+		s.logger.Warn("Failed to deregister rules version", zap.Error(err))
+	}
 	s.logger.Info("Service stopped")
 }
 
@@ -87,19 +111,35 @@ func (s *RDSService) Stop() {
 func (s *RDSService) loadRules() error {
 	s.logger.Info("Loading rules", zap.String("path", s.cfg.RulesPath))
 
+	// 规则目录导入数据库，失败时仍按数据库中已有的规则启动
 	ctx := context.Background()
-	if err := s.ruleManager.LoadRules(ctx); err != nil {
+	if err := s.ruleManager.SeedRules(ctx); err != nil {
+		s.logger.Warn("Failed to seed rules", zap.Error(err))
+	}
+	if err := s.ruleManager.LoadFromStore(ctx); err != nil {
 		return err
 	}
 
-	s.logger.Info("Rules loaded", zap.Int("enabled_rules", len(s.ruleManager.GetRules())))
+	// 恢复规则隔离状态，失败不影响启动
+	if err := s.ruleHealth.Load(ctx); err != nil {
+		s.logger.Warn("Failed to load rule health", zap.Error(err))
+	}
+
+	// 加载全局地址名单，失败不影响启动
+	if err := s.addressLists.Load(ctx); err != nil {
+		s.logger.Warn("Failed to load address lists", zap.Error(err))
+	}
+
+	s.logger.Info("Rules loaded",
+		zap.Int("enabled_rules", len(s.ruleManager.GetRules())),
+		zap.String("version", s.ruleManager.Version()))
 	return nil
 }
 
 // registerHooks 注册钩子
 func (s *RDSService) registerHooks() {
 	// 注册合约函数调用钩子
-	contractFunctionHook := hooks.NewContractFunctionHook()
+	contractFunctionHook := hooks.NewContractFunctionHook(s.ruleManager.Programs(), s.ruleHealth)
 	s.hookManager.Register(contractFunctionHook)
 
 	s.logger.Info("Registered hooks", zap.String("hooks", "contract_function_call"))
@@ -133,43 +173,40 @@ func (s *RDSService) processTransaction(msg *kafkago.Message) error {
 		return err
 	}
 
-	// 2. 转换为 models.Transaction（用于兼容）
-	tx := &models.Transaction{
-		TxHash:      txData.TxHash,
-		BlockNumber: int64(txData.BlockNumber),
-		FromAddress: txData.FromAddress,
-		ToAddress:   txData.ToAddress,
-		Value:       txData.Value,
-		GasPrice:    int64(txData.GasPrice),
-		GasUsed:     int64(txData.GasUsed),
-		Status:      int16(txData.Status),
+	// 2. 创建评估上下文并填充运行时数据
+	ctx := hooks.NewEvaluationContext(&txData)
+	ctx.Windows = s.windows
+
+	// 3. 将交易记入所有规则的窗口条件，计数不受名单、作用域与其他规则结果的影响
+	set := s.ruleManager.Snapshot()
+	rules := set.Rules
+	if err := s.ruleManager.Programs().ObserveWindows(ctx, rules); err != nil {
+		s.logger.Warn("Failed to record window conditions",
+			zap.String("tx_hash", txData.TxHash),
+			zap.Error(err))
 	}
 
-	// 3. 创建评估上下文并填充运行时数据
-	ctx := ruleengine.NewEvaluationContext(tx, nil)
-	ctx.CallDepth = hooks.CalculateMaxCallDepth(txData.CallStack)
-	ctx.CallCount = len(txData.CallStack)
-	ctx.GasUsed = txData.GasUsed
-	ctx.GasLimit = txData.GasLimit
-
-	// 填充调用轨迹
-	for _, frame := range txData.CallStack {
-		ctx.CallTrace = append(ctx.CallTrace, frame.To)
+	// 4. 检查全局名单：允许名单直接跳过，拒绝名单升级所有风险事件
+	decision, listed := s.addressLists.Check(ctx)
+	if decision == ruleengine.FilterAllow {
+		s.logger.Debug("Transaction skipped by allow list",
+			zap.String("tx_hash", txData.TxHash),
+			zap.String("address", listed))
+		return nil
 	}
 
-	// 检测重入模式
-	if hooks.DetectReentrancyPattern(txData.CallStack) {
-		ctx.ExtractedData["reentrancy_detected"] = true
-	}
-
-	// 4. 触发 hook
-	rules := s.ruleManager.GetRules()
-	events, err := s.hookManager.Trigger("contract_function_call", ctx, rules)
+	// 5. 触发 hook，只评估作用域包含该交易的规则
+	events, err := s.hookManager.Trigger("contract_function_call", ctx, set)
 	if err != nil {
-		return err
+		// 单条规则的错误不影响其他规则产生的风险事件
+		s.logger.Warn("Some rules failed to evaluate",
+			zap.String("tx_hash", txData.TxHash),
+			zap.Error(err))
 	}
 
-	// 5. 处理风险事件
+	// 6. 处理风险事件
+	compared := ruleengine.ShadowTargets(rules)
+	liveEvents := 0
 	for _, event := range events {
 		var matchedRule *ruleengine.Rule
 		for _, rule := range rules {
@@ -183,15 +220,79 @@ func (s *RDSService) processTransaction(msg *kafkago.Message) error {
 			continue
 		}
 
-		score, err := s.scorer.CalculateScore(matchedRule, ctx)
+		// 评分与动作使用规则作用域的上下文，才能引用该规则提取的变量
+		ruleCtx := ctx
+		if event.Context != nil {
+			ruleCtx = event.Context
+		}
+
+		// 影子规则与被比较的线上规则在限流之前记录命中，两者的评分基于相同的上下文；
+		// 被抑制或 stop_on_match 之后才评估的命中同样记录（带 silenced 标记），只是不执行限流与动作
+		record := matchedRule.IsShadow() || compared[matchedRule.Metadata.Name]
+		live := !matchedRule.IsShadow() && event.Silenced == ""
+		if !record && !live {
+			continue
+		}
+		if live {
+			liveEvents++
+		}
+
+		// 评分只计算一次，影子结果与动作使用同一评分；限流与抑制信息只供动作模板引用，不影响评分
+		var score int
+		err := s.ruleHealth.Guard(context.Background(), matchedRule, ruleCtx, func() (err error) {
+			score, err = s.scorer.CalculateScore(matchedRule, ruleCtx)
+			return err
+		})
 		if err != nil {
-			s.logger.Error("Failed to calculate score", zap.Error(err))
+			s.logger.Error("Failed to calculate score", zap.String("rule", matchedRule.Metadata.Name), zap.Error(err))
+			continue
+		}
+		if record {
+			s.recordShadowResult(matchedRule, ruleCtx, score, event.Silenced)
+		}
+		if !live {
 			continue
 		}
 
+		// 限流：被抑制的命中只计数，随下一条放行的风险事件上报；命中拒绝名单的交易不受限流
+		if decision != ruleengine.FilterDeny {
+			result, err := s.throttler.Allow(context.Background(), matchedRule, ruleCtx)
+			if err != nil {
+				s.logger.Warn("Failed to check throttle", zap.String("rule", matchedRule.Metadata.Name), zap.Error(err))
+			}
+			if !result.Allowed {
+				s.logger.Debug("Risk event throttled",
+					zap.String("rule", matchedRule.Metadata.Name),
+					zap.String("tx_hash", event.TxHash),
+					zap.Int64("suppressed", result.Suppressed))
+				continue
+			}
+			if result.Suppressed > 0 {
+				ruleCtx = ruleCtx.WithExtractedData(map[string]interface{}{
+					ruleengine.SuppressedCountKey: result.Suppressed,
+				})
+				event.Metadata[ruleengine.SuppressedCountKey] = result.Suppressed
+			}
+		}
+
+		// 记录被本事件抑制的低优先级规则
+		if len(event.Suppressed) > 0 {
+			ruleCtx = ruleCtx.WithExtractedData(map[string]interface{}{
+				ruleengine.SuppressedRulesKey: event.Suppressed,
+			})
+			event.Metadata[ruleengine.SuppressedRulesKey] = event.Suppressed
+		}
+
+		// 命中拒绝名单的交易一律按严重风险处理
+		actionRule := matchedRule
+		if decision == ruleengine.FilterDeny {
+			score = 100
+			actionRule = ruleengine.EscalateRule(matchedRule)
+		}
+
 		event.Score = score
 
-		if err := s.executor.Execute(matchedRule, ctx, score); err != nil {
+		if err := s.executor.Execute(actionRule, ruleCtx, score); err != nil {
 			s.logger.Error("Failed to execute actions", zap.Error(err))
 		}
 
@@ -202,5 +303,30 @@ func (s *RDSService) processTransaction(msg *kafkago.Message) error {
 			zap.Int("call_depth", ctx.CallDepth))
 	}
 
+	// 没有规则命中时，拒绝名单本身也要产生风险事件
+	if decision == ruleengine.FilterDeny && liveEvents == 0 {
+		rule := ruleengine.DenyListRule(listed)
+		if err := s.executor.Execute(rule, ctx, rule.Scoring.BaseScore); err != nil {
+			s.logger.Error("Failed to execute actions", zap.Error(err))
+		}
+		s.logger.Warn("Deny-listed address detected",
+			zap.String("tx_hash", txData.TxHash),
+			zap.String("address", listed))
+	}
+
 	return nil
 }
+
+// recordShadowResult 记录影子结果，silenced 为事件的 Silenced 标记；失败只记录日志
+func (s *RDSService) recordShadowResult(rule *ruleengine.Rule, ruleCtx *ruleengine.EvaluationContext, score int, silenced string) {
+	if err := s.shadows.Record(context.Background(), rule, ruleCtx, score, silenced); err != nil {
+		s.logger.Error("Failed to record shadow result", zap.String("rule", rule.Metadata.Name), zap.Error(err))
+		return
+	}
+	if rule.IsShadow() {
+		s.logger.Info("Shadow rule matched",
+			zap.String("rule", rule.Metadata.Name),
+			zap.String("tx_hash", ruleCtx.Transaction.TxHash),
+			zap.Int("score", score))
+	}
+}
@@ -36,22 +36,26 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go monitorBlocks(ctx, client, producer, logger)
+	tracer := NewTracer(client, logger, cfg.TraceStateDiff)
+
+	go monitorBlocks(ctx, client, tracer, producer, logger)
 
 	waitForShutdown(logger)
 }
 
 type Config struct {
-	EthNodeURL  string
-	KafkaBroker string
-	KafkaTopic  string
+	EthNodeURL     string
+	KafkaBroker    string
+	KafkaTopic     string
+	TraceStateDiff bool // 是否获取状态差异（balance_change 等条件依赖）
 }
 
 func loadConfig() *Config {
 	return &Config{
-		EthNodeURL:  getEnv("ETH_NODE_URL", "ws://ganache:8545"),
-		KafkaBroker: getEnv("KAFKA_BROKER", "redpanda:9092"),
-		KafkaTopic:  getEnv("KAFKA_TOPIC", "blockchain.transactions"),
+		EthNodeURL:     getEnv("ETH_NODE_URL", "ws://ganache:8545"),
+		KafkaBroker:    getEnv("KAFKA_BROKER", "redpanda:9092"),
+		KafkaTopic:     getEnv("KAFKA_TOPIC", "blockchain.transactions"),
+		TraceStateDiff: getEnv("TRACE_STATE_DIFF", "true") != "false",
 	}
 }
 
@@ -69,7 +73,7 @@ func waitForShutdown(logger *zap.Logger) {
 	logger.Info("Shutdown signal received, stopping service...")
 }
 
-func monitorBlocks(ctx context.Context, client *ethclient.Client, producer *kafka.Producer, logger *zap.Logger) {
+func monitorBlocks(ctx context.Context, client *ethclient.Client, tracer *Tracer, producer *kafka.Producer, logger *zap.Logger) {
 	headers := make(chan *types.Header)
 	sub, err := client.SubscribeNewHead(ctx, headers)
 	if err != nil {
@@ -85,14 +89,14 @@ func monitorBlocks(ctx context.Context, client *ethclient.Client, producer *kafk
 			logger.Error("Subscription error", zap.Error(err))
 			return
 		case header := <-headers:
-			processBlock(ctx, client, producer, header.Number, logger)
+			processBlock(ctx, client, tracer, producer, header.Number, logger)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func processBlock(ctx context.Context, client *ethclient.Client, producer *kafka.Producer, blockNumber *big.Int, logger *zap.Logger) {
+func processBlock(ctx context.Context, client *ethclient.Client, tracer *Tracer, producer *kafka.Producer, blockNumber *big.Int, logger *zap.Logger) {
 	block, err := client.BlockByNumber(ctx, blockNumber)
 	if err != nil {
 		logger.Error("Failed to get block", zap.Error(err))
@@ -102,11 +106,11 @@ func processBlock(ctx context.Context, client *ethclient.Client, producer *kafka
 	logger.Info("Processing block", zap.Uint64("number", block.NumberU64()), zap.Int("txs", len(block.Transactions())))
 
 	for _, tx := range block.Transactions() {
-		processTransaction(ctx, client, producer, tx, block, logger)
+		processTransaction(ctx, client, tracer, producer, tx, block, logger)
 	}
 }
 
-func processTransaction(ctx context.Context, client *ethclient.Client, producer *kafka.Producer, tx *types.Transaction, block *types.Block, logger *zap.Logger) {
+func processTransaction(ctx context.Context, client *ethclient.Client, tracer *Tracer, producer *kafka.Producer, tx *types.Transaction, block *types.Block, logger *zap.Logger) {
 	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
 	if err != nil {
 		logger.Error("Failed to get receipt", zap.Error(err))
@@ -114,7 +118,7 @@ func processTransaction(ctx context.Context, client *ethclient.Client, producer
 	}
 
 	// 构建完整的交易数据（包含调用栈）
-	txData, err := buildTransactionData(ctx, client, tx, receipt, block)
+	txData, err := buildTransactionData(ctx, client, tracer, tx, receipt, block)
 	if err != nil {
 		logger.Error("Failed to build transaction data", zap.Error(err))
 		return
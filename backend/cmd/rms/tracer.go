@@ -3,13 +3,24 @@ package main
 import (
 	"context"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"go.uber.org/zap"
 )
 
+// traceErrorLogInterval 追踪持续失败时重复记录警告的最短间隔
+const traceErrorLogInterval = time.Minute
+
 // TraceResult debug_traceTransaction 返回结果
 type TraceResult struct {
 	Type    string        `json:"type"`
@@ -24,7 +35,7 @@ type TraceResult struct {
 	Calls   []TraceResult `json:"calls"`
 }
 
-func buildTransactionData(ctx context.Context, client *ethclient.Client, tx *types.Transaction, receipt *types.Receipt, block *types.Block) (*TransactionData, error) {
+func buildTransactionData(ctx context.Context, client *ethclient.Client, tracer *Tracer, tx *types.Transaction, receipt *types.Receipt, block *types.Block) (*TransactionData, error) {
 	from, _ := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
 	to := ""
 	if tx.To() != nil {
@@ -39,29 +50,42 @@ func buildTransactionData(ctx context.Context, client *ethclient.Client, tx *typ
 		inputData = "0x" + hex.EncodeToString(tx.Data())
 	}
 
+	// EIP-1559 交易的实际 gas 价格以收据为准
+	gasPrice := tx.GasPrice().Uint64()
+	if receipt.EffectiveGasPrice != nil {
+		gasPrice = receipt.EffectiveGasPrice.Uint64()
+	}
+
 	txData := &TransactionData{
-		TxHash:           tx.Hash().Hex(),
-		BlockNumber:      block.NumberU64(),
-		FromAddress:      from.Hex(),
-		ToAddress:        to,
-		Value:            tx.Value().String(),
-		GasPrice:         tx.GasPrice().Uint64(),
-		GasUsed:          receipt.GasUsed,
-		GasLimit:         tx.Gas(),
-		Status:           receipt.Status,
-		Timestamp:        block.Time(),
-		FunctionSelector: functionSelector,
-		InputData:        inputData,
-		CallStack:        []CallFrame{},
-		Events:           []EventLog{},
+		TxHash:               tx.Hash().Hex(),
+		ChainID:              tx.ChainId().Uint64(),
+		BlockNumber:          block.NumberU64(),
+		FromAddress:          from.Hex(),
+		ToAddress:            to,
+		Value:                tx.Value().String(),
+		Nonce:                tx.Nonce(),
+		TxType:               uint64(tx.Type()),
+		GasUsed:              receipt.GasUsed,
+		GasLimit:             tx.Gas(),
+		Status:               receipt.Status,
+		Timestamp:            block.Time(),
+		GasPrice:             gasPrice,
+		MaxFeePerGas:         tx.GasFeeCap().Uint64(),
+		MaxPriorityFeePerGas: tx.GasTipCap().Uint64(),
+		FunctionSelector:     functionSelector,
+		InputData:            inputData,
+		Block:                buildBlockData(block),
+		CallStack:            []CallFrame{},
+		Events:               []EventLog{},
 	}
 
-	// 追踪调用栈（仅对合约调用）
-	if to != "" && len(tx.Data()) > 0 {
-		trace, err := traceTransaction(ctx, client, tx.Hash())
-		if err == nil {
-			txData.CallStack = parseCallStack(trace, 0)
-		}
+	// 追踪调用栈（仅对合约调用）与状态差异（包括普通转账的余额变化）
+	trace, diff := tracer.Trace(ctx, tx.Hash(), to != "" && len(tx.Data()) > 0)
+	if trace != nil {
+		txData.CallStack = parseCallStack(trace, 0)
+	}
+	if diff != nil {
+		txData.StateDiff = parseStateDiff(diff)
 	}
 
 	// 解析事件
@@ -80,11 +104,149 @@ func buildTransactionData(ctx context.Context, client *ethclient.Client, tx *typ
 	return txData, nil
 }
 
-func traceTransaction(ctx context.Context, client *ethclient.Client, txHash common.Hash) (*TraceResult, error) {
+func buildBlockData(block *types.Block) *BlockData {
+	data := &BlockData{
+		Number:           block.NumberU64(),
+		Hash:             block.Hash().Hex(),
+		ParentHash:       block.ParentHash().Hex(),
+		Miner:            block.Coinbase().Hex(),
+		Timestamp:        block.Time(),
+		GasUsed:          block.GasUsed(),
+		GasLimit:         block.GasLimit(),
+		TransactionCount: len(block.Transactions()),
+	}
+	if block.BaseFee() != nil {
+		data.BaseFee = block.BaseFee().Uint64()
+	}
+	return data
+}
+
+// rpcCaller 执行 JSON-RPC 调用，由 rpc.Client 实现
+type rpcCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// Tracer 通过 debug_traceTransaction 获取调用栈与状态差异，每笔交易最多调用一次：
+// 合约调用通过 muxTracer 在同一次调用中执行 callTracer 与 prestateTracer，普通转账只获取状态差异
+// muxTracer 失败时退回只获取调用栈；节点明确不支持该追踪器时关闭状态差异，其他失败只影响当前交易
+type Tracer struct {
+	client    rpcCaller
+	logger    *zap.Logger
+	stateDiff atomic.Bool // 是否获取状态差异
+
+	mu         sync.Mutex
+	loggedAt   time.Time // 上次记录追踪失败的时间
+	suppressed int       // 此后未记录的失败次数
+}
+
+// NewTracer 创建追踪器，stateDiff 为 false 时只获取合约调用的调用栈
+func NewTracer(client *ethclient.Client, logger *zap.Logger, stateDiff bool) *Tracer {
+	t := &Tracer{client: client.Client(), logger: logger}
+	t.stateDiff.Store(stateDiff)
+	return t
+}
+
+// Trace 追踪交易，contract 表示是否为合约调用；追踪失败时返回 nil 并按 traceErrorLogInterval 限频记录警告
+func (t *Tracer) Trace(ctx context.Context, txHash common.Hash, contract bool) (*TraceResult, *StateDiffResult) {
+	stateDiff := t.stateDiff.Load()
+	switch {
+	case contract && stateDiff:
+		trace, diff, err := traceAll(ctx, t.client, txHash)
+		if err == nil {
+			return trace, diff
+		}
+		// 退回 callTracer；只有节点明确不支持追踪器时才关闭状态差异，超时等临时错误不影响后续交易
+		trace, callErr := traceTransaction(ctx, t.client, txHash)
+		if callErr != nil {
+			t.failed(txHash, err)
+			return nil, nil
+		}
+		if !unsupportedTracer(err) {
+			t.failed(txHash, err)
+		} else if t.stateDiff.CompareAndSwap(true, false) {
+			t.logger.Warn("Node does not support muxTracer, state diffs disabled", zap.Error(err))
+		}
+		return trace, nil
+	case contract:
+		trace, err := traceTransaction(ctx, t.client, txHash)
+		if err != nil {
+			t.failed(txHash, err)
+		}
+		return trace, nil
+	case stateDiff:
+		diff, err := traceStateDiff(ctx, t.client, txHash)
+		if err != nil {
+			t.failed(txHash, err)
+		}
+		return nil, diff
+	default:
+		return nil, nil
+	}
+}
+
+// failed 记录追踪失败，持续失败时按 traceErrorLogInterval 限频并汇总期间的失败次数
+func (t *Tracer) failed(txHash common.Hash, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if !t.loggedAt.IsZero() && now.Sub(t.loggedAt) < traceErrorLogInterval {
+		t.suppressed++
+		return
+	}
+	t.logger.Warn("Failed to trace transaction",
+		zap.String("tx_hash", txHash.Hex()),
+		zap.Int("failures", t.suppressed+1),
+		zap.Error(err))
+	t.loggedAt, t.suppressed = now, 0
+}
+
+// unsupportedTracer 判断追踪错误是否表示节点不支持所请求的追踪器
+func unsupportedTracer(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"tracer not found", "unknown tracer", "unsupported tracer", "is not defined"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// muxTraceResult muxTracer 返回结果，按追踪器名称分别返回
+type muxTraceResult struct {
+	CallTracer     *TraceResult     `json:"callTracer"`
+	PrestateTracer *StateDiffResult `json:"prestateTracer"`
+}
+
+// traceAll 在一次 debug_traceTransaction 调用中同时获取调用栈与状态差异
+func traceAll(ctx context.Context, client rpcCaller, txHash common.Hash) (*TraceResult, *StateDiffResult, error) {
+	var result muxTraceResult
+
+	err := client.CallContext(ctx, &result, "debug_traceTransaction", txHash, map[string]interface{}{
+		"tracer": "muxTracer",
+		"tracerConfig": map[string]interface{}{
+			"callTracer":     map[string]interface{}{},
+			"prestateTracer": map[string]interface{}{"diffMode": true},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.CallTracer == nil || result.PrestateTracer == nil {
+		return nil, nil, fmt.Errorf("incomplete muxTracer result")
+	}
+
+	return result.CallTracer, result.PrestateTracer, nil
+}
+
+func traceTransaction(ctx context.Context, client rpcCaller, txHash common.Hash) (*TraceResult, error) {
 	var result TraceResult
 
 	// 调用 debug_traceTransaction
-	err := client.Client().CallContext(ctx, &result, "debug_traceTransaction", txHash, map[string]interface{}{
+	err := client.CallContext(ctx, &result, "debug_traceTransaction", txHash, map[string]interface{}{
 		"tracer": "callTracer",
 	})
 
@@ -95,6 +257,113 @@ func traceTransaction(ctx context.Context, client *ethclient.Client, txHash comm
 	return &result, nil
 }
 
+// PrestateAccount prestateTracer 返回的账户状态
+type PrestateAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   uint64            `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// StateDiffResult prestateTracer diffMode 返回结果
+// pre 为所有被修改账户在交易前的状态；post 只包含被修改的字段，被销毁的账户不出现在 post 中
+type StateDiffResult struct {
+	Pre  map[string]PrestateAccount `json:"pre"`
+	Post map[string]PrestateAccount `json:"post"`
+}
+
+func traceStateDiff(ctx context.Context, client rpcCaller, txHash common.Hash) (*StateDiffResult, error) {
+	var result StateDiffResult
+
+	err := client.CallContext(ctx, &result, "debug_traceTransaction", txHash, map[string]interface{}{
+		"tracer":       "prestateTracer",
+		"tracerConfig": map[string]interface{}{"diffMode": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// parseStateDiff 将 pre/post 合并为按地址排序的账户差异
+func parseStateDiff(result *StateDiffResult) []AccountDiff {
+	addresses := make(map[string]struct{}, len(result.Pre)+len(result.Post))
+	for address := range result.Pre {
+		addresses[address] = struct{}{}
+	}
+	for address := range result.Post {
+		addresses[address] = struct{}{}
+	}
+
+	diffs := make([]AccountDiff, 0, len(addresses))
+	for address := range addresses {
+		pre, existed := result.Pre[address]
+		post, exists := result.Post[address]
+
+		diff := AccountDiff{
+			Address:       strings.ToLower(address),
+			BalanceBefore: hexToDecimal(pre.Balance),
+			NonceBefore:   pre.Nonce,
+			Created:       !existed,
+			Destroyed:     existed && !exists,
+		}
+
+		// post 中缺少的字段表示未修改
+		diff.BalanceAfter, diff.NonceAfter = diff.BalanceBefore, diff.NonceBefore
+		if post.Balance != "" {
+			diff.BalanceAfter = hexToDecimal(post.Balance)
+		}
+		if post.Nonce != 0 {
+			diff.NonceAfter = post.Nonce
+		}
+		if diff.Destroyed {
+			diff.BalanceAfter = "0"
+		}
+		diff.CodeChanged = post.Code != "" && post.Code != pre.Code || diff.Destroyed && pre.Code != ""
+
+		slots := make(map[string]struct{}, len(pre.Storage)+len(post.Storage))
+		for slot := range pre.Storage {
+			slots[slot] = struct{}{}
+		}
+		for slot := range post.Storage {
+			slots[slot] = struct{}{}
+		}
+		for slot := range slots {
+			before, after := pre.Storage[slot], post.Storage[slot]
+			if before == "" {
+				before = zeroWord
+			}
+			if after == "" {
+				after = zeroWord // post 中缺少的槽位被清零
+			}
+			if before != after {
+				diff.Storage = append(diff.Storage, StorageDiff{Slot: strings.ToLower(slot), Before: before, After: after})
+			}
+		}
+		sort.Slice(diff.Storage, func(i, j int) bool { return diff.Storage[i].Slot < diff.Storage[j].Slot })
+
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Address < diffs[j].Address })
+	return diffs
+}
+
+// zeroWord 32 字节的零值
+const zeroWord = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
+// hexToDecimal 将十六进制数量转换为十进制字符串，空值为 0
+func hexToDecimal(hexStr string) string {
+	if hexStr == "" || hexStr == "0x" {
+		return "0"
+	}
+	var result big.Int
+	if _, ok := result.SetString(strings.TrimPrefix(hexStr, "0x"), 16); !ok {
+		return "0"
+	}
+	return result.String()
+}
+
 func parseCallStack(trace *TraceResult, depth int) []CallFrame {
 	if trace == nil {
 		return []CallFrame{}
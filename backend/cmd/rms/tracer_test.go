@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+const (
+	testCallTrace = `{"type":"CALL","from":"0x01","to":"0x02","input":"0x2e1a7d4d"}`
+	testMuxTrace  = `{"callTracer":` + testCallTrace + `,"prestateTracer":{"pre":{"0x02":{"balance":"0x10"}},"post":{"0x02":{"balance":"0x0"}}}}`
+)
+
+// fakeCaller 按 tracer 名称返回预设结果，muxErrs 依次作为 muxTracer 调用的错误
+type fakeCaller struct {
+	muxErrs []error
+	calls   map[string]int
+}
+
+func (f *fakeCaller) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	tracer := args[1].(map[string]interface{})["tracer"].(string)
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[tracer]++
+
+	response := testCallTrace
+	if tracer == "muxTracer" {
+		if len(f.muxErrs) > 0 {
+			err := f.muxErrs[0]
+			f.muxErrs = f.muxErrs[1:]
+			return err
+		}
+		response = testMuxTrace
+	}
+	return json.Unmarshal([]byte(response), result)
+}
+
+func TestTracerMuxFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		stateDiff bool // 失败后是否仍获取状态差异
+	}{
+		{"timeout", context.DeadlineExceeded, true},
+		{"node hiccup", errors.New("connection reset by peer"), true},
+		{"tracer not found", errors.New("tracer not found"), false},
+		{"js tracer undefined", errors.New("ReferenceError: muxTracer is not defined"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller := &fakeCaller{muxErrs: []error{tt.err}}
+			tracer := &Tracer{client: caller, logger: zap.NewNop()}
+			tracer.stateDiff.Store(true)
+			txHash := common.HexToHash("0x1")
+
+			// 第一次 muxTracer 失败，退回 callTracer 仍返回调用栈
+			trace, diff := tracer.Trace(context.Background(), txHash, true)
+			if trace == nil || trace.Input != "0x2e1a7d4d" || diff != nil {
+				t.Fatalf("first Trace() = %+v, %+v, want call trace without state diff", trace, diff)
+			}
+			if got := tracer.stateDiff.Load(); got != tt.stateDiff {
+				t.Fatalf("stateDiff = %v, want %v", got, tt.stateDiff)
+			}
+
+			trace, diff = tracer.Trace(context.Background(), txHash, true)
+			if trace == nil {
+				t.Fatal("second Trace() returned no call trace")
+			}
+			if got := diff != nil; got != tt.stateDiff {
+				t.Errorf("second Trace() state diff = %+v, want present %v", diff, tt.stateDiff)
+			}
+			wantMux := 1
+			if tt.stateDiff {
+				wantMux = 2
+			}
+			if caller.calls["muxTracer"] != wantMux {
+				t.Errorf("muxTracer calls = %d, want %d", caller.calls["muxTracer"], wantMux)
+			}
+		})
+	}
+}
+
+func TestParseStateDiff(t *testing.T) {
+	const slot = "0x0000000000000000000000000000000000000000000000000000000000000001"
+	result := &StateDiffResult{
+		Pre: map[string]PrestateAccount{
+			// 余额减少，槽位被清零（post 中缺少）
+			"0xAA": {Balance: "0x10", Nonce: 1, Storage: map[string]string{slot: "0x" + strings.Repeat("0", 63) + "5"}},
+			// 被销毁的合约
+			"0xbb": {Balance: "0x1", Code: "0x6080"},
+		},
+		Post: map[string]PrestateAccount{
+			"0xAA": {Balance: "0x4", Nonce: 2},
+			// 交易前不存在（pre 中缺少）的新合约
+			"0xcc": {Balance: "0x2", Code: "0x6080", Storage: map[string]string{slot: "0x" + strings.Repeat("0", 63) + "7"}},
+		},
+	}
+	want := []AccountDiff{
+		{Address: "0xaa", BalanceBefore: "16", BalanceAfter: "4", NonceBefore: 1, NonceAfter: 2,
+			Storage: []StorageDiff{{Slot: slot, Before: "0x" + strings.Repeat("0", 63) + "5", After: zeroWord}}},
+		{Address: "0xbb", BalanceBefore: "1", BalanceAfter: "0", CodeChanged: true, Destroyed: true},
+		{Address: "0xcc", BalanceBefore: "0", BalanceAfter: "2", CodeChanged: true, Created: true,
+			Storage: []StorageDiff{{Slot: slot, Before: zeroWord, After: "0x" + strings.Repeat("0", 63) + "7"}}},
+	}
+	if got := parseStateDiff(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStateDiff() =\n%+v\nwant\n%+v", got, want)
+	}
+}
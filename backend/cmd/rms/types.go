@@ -4,25 +4,51 @@ package main
 type TransactionData struct {
 	// 基础信息
 	TxHash      string `json:"tx_hash"`
+	ChainID     uint64 `json:"chain_id"` // 未启用 EIP-155 的旧交易为 0
 	BlockNumber uint64 `json:"block_number"`
 	FromAddress string `json:"from_address"`
 	ToAddress   string `json:"to_address"`
 	Value       string `json:"value"`
-	GasPrice    uint64 `json:"gas_price"`
+	Nonce       uint64 `json:"nonce"`
+	TxType      uint64 `json:"tx_type"` // 0 legacy, 1 access list, 2 EIP-1559, 3 blob
 	GasUsed     uint64 `json:"gas_used"`
 	GasLimit    uint64 `json:"gas_limit"`
 	Status      uint64 `json:"status"`
 	Timestamp   uint64 `json:"timestamp"`
 
+	// 费用信息
+	GasPrice             uint64 `json:"gas_price"`                // 实际 gas 价格
+	MaxFeePerGas         uint64 `json:"max_fee_per_gas"`          // EIP-1559 最高 gas 价格
+	MaxPriorityFeePerGas uint64 `json:"max_priority_fee_per_gas"` // EIP-1559 最高小费
+
 	// 函数调用信息
 	FunctionSelector string `json:"function_selector"` // 前 4 字节
 	InputData        string `json:"input_data"`
 
+	// 区块信息
+	Block *BlockData `json:"block"`
+
 	// 调用栈
 	CallStack []CallFrame `json:"call_stack"`
 
 	// 事件日志
 	Events []EventLog `json:"events"`
+
+	// 状态差异（prestateTracer diffMode），只包含被修改的账户
+	StateDiff []AccountDiff `json:"state_diff,omitempty"`
+}
+
+// BlockData 区块信息
+type BlockData struct {
+	Number           uint64 `json:"number"`
+	Hash             string `json:"hash"`
+	ParentHash       string `json:"parent_hash"`
+	Miner            string `json:"miner"`
+	Timestamp        uint64 `json:"timestamp"`
+	GasUsed          uint64 `json:"gas_used"`
+	GasLimit         uint64 `json:"gas_limit"`
+	BaseFee          uint64 `json:"base_fee"` // EIP-1559 之前为 0
+	TransactionCount int    `json:"transaction_count"`
 }
 
 // CallFrame 调用帧
@@ -46,3 +72,23 @@ type EventLog struct {
 	Topics  []string `json:"topics"`  // 事件主题
 	Data    string   `json:"data"`    // 事件数据
 }
+
+// AccountDiff 账户在交易前后的状态差异
+type AccountDiff struct {
+	Address       string        `json:"address"`
+	BalanceBefore string        `json:"balance_before"` // 十进制 wei
+	BalanceAfter  string        `json:"balance_after"`
+	NonceBefore   uint64        `json:"nonce_before"`
+	NonceAfter    uint64        `json:"nonce_after"`
+	CodeChanged   bool          `json:"code_changed"` // 合约代码被部署、替换或销毁
+	Created       bool          `json:"created"`      // 交易前不存在的账户
+	Destroyed     bool          `json:"destroyed"`    // 交易中被销毁的账户
+	Storage       []StorageDiff `json:"storage,omitempty"`
+}
+
+// StorageDiff 存储槽的变化，槽位与值均为 32 字节十六进制
+type StorageDiff struct {
+	Slot   string `json:"slot"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
@@ -45,6 +45,30 @@ func (r *RedisClient) GetRaw(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
 
+func (r *RedisClient) HSet(ctx context.Context, key, field string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(ctx, key, field, data).Err()
+}
+
+func (r *RedisClient) HGet(ctx context.Context, key, field string, dest interface{}) error {
+	data, err := r.client.HGet(ctx, key, field).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (r *RedisClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, key).Result()
+}
+
+func (r *RedisClient) HDel(ctx context.Context, key string, fields ...string) error {
+	return r.client.HDel(ctx, key, fields...).Err()
+}
+
 func (r *RedisClient) Publish(ctx context.Context, channel string, message interface{}) error {
 	data, err := json.Marshal(message)
 	if err != nil {
@@ -61,6 +85,11 @@ func (r *RedisClient) Incr(ctx context.Context, key string) error {
 	return r.client.Incr(ctx, key).Err()
 }
 
+// RunScript 执行 Lua 脚本（优先 EVALSHA，脚本未缓存时自动回退到 EVAL）
+func (r *RedisClient) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	return script.Run(ctx, r.client, keys, args...).Result()
+}
+
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// AddressListEntry 全局地址名单条目
+type AddressListEntry struct {
+	ID        int64     `json:"id" db:"id"`
+	Address   string    `json:"address" db:"address"`     // 小写地址
+	ListType  string    `json:"list_type" db:"list_type"` // allow / deny
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DetectionRule 数据库中的检测规则，Content 为完整的规则 YAML
+type DetectionRule struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Severity    string    `json:"severity" db:"severity"`
+	Enabled     bool      `json:"enabled" db:"enabled"`
+	Version     string    `json:"version" db:"version"`           // metadata.version
+	Revision    int       `json:"revision" db:"revision"`         // 当前内容对应的修订号（rule_revisions）
+	Content     string    `json:"content,omitempty" db:"content"` // 规则 YAML
+	ContentHash string    `json:"content_hash" db:"content_hash"` // 规则内容的 SHA-256
+	Source      string    `json:"source" db:"source"`             // file（由规则目录导入）/ api（通过 API 修改过）
+	UpdatedBy   string    `json:"updated_by" db:"updated_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
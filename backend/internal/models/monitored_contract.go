@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MonitoredContract 监控的合约，规则的 scope.monitored_contracts 按名称引用
+type MonitoredContract struct {
+	ID        int64     `json:"id" db:"id"`
+	Address   string    `json:"address" db:"address"`
+	Name      string    `json:"name" db:"name"`
+	ChainID   int       `json:"chain_id" db:"chain_id"`
+	Status    string    `json:"status" db:"status"` // active / inactive
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -10,5 +10,9 @@ type RiskEvent struct {
 	TxHash          string    `json:"tx_hash" db:"tx_hash"`
 	Description     string    `json:"description" db:"description"`
 	Score           int       `json:"score" db:"score"`
+	SuppressedCount int64     `json:"suppressed_count" db:"suppressed_count"` // 此前被限流抑制的同类命中次数
+	SuppressedRules []string  `json:"suppressed_rules" db:"suppressed_rules"` // 同一交易中被本事件抑制的低优先级规则
+	RuleRevision    int       `json:"rule_revision" db:"rule_revision"`       // 产生事件的规则修订号，0 表示未记录
+	RuleHash        string    `json:"rule_hash" db:"rule_hash"`               // 产生事件的规则文件内容哈希
 	DetectedAt      time.Time `json:"detected_at" db:"detected_at"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RuleRevision 规则修订：规则文件某一版内容的快照
+type RuleRevision struct {
+	ID          int64     `json:"id" db:"id"`
+	RuleName    string    `json:"rule_name" db:"rule_name"`
+	Revision    int       `json:"revision" db:"revision"`         // 规则内从 1 开始递增的修订号
+	Version     string    `json:"version" db:"version"`           // metadata.version
+	ContentHash string    `json:"content_hash" db:"content_hash"` // 规则文件内容的 SHA-256
+	Content     string    `json:"content,omitempty" db:"content"` // 规则文件 YAML，列表接口不返回
+	Author      string    `json:"author" db:"author"`
+	Source      string    `json:"source" db:"source"` // file（由规则目录导入）/ api（通过 API 修改）/ rollback（回滚）
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
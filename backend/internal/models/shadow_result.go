@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// ShadowResult 影子规则或被比较的线上规则的一次命中
+type ShadowResult struct {
+	ID              int64     `json:"id" db:"id"`
+	RuleName        string    `json:"rule_name" db:"rule_name"`
+	Mode            string    `json:"mode" db:"mode"` // shadow（影子规则）/ live（被比较的线上规则）
+	RuleRevision    int       `json:"rule_revision" db:"rule_revision"`
+	RuleHash        string    `json:"rule_hash" db:"rule_hash"`
+	TxHash          string    `json:"tx_hash" db:"tx_hash"`
+	ContractAddress string    `json:"contract_address" db:"contract_address"`
+	Score           int       `json:"score" db:"score"`
+	Silenced        string    `json:"silenced,omitempty" db:"silenced"` // suppressed / stopped：命中但未执行动作，为空表示正常处理
+	DetectedAt      time.Time `json:"detected_at" db:"detected_at"`
+}
+
+// ShadowComparison 影子规则与线上规则在一段时间内的命中比较，命中数按交易去重
+type ShadowComparison struct {
+	Shadow         string    `json:"shadow"`
+	Live           string    `json:"live"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	ShadowHits     int       `json:"shadow_hits"`
+	LiveHits       int       `json:"live_hits"`
+	Overlap        int       `json:"overlap"`       // 两者都命中的交易数
+	ShadowOnly     int       `json:"shadow_only"`   // 只有影子规则命中（新增的命中）
+	LiveOnly       int       `json:"live_only"`     // 只有线上规则命中（影子规则漏掉的命中）
+	LiveSilenced   int       `json:"live_silenced"` // 线上规则命中但被抑制或在 stop_on_match 之后才评估、未执行动作的交易数
+	OverlapRate    float64   `json:"overlap_rate"`  // Overlap / 两者命中的并集，都未命中时为 0
+	ShadowAvgScore float64   `json:"shadow_avg_score"`
+	LiveAvgScore   float64   `json:"live_avg_score"`
+	AvgScoreDelta  float64   `json:"avg_score_delta"` // 两者都命中的交易上，影子评分减线上评分的平均值
+
+	// 各取最近的若干笔，便于逐笔检查差异
+	ShadowOnlyTxs []string `json:"shadow_only_txs"`
+	LiveOnlyTxs   []string `json:"live_only_txs"`
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/haswell/bcscan/internal/models"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// ErrAddressListed 地址已在该名单中
+var ErrAddressListed = errors.New("address already in list")
+
+// uniqueViolation PostgreSQL 唯一约束冲突的错误码
+const uniqueViolation = "23505"
+
+// AddressListRepository 全局地址名单仓储
+type AddressListRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewAddressListRepository(db *sql.DB, logger *zap.Logger) *AddressListRepository {
+	return &AddressListRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// List 获取名单条目，listType 为空时返回全部
+func (r *AddressListRepository) List(ctx context.Context, listType string) ([]*models.AddressListEntry, error) {
+	query := `SELECT id, address, list_type, COALESCE(reason, ''), created_at FROM address_lists`
+	args := []interface{}{}
+
+	if listType != "" {
+		query += " WHERE list_type = $1"
+		args = append(args, listType)
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AddressListEntry{}
+	for rows.Next() {
+		var entry models.AddressListEntry
+		if err := rows.Scan(&entry.ID, &entry.Address, &entry.ListType, &entry.Reason, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// Create 添加名单条目，地址已在该名单中时返回 ErrAddressListed
+func (r *AddressListRepository) Create(ctx context.Context, entry *models.AddressListEntry) error {
+	query := `
+		INSERT INTO address_lists (address, list_type, reason)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, entry.Address, entry.ListType, entry.Reason).
+		Scan(&entry.ID, &entry.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrAddressListed
+	}
+	return err
+}
+
+// Delete 删除名单条目，条目不存在时返回 sql.ErrNoRows
+func (r *AddressListRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM address_lists WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+// MonitoredContractRepository 监控合约仓储
+type MonitoredContractRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewMonitoredContractRepository(db *sql.DB, logger *zap.Logger) *MonitoredContractRepository {
+	return &MonitoredContractRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ListActive 获取状态为 active 的合约
+func (r *MonitoredContractRepository) ListActive(ctx context.Context) ([]*models.MonitoredContract, error) {
+	query := `
+		SELECT id, address, COALESCE(name, ''), chain_id, status, created_at, updated_at
+		FROM monitored_contracts
+		WHERE status = 'active'
+		ORDER BY id
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contracts := []*models.MonitoredContract{}
+	for rows.Next() {
+		var c models.MonitoredContract
+		if err := rows.Scan(&c.ID, &c.Address, &c.Name, &c.ChainID, &c.Status, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, &c)
+	}
+	return contracts, rows.Err()
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/haswell/bcscan/internal/cache"
 	"github.com/haswell/bcscan/internal/models"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -52,12 +53,13 @@ func (r *RiskEventRepository) writeWorker() {
 
 // writeToDBAndCache 实际写入逻辑
 func (r *RiskEventRepository) writeToDBAndCache(ctx context.Context, event *models.RiskEvent) error {
-	query := `INSERT INTO risk_events (event_type, severity, contract_address, tx_hash, description, score, detected_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	query := `INSERT INTO risk_events (event_type, severity, contract_address, tx_hash, description, score, suppressed_count, suppressed_rules, rule_revision, rule_hash, detected_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
 
 	err := r.db.QueryRowContext(ctx, query,
 		event.EventType, event.Severity, event.ContractAddress,
-		event.TxHash, event.Description, event.Score, event.DetectedAt,
+		event.TxHash, event.Description, event.Score, event.SuppressedCount, pq.Array(event.SuppressedRules),
+		event.RuleRevision, event.RuleHash, event.DetectedAt,
 	).Scan(&event.ID)
 
 	if err != nil {
@@ -101,12 +103,13 @@ func (r *RiskEventRepository) GetByID(ctx context.Context, id int) (*models.Risk
 	}
 
 	// 缓存未命中，从 DB 读取
-	query := `SELECT id, event_type, severity, contract_address, tx_hash, description, score, detected_at
+	query := `SELECT id, event_type, severity, contract_address, tx_hash, description, score, suppressed_count, suppressed_rules, rule_revision, rule_hash, detected_at
 	          FROM risk_events WHERE id = $1`
 
 	err = r.db.QueryRowContext(ctx, query, id).Scan(
 		&event.ID, &event.EventType, &event.Severity, &event.ContractAddress,
-		&event.TxHash, &event.Description, &event.Score, &event.DetectedAt,
+		&event.TxHash, &event.Description, &event.Score, &event.SuppressedCount, pq.Array(&event.SuppressedRules),
+		&event.RuleRevision, &event.RuleHash, &event.DetectedAt,
 	)
 
 	if err != nil {
@@ -131,7 +134,7 @@ func (r *RiskEventRepository) List(ctx context.Context, severity string, limit i
 	}
 
 	// 从 DB 读取
-	query := `SELECT id, event_type, severity, contract_address, tx_hash, description, score, detected_at
+	query := `SELECT id, event_type, severity, contract_address, tx_hash, description, score, suppressed_count, suppressed_rules, rule_revision, rule_hash, detected_at
 	          FROM risk_events WHERE 1=1`
 	args := []interface{}{}
 
@@ -152,7 +155,8 @@ func (r *RiskEventRepository) List(ctx context.Context, severity string, limit i
 		var event models.RiskEvent
 		err := rows.Scan(
 			&event.ID, &event.EventType, &event.Severity, &event.ContractAddress,
-			&event.TxHash, &event.Description, &event.Score, &event.DetectedAt,
+			&event.TxHash, &event.Description, &event.Score, &event.SuppressedCount, pq.Array(&event.SuppressedRules),
+			&event.RuleRevision, &event.RuleHash, &event.DetectedAt,
 		)
 		if err != nil {
 			continue
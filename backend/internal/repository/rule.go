@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+// 规则来源（models.DetectionRule.Source），同时用作修订来源（models.RuleRevision.Source）
+const (
+	RuleSourceFile = "file" // 由规则目录导入，规则文件变化时随启动更新
+	RuleSourceAPI  = "api"  // 通过 API 创建或修改过，不再被规则文件覆盖
+)
+
+// RevisionSourceRollback 回滚产生的修订，规则来源记为 api
+const RevisionSourceRollback = "rollback"
+
+// ErrRuleExists 创建的规则已存在
+var ErrRuleExists = errors.New("rule already exists")
+
+// RuleRepository 检测规则仓储，规则的唯一来源；每次内容变化都在同一事务中记录修订（rule_revisions）
+type RuleRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewRuleRepository(db *sql.DB, logger *zap.Logger) *RuleRepository {
+	return &RuleRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const ruleColumns = `id, name, COALESCE(severity, ''), enabled, COALESCE(version, ''), revision, content, content_hash,
+	source, COALESCE(updated_by, ''), created_at, updated_at`
+
+// scanRule 按 ruleColumns 的顺序扫描一行，extra 为查询中追加的列
+func scanRule(row interface{ Scan(...interface{}) error }, rule *models.DetectionRule, extra ...interface{}) error {
+	dest := []interface{}{&rule.ID, &rule.Name, &rule.Severity, &rule.Enabled, &rule.Version, &rule.Revision,
+		&rule.Content, &rule.ContentHash, &rule.Source, &rule.UpdatedBy, &rule.CreatedAt, &rule.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// List 获取所有未删除的规则（含内容），按名称排序
+func (r *RuleRepository) List(ctx context.Context) ([]*models.DetectionRule, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+ruleColumns+` FROM detection_rules WHERE deleted_at IS NULL ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*models.DetectionRule{}
+	for rows.Next() {
+		var rule models.DetectionRule
+		if err := scanRule(rows, &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+// Get 获取规则，不存在或已删除时返回 sql.ErrNoRows
+func (r *RuleRepository) Get(ctx context.Context, name string) (*models.DetectionRule, error) {
+	var rule models.DetectionRule
+	row := r.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM detection_rules WHERE name = $1 AND deleted_at IS NULL`, name)
+	if err := scanRule(row, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Seed 导入规则目录中的规则：新规则直接写入；已有规则只在来源仍为 file 且内容变化时更新，
+// 通过 API 修改过或已删除的规则保持不变。返回是否写入
+func (r *RuleRepository) Seed(ctx context.Context, rule *models.DetectionRule) (bool, error) {
+	return r.save(ctx, rule, RuleSourceFile, func(existing *models.DetectionRule, deleted bool) (bool, error) {
+		return existing == nil || (!deleted && existing.Source == RuleSourceFile), nil
+	})
+}
+
+// Create 创建规则，规则已存在时返回 ErrRuleExists；已删除的同名规则会被恢复，修订号继续递增
+func (r *RuleRepository) Create(ctx context.Context, rule *models.DetectionRule) error {
+	_, err := r.save(ctx, rule, RuleSourceAPI, func(existing *models.DetectionRule, deleted bool) (bool, error) {
+		if existing != nil && !deleted {
+			return false, ErrRuleExists
+		}
+		return true, nil
+	})
+	return err
+}
+
+// Update 替换规则内容，revisionSource 为修订来源（api 或 rollback）；规则不存在或已删除时返回 sql.ErrNoRows
+func (r *RuleRepository) Update(ctx context.Context, rule *models.DetectionRule, revisionSource string) error {
+	_, err := r.save(ctx, rule, revisionSource, func(existing *models.DetectionRule, deleted bool) (bool, error) {
+		if existing == nil || deleted {
+			return false, sql.ErrNoRows
+		}
+		return true, nil
+	})
+	return err
+}
+
+// save 在事务中写入规则并记录修订，allow 根据已有的行（不存在时为 nil）决定是否写入
+// 内容未变化时不更新行，rule 由已有的行填充
+func (r *RuleRepository) save(ctx context.Context, rule *models.DetectionRule, revisionSource string,
+	allow func(existing *models.DetectionRule, deleted bool) (bool, error)) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := lockRule(ctx, tx, rule.Name); err != nil {
+		return false, err
+	}
+
+	var existing *models.DetectionRule
+	var current models.DetectionRule
+	var deleted bool
+	row := tx.QueryRowContext(ctx, `SELECT `+ruleColumns+`, deleted_at IS NOT NULL FROM detection_rules WHERE name = $1`, rule.Name)
+	switch err := scanRule(row, &current, &deleted); {
+	case err == nil:
+		existing = &current
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+
+	ok, err := allow(existing, deleted)
+	if err != nil || !ok {
+		return false, err
+	}
+	if existing != nil && !deleted && existing.ContentHash == rule.ContentHash {
+		*rule = *existing
+		return false, nil
+	}
+
+	rev := &models.RuleRevision{
+		RuleName:    rule.Name,
+		Version:     rule.Version,
+		ContentHash: rule.ContentHash,
+		Content:     rule.Content,
+		Author:      rule.UpdatedBy,
+		Source:      revisionSource,
+	}
+	if _, err := recordRevision(ctx, tx, rev); err != nil {
+		return false, err
+	}
+	rule.Revision = rev.Revision
+
+	source := RuleSourceAPI
+	if revisionSource == RuleSourceFile {
+		source = RuleSourceFile
+	}
+	row = tx.QueryRowContext(ctx, `
+		INSERT INTO detection_rules (name, severity, enabled, version, revision, content, content_hash, source, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (name) DO UPDATE SET
+			severity = EXCLUDED.severity, enabled = EXCLUDED.enabled, version = EXCLUDED.version,
+			revision = EXCLUDED.revision, content = EXCLUDED.content, content_hash = EXCLUDED.content_hash,
+			source = EXCLUDED.source, updated_by = EXCLUDED.updated_by,
+			updated_at = CURRENT_TIMESTAMP, deleted_at = NULL
+		RETURNING `+ruleColumns,
+		rule.Name, rule.Severity, rule.Enabled, rule.Version, rule.Revision, rule.Content, rule.ContentHash, source, rule.UpdatedBy,
+	)
+	if err := scanRule(row, rule); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Delete 删除规则（保留行与修订历史，规则目录中的同名规则不会再被导入），规则不存在时返回 sql.ErrNoRows
+func (r *RuleRepository) Delete(ctx context.Context, name, author string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE detection_rules SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, updated_by = $2
+		WHERE name = $1 AND deleted_at IS NULL
+	`, name, author)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
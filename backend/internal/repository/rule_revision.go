@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+// RuleRevisionRepository 规则修订历史仓储
+type RuleRevisionRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewRuleRevisionRepository(db *sql.DB, logger *zap.Logger) *RuleRevisionRepository {
+	return &RuleRevisionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// recordRevision 在事务中记录规则修订：内容与最新修订相同时不新增，返回 false 并用最新修订填充 rev；
+// 否则以下一个修订号插入并返回 true。调用方需已持有该规则的 advisory lock（见 lockRule）
+func recordRevision(ctx context.Context, tx *sql.Tx, rev *models.RuleRevision) (bool, error) {
+	var latest models.RuleRevision
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, revision, COALESCE(version, ''), content_hash, COALESCE(author, ''), source, created_at
+		FROM rule_revisions WHERE rule_name = $1 ORDER BY revision DESC LIMIT 1
+	`, rev.RuleName).Scan(&latest.ID, &latest.Revision, &latest.Version, &latest.ContentHash, &latest.Author, &latest.Source, &latest.CreatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if err == nil && latest.ContentHash == rev.ContentHash {
+		rev.ID, rev.Revision, rev.Version = latest.ID, latest.Revision, latest.Version
+		rev.Author, rev.Source, rev.CreatedAt = latest.Author, latest.Source, latest.CreatedAt
+		return false, nil
+	}
+
+	rev.Revision = latest.Revision + 1
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rule_revisions (rule_name, revision, version, content_hash, content, author, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, rev.RuleName, rev.Revision, rev.Version, rev.ContentHash, rev.Content, rev.Author, rev.Source).
+		Scan(&rev.ID, &rev.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lockRule 在事务中锁定规则：API 与各 RDS 实例可能同时导入或修改同一规则，用事务级 advisory lock 串行化
+func lockRule(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, name)
+	return err
+}
+
+// List 获取规则的所有修订（不含内容），按修订号从新到旧排列
+func (r *RuleRevisionRepository) List(ctx context.Context, ruleName string) ([]*models.RuleRevision, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, rule_name, revision, COALESCE(version, ''), content_hash, COALESCE(author, ''), source, created_at
+		FROM rule_revisions WHERE rule_name = $1 ORDER BY revision DESC
+	`, ruleName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.RuleRevision{}
+	for rows.Next() {
+		var rev models.RuleRevision
+		if err := rows.Scan(&rev.ID, &rev.RuleName, &rev.Revision, &rev.Version, &rev.ContentHash, &rev.Author, &rev.Source, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &rev)
+	}
+	return revisions, rows.Err()
+}
+
+// Get 获取规则的指定修订（含内容），不存在时返回 sql.ErrNoRows
+func (r *RuleRevisionRepository) Get(ctx context.Context, ruleName string, revision int) (*models.RuleRevision, error) {
+	var rev models.RuleRevision
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, rule_name, revision, COALESCE(version, ''), content_hash, content, COALESCE(author, ''), source, created_at
+		FROM rule_revisions WHERE rule_name = $1 AND revision = $2
+	`, ruleName, revision).Scan(&rev.ID, &rev.RuleName, &rev.Revision, &rev.Version, &rev.ContentHash, &rev.Content, &rev.Author, &rev.Source, &rev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/haswell/bcscan/internal/models"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// shadowSampleLimit 比较结果中各列出的只有一方命中的交易数
+const shadowSampleLimit = 20
+
+// ShadowResultRepository 影子规则结果仓储
+type ShadowResultRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewShadowResultRepository(db *sql.DB, logger *zap.Logger) *ShadowResultRepository {
+	return &ShadowResultRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create 记录一次命中
+func (r *ShadowResultRepository) Create(ctx context.Context, result *models.ShadowResult) error {
+	query := `
+		INSERT INTO shadow_results (rule_name, mode, rule_revision, rule_hash, tx_hash, contract_address, score, silenced, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		result.RuleName, result.Mode, result.RuleRevision, result.RuleHash,
+		result.TxHash, result.ContractAddress, result.Score, result.Silenced, result.DetectedAt,
+	).Scan(&result.ID)
+}
+
+// Compare 比较影子规则与线上规则在 [from, to) 内的命中；live 为空时只统计影子规则
+// 同一交易多次命中按最高评分计一次；被抑制或 stop_on_match 之后才评估的命中同样计入，线上规则的这类交易另计于 LiveSilenced
+func (r *ShadowResultRepository) Compare(ctx context.Context, shadow, live string, from, to time.Time) (*models.ShadowComparison, error) {
+	cmp := &models.ShadowComparison{
+		Shadow:        shadow,
+		Live:          live,
+		From:          from,
+		To:            to,
+		ShadowOnlyTxs: []string{},
+		LiveOnlyTxs:   []string{},
+	}
+
+	hits := `
+		WITH s AS (
+			SELECT tx_hash, MAX(score) AS score, MAX(detected_at) AS detected_at FROM shadow_results
+			WHERE rule_name = $1 AND mode = 'shadow' AND detected_at >= $3 AND detected_at < $4
+			GROUP BY tx_hash
+		), l AS (
+			SELECT tx_hash, MAX(score) AS score, MAX(detected_at) AS detected_at, BOOL_AND(silenced <> '') AS silenced FROM shadow_results
+			WHERE rule_name = $2 AND mode = 'live' AND detected_at >= $3 AND detected_at < $4
+			GROUP BY tx_hash
+		)
+	`
+	err := r.db.QueryRowContext(ctx, hits+`
+		SELECT
+			(SELECT COUNT(*) FROM s),
+			(SELECT COUNT(*) FROM l),
+			(SELECT COUNT(*) FROM s JOIN l USING (tx_hash)),
+			(SELECT COUNT(*) FROM l WHERE silenced),
+			COALESCE((SELECT AVG(score) FROM s), 0),
+			COALESCE((SELECT AVG(score) FROM l), 0),
+			COALESCE((SELECT AVG(s.score - l.score) FROM s JOIN l USING (tx_hash)), 0)
+	`, shadow, live, from, to).Scan(&cmp.ShadowHits, &cmp.LiveHits, &cmp.Overlap, &cmp.LiveSilenced,
+		&cmp.ShadowAvgScore, &cmp.LiveAvgScore, &cmp.AvgScoreDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp.ShadowOnly = cmp.ShadowHits - cmp.Overlap
+	cmp.LiveOnly = cmp.LiveHits - cmp.Overlap
+	if union := cmp.ShadowHits + cmp.LiveHits - cmp.Overlap; union > 0 {
+		cmp.OverlapRate = float64(cmp.Overlap) / float64(union)
+	}
+
+	err = r.db.QueryRowContext(ctx, hits+`
+		SELECT
+			ARRAY(SELECT tx_hash FROM s WHERE tx_hash NOT IN (SELECT tx_hash FROM l) ORDER BY detected_at DESC LIMIT $5),
+			ARRAY(SELECT tx_hash FROM l WHERE tx_hash NOT IN (SELECT tx_hash FROM s) ORDER BY detected_at DESC LIMIT $5)
+	`, shadow, live, from, to, shadowSampleLimit).Scan(pq.Array(&cmp.ShadowOnlyTxs), pq.Array(&cmp.LiveOnlyTxs))
+	if err != nil {
+		return nil, err
+	}
+	return cmp, nil
+}
@@ -0,0 +1,138 @@
+package ruleengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/haswell/bcscan/internal/cache"
+	"github.com/haswell/bcscan/internal/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	AddressListsUpdateChannel = "address_lists:update"
+
+	ListTypeAllow = "allow" // 允许名单：不参与规则检测（如已知的良性机器人）
+	ListTypeDeny  = "deny"  // 拒绝名单：一律升级为严重风险（如已知攻击者）
+)
+
+// AddressLists 全局地址名单
+// 名单保存在数据库中，通过 API 维护；变更后经 Redis 通知各 RDS 实例重新加载
+// 允许名单匹配交易的发送方或接收方，拒绝名单匹配交易及调用栈中出现的任意地址
+type AddressLists struct {
+	mu     sync.RWMutex
+	allow  addressSet
+	deny   addressSet
+	repo   *repository.AddressListRepository
+	redis  *cache.RedisClient
+	logger *zap.Logger
+}
+
+func NewAddressLists(repo *repository.AddressListRepository, redis *cache.RedisClient, logger *zap.Logger) *AddressLists {
+	return &AddressLists{
+		allow:  addressSet{},
+		deny:   addressSet{},
+		repo:   repo,
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// Load 从数据库加载名单，非法地址记录警告后跳过
+func (l *AddressLists) Load(ctx context.Context) error {
+	entries, err := l.repo.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to load address lists: %w", err)
+	}
+
+	allow, deny := addressSet{}, addressSet{}
+	for _, entry := range entries {
+		address, err := NormalizeAddress(entry.Address)
+		if err != nil {
+			l.logger.Warn("Invalid address list entry", zap.Int64("id", entry.ID), zap.Error(err))
+			continue
+		}
+		switch entry.ListType {
+		case ListTypeAllow:
+			allow[address] = struct{}{}
+		case ListTypeDeny:
+			deny[address] = struct{}{}
+		}
+	}
+
+	l.mu.Lock()
+	l.allow, l.deny = allow, deny
+	l.mu.Unlock()
+
+	l.logger.Info("Address lists loaded", zap.Int("allow", len(allow)), zap.Int("deny", len(deny)))
+	return nil
+}
+
+// Check 检查交易是否命中全局名单，拒绝名单优先
+func (l *AddressLists) Check(ctx *EvaluationContext) (FilterDecision, string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if address, ok := l.deny.match(callParticipants(ctx)...); ok {
+		return FilterDeny, address
+	}
+	from, to := txParties(ctx)
+	if address, ok := l.allow.match(from, to); ok {
+		return FilterAllow, address
+	}
+	return FilterNone, ""
+}
+
+// SubscribeUpdates 订阅名单变更，收到通知后从数据库重新加载
+func (l *AddressLists) SubscribeUpdates(ctx context.Context) {
+	pubsub := l.redis.Subscribe(ctx, AddressListsUpdateChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-ch:
+			l.logger.Info("Received address list update", zap.String("message", msg.Payload))
+			if err := l.Load(ctx); err != nil {
+				l.logger.Error("Failed to reload address lists", zap.Error(err))
+			}
+		}
+	}
+}
+
+// PublishUpdate 发布名单变更通知
+func (l *AddressLists) PublishUpdate(ctx context.Context) error {
+	return l.redis.Publish(ctx, AddressListsUpdateChannel, map[string]interface{}{
+		"action": "reload",
+	})
+}
+
+// DenyListRuleName 命中拒绝名单但没有规则命中时产生的风险事件类型
+const DenyListRuleName = "global-deny-list"
+
+// DenyListRule 返回拒绝名单的内置规则，保证命中拒绝名单的交易至少产生一条风险事件
+func DenyListRule(address string) *Rule {
+	return &Rule{
+		Metadata: RuleMetadata{
+			Name:        DenyListRuleName,
+			Description: fmt.Sprintf("交易涉及拒绝名单地址 %s", address),
+			Enabled:     true,
+		},
+		Config:  RuleConfig{Severity: "critical"},
+		Scoring: RuleScoring{BaseScore: 100},
+		Actions: []RuleAction{
+			{Type: "alert", Severity: "critical", Title: "拒绝名单地址活动", Message: "交易 {{tx_hash}} 涉及拒绝名单地址 " + address},
+			{Type: "log_risk_event", Severity: "critical"},
+		},
+	}
+}
+
+// EscalateRule 返回严重级别提升为 critical 的规则副本，用于命中拒绝名单时执行动作
+func EscalateRule(rule *Rule) *Rule {
+	escalated := *rule
+	escalated.Config.Severity = "critical"
+	return &escalated
+}
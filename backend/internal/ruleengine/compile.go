@@ -0,0 +1,546 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// evalFunc 编译后的表达式节点，elem 为量词中当前迭代的元素（量词外为 nil）
+type evalFunc func(ctx *EvaluationContext, elem interface{}) (interface{}, error)
+
+// Program 编译后的表达式
+// 语法树在编译时转换为闭包，字面量、命名列表等常量只计算一次；
+// Program 不持有可变状态，可在多个交易和 goroutine 之间复用
+type Program struct {
+	Expr Node
+	fn   evalFunc
+}
+
+// Compile 将语法树编译为可复用的 Program
+func (e *Evaluator) Compile(node Node) (*Program, error) {
+	fn, err := e.compile(node)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{Expr: node, fn: fn}, nil
+}
+
+// Eval 在给定上下文中执行表达式，结果必须为布尔值
+func (p *Program) Eval(ctx *EvaluationContext) (bool, error) {
+	value, err := p.fn(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	if m, ok := value.(*missingValue); ok {
+		return false, &MissingValueError{Name: m.name, Expr: p.Expr.String()}
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, nodeErrorf(p.Expr, "expression %s does not evaluate to a boolean", p.Expr)
+	}
+	return result, nil
+}
+
+// Value 在给定上下文中执行表达式并返回结果值（如 extract.custom 中的计算字段）
+func (p *Program) Value(ctx *EvaluationContext) (interface{}, error) {
+	value, err := p.fn(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := value.(*missingValue); ok {
+		return nil, &MissingValueError{Name: m.name, Expr: p.Expr.String()}
+	}
+	return value, nil
+}
+
+func (p *Program) String() string {
+	return p.Expr.String()
+}
+
+// compile 递归编译语法树节点
+func (e *Evaluator) compile(node Node) (evalFunc, error) {
+	switch n := node.(type) {
+	case *Literal:
+		value := n.Value
+		return func(*EvaluationContext, interface{}) (interface{}, error) {
+			return value, nil
+		}, nil
+
+	case *Ident:
+		name := n.Name
+		return func(ctx *EvaluationContext, _ interface{}) (interface{}, error) {
+			return normalizeValue(e.getValue(name, ctx)), nil
+		}, nil
+
+	case *UnaryExpr:
+		x, err := e.compile(n.X)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == "-" {
+			return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+				v, err := x(ctx, elem)
+				if err != nil || isMissing(v) {
+					return v, err
+				}
+				result, err := negate(v)
+				if err != nil {
+					return nil, nodeErrorf(n, "%v", err)
+				}
+				return result, nil
+			}, nil
+		}
+		operand := truthFunc(n.X, x)
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			v, m, err := operand(ctx, elem)
+			if err != nil {
+				return nil, err
+			}
+			if m != nil {
+				return m, nil
+			}
+			return !v, nil
+		}, nil
+
+	case *ListExpr:
+		items := make([]evalFunc, len(n.Elems))
+		for i, item := range n.Elems {
+			fn, err := e.compile(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = fn
+		}
+		if values, ok := constantList(n); ok {
+			return func(*EvaluationContext, interface{}) (interface{}, error) {
+				return values, nil
+			}, nil
+		}
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			values := make([]interface{}, len(items))
+			for i, item := range items {
+				value, err := item(ctx, elem)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = value
+			}
+			return values, nil
+		}, nil
+
+	case *ListRef:
+		return nil, nodeErrorf(n, "$%s is not resolved", n.Name)
+
+	case *FieldExpr:
+		var x evalFunc
+		if n.X != nil {
+			var err error
+			if x, err = e.compile(n.X); err != nil {
+				return nil, err
+			}
+		}
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			target := elem
+			if x != nil {
+				v, err := x(ctx, elem)
+				if err != nil {
+					return nil, err
+				}
+				target = v
+			}
+			if n.Name == "" || isMissing(target) {
+				return target, nil
+			}
+			fields, ok := target.(map[string]interface{})
+			if !ok {
+				return nil, nodeErrorf(n, "cannot access field .%s of %T", n.Name, target)
+			}
+			value, ok := fields[n.Name]
+			if !ok {
+				return nil, nodeErrorf(n, "unknown field .%s", n.Name)
+			}
+			return normalizeValue(value), nil
+		}, nil
+
+	case *IndexExpr:
+		x, err := e.compile(n.X)
+		if err != nil {
+			return nil, err
+		}
+		index, err := e.compile(n.Index)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			v, err := x(ctx, elem)
+			if err != nil {
+				return nil, err
+			}
+			i, err := index(ctx, elem)
+			if err != nil {
+				return nil, err
+			}
+			if m, ok := firstMissing(v, i); ok {
+				return m, nil
+			}
+			result, err := indexValue(v, i)
+			if err != nil {
+				return nil, nodeErrorf(n, "%v", err)
+			}
+			return result, nil
+		}, nil
+
+	case *QuantifierExpr:
+		return e.compileQuantifier(n)
+
+	case *PatternExpr:
+		pattern := n.Pattern
+		return func(ctx *EvaluationContext, _ interface{}) (interface{}, error) {
+			_, matched := pattern.Match(ctx)
+			return matched, nil
+		}, nil
+
+	case *WindowExpr:
+		return e.compileWindow(n)
+
+	case *CallExpr:
+		fn, ok := builtinFuncs[n.Func]
+		if !ok {
+			return nil, nodeErrorf(n, "unknown function %s", n.Func)
+		}
+		args := make([]evalFunc, len(n.Args))
+		for i, arg := range n.Args {
+			compiled, err := e.compile(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = compiled
+		}
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			values := make([]interface{}, len(args))
+			for i, arg := range args {
+				value, err := arg(ctx, elem)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = value
+			}
+			if m, ok := firstMissing(values...); ok && !fn.nullable {
+				return m, nil
+			}
+			result, err := fn.call(values)
+			if err != nil {
+				return nil, nodeErrorf(n, "%s: %v", n.Func, err)
+			}
+			return result, nil
+		}, nil
+
+	case *BinaryExpr:
+		return e.compileBinary(n)
+	}
+
+	return nil, fmt.Errorf("unsupported expression node %T", node)
+}
+
+// compileWindow 编译窗口条件，只读取 ObserveWindows 记录的次数；未计数时结果未知
+func (e *Evaluator) compileWindow(n *WindowExpr) (evalFunc, error) {
+	w := n.Window
+	return func(ctx *EvaluationContext, _ interface{}) (interface{}, error) {
+		result, ok := w.Count(ctx)
+		if ctx.Windows == nil || !ok {
+			return &missingValue{name: w.Type}, nil
+		}
+		if n.Count {
+			return normalizeValue(result.count), nil
+		}
+		return result.applicable, nil
+	}, nil
+}
+
+// compileBinary 编译二元表达式，AND/OR 保持短路求值
+func (e *Evaluator) compileBinary(n *BinaryExpr) (evalFunc, error) {
+	left, err := e.compile(n.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.compile(n.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case "AND", "OR":
+		// 三值逻辑：AND 遇到 false、OR 遇到 true 即可确定结果，否则任一侧未知则结果未知
+		l, r := truthFunc(n.Left, left), truthFunc(n.Right, right)
+		stopOn := n.Op == "OR"
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			lv, lm, err := l(ctx, elem)
+			if err != nil {
+				return nil, err
+			}
+			if lm == nil && lv == stopOn {
+				return lv, nil // 短路求值
+			}
+			rv, rm, err := r(ctx, elem)
+			if err != nil {
+				return nil, err
+			}
+			if rm == nil && rv == stopOn {
+				return rv, nil
+			}
+			if lm != nil {
+				return lm, nil
+			}
+			if rm != nil {
+				return rm, nil
+			}
+			return !stopOn, nil
+		}, nil
+
+	case "??":
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			v, err := left(ctx, elem)
+			if err != nil || !isMissing(v) {
+				return v, err
+			}
+			return right(ctx, elem)
+		}, nil
+	}
+
+	var apply func(l, r interface{}) (interface{}, error)
+	switch n.Op {
+	case "+", "-", "*", "/", "%":
+		apply = func(l, r interface{}) (interface{}, error) {
+			return arith(l, n.Op, r)
+		}
+	case ">", "<", ">=", "<=", "==", "!=":
+		apply = func(l, r interface{}) (interface{}, error) {
+			return e.compare(l, n.Op, r)
+		}
+	case "in", "not in":
+		// 常量字符串列表预先建立索引，避免每次线性扫描
+		if set, ok := newStringSet(n.Right); ok {
+			negated := n.Op == "not in"
+			apply = func(l, r interface{}) (interface{}, error) {
+				found, ok := set.contains(l)
+				if !ok {
+					found = listContains(r.([]interface{}), l)
+				}
+				return found != negated, nil
+			}
+			break
+		}
+		fallthrough
+	default:
+		apply = func(l, r interface{}) (interface{}, error) {
+			return matchOperator(l, n.Op, r)
+		}
+	}
+
+	return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+		l, err := left(ctx, elem)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(ctx, elem)
+		if err != nil {
+			return nil, err
+		}
+		// 与缺失值的算术和比较结果均为未知
+		if m, ok := firstMissing(l, r); ok {
+			return m, nil
+		}
+		result, err := apply(l, r)
+		if err != nil {
+			return nil, nodeErrorf(n, "%v", err)
+		}
+		return result, nil
+	}, nil
+}
+
+// compileQuantifier 编译量词与聚合，对集合中的每个元素执行 Body
+func (e *Evaluator) compileQuantifier(n *QuantifierExpr) (evalFunc, error) {
+	collection, err := e.compile(n.Collection)
+	if err != nil {
+		return nil, err
+	}
+	var body evalFunc
+	if n.Body != nil {
+		if body, err = e.compile(n.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	items := func(ctx *EvaluationContext, elem interface{}) ([]interface{}, *missingValue, error) {
+		value, err := collection(ctx, elem)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m, ok := value.(*missingValue); ok {
+			return nil, m, nil
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, nil, nodeErrorf(n.Collection, "%s: %s is not a collection", n.Func, n.Collection)
+		}
+		return items, nil, nil
+	}
+
+	switch n.Func {
+	case "any", "all", "none", "count":
+		var cond func(*EvaluationContext, interface{}) (bool, *missingValue, error)
+		if body != nil {
+			cond = truthFunc(n.Body, body)
+		}
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			list, m, err := items(ctx, elem)
+			if err != nil || m != nil {
+				return m, err
+			}
+			// any/none 为各元素的 OR，all 为 AND，按三值逻辑处理未知；count 只统计确定为 true 的元素
+			matched := 0
+			var unknown *missingValue
+			for _, item := range list {
+				ok := true
+				if cond != nil {
+					var im *missingValue
+					if ok, im, err = cond(ctx, item); err != nil {
+						return nil, err
+					}
+					if im != nil {
+						if unknown == nil {
+							unknown = im
+						}
+						continue
+					}
+				}
+				if ok {
+					matched++
+				}
+				// 短路求值
+				if ok && (n.Func == "any" || n.Func == "none") {
+					break
+				}
+				if !ok && n.Func == "all" {
+					return false, nil
+				}
+			}
+			switch n.Func {
+			case "any", "none":
+				if matched == 0 && unknown != nil {
+					return unknown, nil
+				}
+				return (matched > 0) == (n.Func == "any"), nil
+			case "all":
+				if unknown != nil {
+					return unknown, nil
+				}
+				return true, nil
+			}
+			return normalizeValue(matched), nil
+		}, nil
+
+	case "sum", "avg":
+		if body == nil {
+			return nil, nodeErrorf(n, "%s requires an expression", n.Func)
+		}
+		return func(ctx *EvaluationContext, elem interface{}) (interface{}, error) {
+			list, m, err := items(ctx, elem)
+			if err != nil || m != nil {
+				return m, err
+			}
+			var total interface{} = normalizeValue(0)
+			for _, item := range list {
+				v, err := body(ctx, item)
+				if err != nil || isMissing(v) {
+					return v, err
+				}
+				if total, err = arith(total, "+", v); err != nil {
+					return nil, nodeErrorf(n, "%s: %v", n.Func, err)
+				}
+			}
+			if n.Func == "avg" && len(list) > 0 {
+				return arith(total, "/", normalizeValue(len(list)))
+			}
+			return total, nil
+		}, nil
+	}
+
+	return nil, nodeErrorf(n, "unsupported quantifier %s", n.Func)
+}
+
+// truthFunc 包装编译后的节点，要求结果为布尔值或缺失（未知）
+func truthFunc(node Node, fn evalFunc) func(*EvaluationContext, interface{}) (bool, *missingValue, error) {
+	return func(ctx *EvaluationContext, elem interface{}) (bool, *missingValue, error) {
+		value, err := fn(ctx, elem)
+		if err != nil {
+			return false, nil, err
+		}
+		switch v := value.(type) {
+		case bool:
+			return v, nil, nil
+		case *missingValue:
+			return false, v, nil
+		}
+		return false, nil, nodeErrorf(node, "operand %s is not a boolean", node)
+	}
+}
+
+// constantList 列表元素全部为字面量时，返回预先计算好的值
+func constantList(list *ListExpr) ([]interface{}, bool) {
+	values := make([]interface{}, len(list.Elems))
+	for i, item := range list.Elems {
+		lit, ok := item.(*Literal)
+		if !ok {
+			return nil, false
+		}
+		values[i] = lit.Value
+	}
+	return values, true
+}
+
+// stringSet 常量字符串列表的索引，十六进制数据按小写存储
+type stringSet struct {
+	hex   map[string]struct{}
+	plain map[string]struct{}
+}
+
+// newStringSet 为全部由字符串字面量组成的列表建立索引
+func newStringSet(node Node) (*stringSet, bool) {
+	list, ok := node.(*ListExpr)
+	if !ok {
+		return nil, false
+	}
+	values, ok := constantList(list)
+	if !ok {
+		return nil, false
+	}
+
+	set := &stringSet{hex: make(map[string]struct{}), plain: make(map[string]struct{})}
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		if isHexString(s) {
+			set.hex[strings.ToLower(s)] = struct{}{}
+		}
+		set.plain[s] = struct{}{}
+	}
+	return set, true
+}
+
+// contains 查找字符串，value 不是字符串时返回 ok=false 由调用方回退到逐个比较
+func (s *stringSet) contains(value interface{}) (found bool, ok bool) {
+	str, isStr := value.(string)
+	if !isStr {
+		return false, false
+	}
+	if isHexString(str) {
+		_, found = s.hex[strings.ToLower(str)]
+		return found, true
+	}
+	_, found = s.plain[str]
+	return found, true
+}
@@ -1,6 +1,9 @@
 package ruleengine
 
 import (
+	"math/big"
+	"strings"
+
 	"github.com/haswell/bcscan/internal/models"
 )
 
@@ -10,17 +13,55 @@ type EvaluationContext struct {
 	Transaction *models.Transaction
 	Block       *models.Block
 	Events      []*models.Event
+	Message     *TransactionData // 原始消息，提供 models 中没有的字段（nonce、费用等）
 
 	// 运行时数据
+	CallStack    []CallFrame       // 完整调用栈（按调用顺序展开）
+	Logs         []EventLog        // 交易产生的事件日志
 	CallDepth    int               // 调用深度
 	CallCount    int               // 调用次数
 	CallTrace    []string          // 调用轨迹
-	StateChanges map[string]string // 状态变化
+	StateDiff    []AccountDiff     // 被修改账户的状态差异
+	StateChanges map[string]string // 状态变化：<地址>:<槽位> -> 修改后的值
 	GasUsed      uint64            // Gas 使用量
 	GasLimit     uint64            // Gas 限制
 
 	// 提取的数据（从 Extract 规则中提取）
 	ExtractedData map[string]interface{}
+
+	// Windows 跨交易窗口条件（repeated_call、velocity）的计数存储，为空时窗口条件结果未知
+	Windows *WindowStore
+
+	// collections 转换为表达式元素后的集合，按变量名缓存
+	collections map[string][]interface{}
+
+	// patterns 调用模式的匹配结果，按模式缓存
+	patterns map[*CallPattern]patternMatch
+
+	// windows 窗口条件在本交易上的计数，按条件缓存
+	windows map[*WindowCondition]windowCount
+}
+
+// CallFrame 调用帧
+type CallFrame struct {
+	Type     string `json:"type"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Gas      uint64 `json:"gas"`
+	GasUsed  uint64 `json:"gas_used"`
+	Input    string `json:"input"`
+	Output   string `json:"output"`
+	Error    string `json:"error"`
+	Depth    int    `json:"depth"`
+	Function string `json:"function"`
+}
+
+// EventLog 事件日志
+type EventLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
 }
 
 // NewEvaluationContext 创建新的求值上下文
@@ -29,9 +70,15 @@ func NewEvaluationContext(tx *models.Transaction, block *models.Block) *Evaluati
 		Transaction:   tx,
 		Block:         block,
 		Events:        make([]*models.Event, 0),
+		CallStack:     make([]CallFrame, 0),
+		Logs:          make([]EventLog, 0),
 		CallTrace:     make([]string, 0),
+		StateDiff:     make([]AccountDiff, 0),
 		StateChanges:  make(map[string]string),
 		ExtractedData: make(map[string]interface{}),
+		collections:   make(map[string][]interface{}),
+		patterns:      make(map[*CallPattern]patternMatch),
+		windows:       make(map[*WindowCondition]windowCount),
 	}
 }
 
@@ -40,8 +87,102 @@ func (ctx *EvaluationContext) SetExtractedValue(key string, value interface{}) {
 	ctx.ExtractedData[key] = value
 }
 
+// WithExtractedData 返回上下文的副本，ExtractedData 为原有数据加上 data，
+// 用于为每条规则建立独立的提取结果，其余数据与原上下文共享
+func (ctx *EvaluationContext) WithExtractedData(data map[string]interface{}) *EvaluationContext {
+	scoped := *ctx
+	scoped.ExtractedData = make(map[string]interface{}, len(ctx.ExtractedData)+len(data))
+	for key, value := range ctx.ExtractedData {
+		scoped.ExtractedData[key] = value
+	}
+	for key, value := range data {
+		scoped.ExtractedData[key] = value
+	}
+	return &scoped
+}
+
 // GetExtractedValue 获取提取的值
 func (ctx *EvaluationContext) GetExtractedValue(key string) (interface{}, bool) {
 	val, ok := ctx.ExtractedData[key]
 	return val, ok
 }
+
+// collection 返回表达式中可迭代的集合（call_stack、events），元素为字段名到值的映射
+func (ctx *EvaluationContext) collection(name string) ([]interface{}, bool) {
+	if items, ok := ctx.collections[name]; ok {
+		return items, true
+	}
+
+	var items []interface{}
+	switch name {
+	case "call_stack":
+		items = make([]interface{}, len(ctx.CallStack))
+		for i, frame := range ctx.CallStack {
+			items[i] = frame.fields()
+		}
+	case "events":
+		items = make([]interface{}, len(ctx.Logs))
+		for i, log := range ctx.Logs {
+			items[i] = log.fields()
+		}
+	case "state_diff":
+		items = make([]interface{}, len(ctx.StateDiff))
+		for i, diff := range ctx.StateDiff {
+			items[i] = diff.fields()
+		}
+	case "storage_changes":
+		for _, diff := range ctx.StateDiff {
+			for _, change := range diff.Storage {
+				items = append(items, change.fields(diff.Address))
+			}
+		}
+	default:
+		return nil, false
+	}
+
+	if ctx.collections == nil {
+		ctx.collections = make(map[string][]interface{})
+	}
+	ctx.collections[name] = items
+	return items, true
+}
+
+// fields 调用帧在表达式中可访问的字段，如 .type、.to、.value
+func (f CallFrame) fields() map[string]interface{} {
+	value, err := parseBigInt(f.Value)
+	if err != nil {
+		value = new(big.Int)
+	}
+	selector := ""
+	if len(f.Input) >= 10 {
+		selector = f.Input[:10]
+	}
+	return map[string]interface{}{
+		"type":     strings.ToUpper(f.Type),
+		"from":     f.From,
+		"to":       f.To,
+		"value":    value,
+		"gas":      f.Gas,
+		"gas_used": f.GasUsed,
+		"input":    f.Input,
+		"output":   f.Output,
+		"error":    f.Error,
+		"failed":   f.Error != "",
+		"depth":    f.Depth,
+		"function": f.Function,
+		"selector": selector,
+	}
+}
+
+// fields 事件日志在表达式中可访问的字段，如 .address、.topics[0]
+func (l EventLog) fields() map[string]interface{} {
+	topics := make([]interface{}, len(l.Topics))
+	for i, topic := range l.Topics {
+		topics[i] = topic
+	}
+	return map[string]interface{}{
+		"address": l.Address,
+		"topics":  topics,
+		"data":    l.Data,
+	}
+}
@@ -0,0 +1,78 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered 返回 1..n 每行一个数字的文本，replace 中的行替换为给定内容，值为空时删除该行
+func numbered(n int, replace map[int]string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = fmt.Sprint(i)
+		} else if line == "" {
+			continue
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// TestUnifiedDiff 期望结果与 GNU diff -u 的输出一致
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{name: "identical", from: numbered(3, nil), to: numbered(3, nil), want: ""},
+		{
+			name: "single change",
+			from: numbered(10, nil),
+			to:   numbered(10, map[int]string{5: "five"}),
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "deletion",
+			from: numbered(10, nil),
+			to:   numbered(10, map[int]string{3: ""}),
+			want: "@@ -1,6 +1,5 @@\n 1\n 2\n-3\n 4\n 5\n 6\n",
+		},
+		{
+			name: "append",
+			from: numbered(10, nil),
+			to:   numbered(11, nil),
+			want: "@@ -8,3 +8,4 @@\n 8\n 9\n 10\n+11\n",
+		},
+		{
+			// 两处修改之间不超过 2*diffContext 行时合并为一个 hunk
+			name: "changes merged",
+			from: numbered(20, nil),
+			to:   numbered(20, map[int]string{2: "two", 9: "nine"}),
+			want: "@@ -1,12 +1,12 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n 11\n 12\n",
+		},
+		{
+			name: "changes split",
+			from: numbered(20, nil),
+			to:   numbered(20, map[int]string{2: "two", 10: "ten"}),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n",
+		},
+		{name: "from empty", from: "", to: "x\ny\n", want: "@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{name: "to empty", from: "x\ny\n", to: "", want: "@@ -1,2 +0,0 @@\n-x\n-y\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want != "" {
+				want = "--- v1\n+++ v2\n" + want
+			}
+			if got := UnifiedDiff("v1", "v2", tt.from, tt.to); got != want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package ruleengine
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// benchRules 除内置规则外，补充几条覆盖量词、命名列表、正则与算术的典型规则
+var benchRules = []string{`
+metadata: {name: proxy-upgrade, enabled: true}
+triggers:
+  conditions:
+    - expression: any(call_stack, .selector in $admin_selectors && .to not in $trusted_contracts)
+scoring:
+  base_score: 70
+  factors:
+    - {condition: "count(call_stack, .type == \"DELEGATECALL\") > 2", score: 10}
+`, `
+metadata: {name: token-drain, enabled: true}
+triggers:
+  operator: OR
+  conditions:
+    - expression: count(events, .topics[0] == $TRANSFER) > 20
+    - expression: sum(call_stack, .value) > 100 ether
+scoring:
+  base_score: 60
+  factors:
+    - {condition: "count(events, .topics[0] == $APPROVAL) > 5", score: 15}
+    - {condition: "value > 2 * max(50 ether, 1e18)", score: 10}
+`, `
+metadata: {name: gas-anomaly, enabled: true}
+triggers:
+  conditions:
+    - expression: gas_used / gas_limit > 0.95 AND gas_price > 50 gwei
+    - expression: NOT reentrancy_detected OR call_depth > 3
+scoring:
+  base_score: 30
+  factors:
+    - {condition: "pct(gas_used, gas_limit) > 99", score: 20}
+`, `
+metadata: {name: failed-calls, enabled: true}
+triggers:
+  conditions:
+    - expression: any(call_stack, .failed && .error matches /revert|out of gas/)
+scoring:
+  base_score: 40
+  factors:
+    - {condition: "count(call_stack, .failed) > 3", score: 20}
+`}
+
+// loadBenchRules 加载内置规则目录与补充规则
+func loadBenchRules(b *testing.B) []*Rule {
+	b.Helper()
+
+	loader := NewRuleLoader(filepath.Join("..", "..", "rules", "builtin"), zap.NewNop())
+	if err := loader.LoadAll(); err != nil {
+		b.Fatal(err)
+	}
+	rules := loader.GetEnabledRules()
+
+	for _, text := range benchRules {
+		var rule Rule
+		if err := yaml.Unmarshal([]byte(text), &rule); err != nil {
+			b.Fatal(err)
+		}
+		loader.applyLists(&rule)
+		if diags := rule.Validate(); len(diags) > 0 {
+			b.Fatalf("invalid bench rule %s: %v", rule.Metadata.Name, diags)
+		}
+		rules = append(rules, &rule)
+	}
+	return rules
+}
+
+// newBenchContext 构造一笔典型的 DeFi 交易：24 层调用帧、40 条事件日志
+func newBenchContext() *EvaluationContext {
+	ctx := NewEvaluationContext(&models.Transaction{
+		TxHash:      "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",
+		BlockNumber: 19000000,
+		FromAddress: "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984",
+		ToAddress:   "0x7a250d5630b4cf539739df2c5dacb4c659f2488d",
+		Value:       "3000000000000000000",
+		GasPrice:    60000000000,
+		GasUsed:     1900000,
+		Status:      1,
+	}, &models.Block{BlockNumber: 19000000})
+
+	for i := 0; i < 24; i++ {
+		frame := CallFrame{
+			Type:    "CALL",
+			From:    fmt.Sprintf("0x%040x", i),
+			To:      fmt.Sprintf("0x%040x", i+1),
+			Value:   "1000000000000000000",
+			Gas:     100000,
+			GasUsed: 50000,
+			Input:   "0xa9059cbb000000000000000000000000",
+			Depth:   i % 6,
+		}
+		if i%8 == 0 {
+			frame.Type = "DELEGATECALL"
+		}
+		if i%10 == 9 {
+			frame.Error = "execution reverted"
+		}
+		ctx.CallStack = append(ctx.CallStack, frame)
+	}
+	for i := 0; i < 40; i++ {
+		ctx.Logs = append(ctx.Logs, EventLog{
+			Address: fmt.Sprintf("0x%040x", i%5),
+			Topics: []string{
+				"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+				fmt.Sprintf("0x%064x", i),
+			},
+			Data: "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+		})
+	}
+
+	ctx.CallDepth = 6
+	ctx.CallCount = len(ctx.CallStack)
+	ctx.GasUsed = 1900000
+	ctx.GasLimit = 2000000
+	return ctx
+}
+
+// runRuleSet 对整套规则执行一次数据提取、触发条件匹配与评分
+func runRuleSet(b *testing.B, rules []*Rule, ctx *EvaluationContext, compile func(*Rule) (*CompiledRule, error)) {
+	for _, rule := range rules {
+		compiled, err := compile(rule)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ruleCtx, err := compiled.Extract(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := compiled.Match(ruleCtx); err != nil {
+			b.Fatal(err)
+		}
+		for _, factor := range compiled.Factors {
+			if _, err := factor.Eval(ruleCtx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkRuleSet 对比每笔交易重新解析规则与复用编译缓存的吞吐
+func BenchmarkRuleSet(b *testing.B) {
+	rules := loadBenchRules(b)
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			runRuleSet(b, rules, newBenchContext(), CompileRule)
+		}
+		b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "tx/s")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		programs := NewProgramCache()
+		programs.Reset(rules)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			runRuleSet(b, rules, newBenchContext(), programs.Get)
+		}
+		b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "tx/s")
+	})
+
+	b.Run("cached-parallel", func(b *testing.B) {
+		programs := NewProgramCache()
+		programs.Reset(rules)
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				runRuleSet(b, rules, newBenchContext(), programs.Get)
+			}
+		})
+		b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "tx/s")
+	})
+}
+
+// BenchmarkCompileRule 单条规则的编译开销（热加载时每个规则版本只发生一次）
+func BenchmarkCompileRule(b *testing.B) {
+	rules := loadBenchRules(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CompileRule(rules[i%len(rules)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -53,8 +53,7 @@ func (e *Executor) executeAction(action RuleAction, rule *Rule, ctx *EvaluationC
 
 // executeAlert 执行告警动作
 func (e *Executor) executeAlert(action RuleAction, rule *Rule, ctx *EvaluationContext, score int) error {
-	message := e.replaceVariables(action.Message, ctx)
-	title := e.replaceVariables(action.Title, ctx)
+	title, message := e.RenderAlert(action, ctx)
 
 	e.logger.Info("ALERT",
 		zap.String("title", title),
@@ -64,6 +63,11 @@ func (e *Executor) executeAlert(action RuleAction, rule *Rule, ctx *EvaluationCo
 	return nil
 }
 
+// RenderAlert 渲染告警动作的标题与正文
+func (e *Executor) RenderAlert(action RuleAction, ctx *EvaluationContext) (title, message string) {
+	return e.replaceVariables(action.Title, ctx), e.replaceVariables(action.Message, ctx)
+}
+
 // logRiskEvent 记录风险事件到数据库
 func (e *Executor) logRiskEvent(action RuleAction, rule *Rule, ctx *EvaluationContext, score int) error {
 	if e.repo == nil {
@@ -78,6 +82,16 @@ func (e *Executor) logRiskEvent(action RuleAction, rule *Rule, ctx *EvaluationCo
 		contractAddr = ctx.Transaction.ToAddress
 	}
 
+	// 限流时被抑制的命中次数、被本规则抑制的规则随本次事件一起记录
+	var suppressed int64
+	if value, ok := ctx.GetExtractedValue(SuppressedCountKey); ok {
+		suppressed, _ = value.(int64)
+	}
+	var suppressedRules []string
+	if value, ok := ctx.GetExtractedValue(SuppressedRulesKey); ok {
+		suppressedRules, _ = value.([]string)
+	}
+
 	event := &models.RiskEvent{
 		EventType:       rule.Metadata.Name,
 		Severity:        rule.Config.Severity,
@@ -85,6 +99,10 @@ func (e *Executor) logRiskEvent(action RuleAction, rule *Rule, ctx *EvaluationCo
 		TxHash:          txHash,
 		Description:     rule.Metadata.Description,
 		Score:           score,
+		SuppressedCount: suppressed,
+		SuppressedRules: suppressedRules,
+		RuleRevision:    rule.Revision,
+		RuleHash:        rule.Hash,
 		DetectedAt:      time.Now(),
 	}
 
@@ -0,0 +1,222 @@
+package ruleengine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Node 表达式语法树节点
+type Node interface {
+	// Pos 返回节点在表达式中的起始列（从 1 开始）
+	Pos() int
+	String() string
+}
+
+// Ident 变量引用
+type Ident struct {
+	Name   string
+	Column int
+}
+
+// Literal 字面量（数字、字符串、布尔、正则）
+type Literal struct {
+	Value  interface{}
+	Column int
+}
+
+// UnaryExpr 一元表达式（NOT、取负）
+type UnaryExpr struct {
+	Op     string
+	X      Node
+	Column int
+}
+
+// BinaryExpr 二元表达式（逻辑、比较与算术运算）
+type BinaryExpr struct {
+	Op     string
+	Left   Node
+	Right  Node
+	Column int
+}
+
+// CallExpr 内置函数调用，如 max(a, b)
+type CallExpr struct {
+	Func   string
+	Args   []Node
+	Column int
+}
+
+// ListExpr 列表字面量，如 ["0xa9059cbb", "0x23b872dd"]
+type ListExpr struct {
+	Elems  []Node
+	Column int
+}
+
+// ListRef 命名列表或常量引用，如 $admin_selectors，求值前由 ResolveRefs 替换
+type ListRef struct {
+	Name   string
+	Column int
+}
+
+// FieldExpr 字段访问：X 为空时访问量词中的当前元素，如 .to；否则访问 X 的字段
+type FieldExpr struct {
+	X      Node
+	Name   string
+	Column int
+}
+
+// IndexExpr 下标访问，如 .topics[0]
+type IndexExpr struct {
+	X      Node
+	Index  Node
+	Column int
+}
+
+// QuantifierExpr 集合量词与聚合，如 any(call_stack, .type == "DELEGATECALL")
+// Body 对集合中的每个元素求值，count 可省略 Body
+type QuantifierExpr struct {
+	Func       string
+	Collection Node
+	Body       Node
+	Column     int
+}
+
+func (n *Ident) Pos() int      { return n.Column }
+func (n *Literal) Pos() int    { return n.Column }
+func (n *UnaryExpr) Pos() int  { return n.Column }
+func (n *BinaryExpr) Pos() int { return n.Column }
+func (n *CallExpr) Pos() int   { return n.Column }
+func (n *ListExpr) Pos() int   { return n.Column }
+func (n *ListRef) Pos() int    { return n.Column }
+func (n *FieldExpr) Pos() int  { return n.Column }
+func (n *IndexExpr) Pos() int  { return n.Column }
+
+func (n *QuantifierExpr) Pos() int { return n.Column }
+
+func (n *Ident) String() string { return n.Name }
+
+func (n *Literal) String() string {
+	switch v := n.Value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case *regexp.Regexp:
+		return "/" + v.String() + "/"
+	}
+	return fmt.Sprintf("%v", n.Value)
+}
+
+func (n *UnaryExpr) String() string {
+	return fmt.Sprintf("(%s %s)", n.Op, n.X)
+}
+
+func (n *BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", n.Left, n.Op, n.Right)
+}
+
+func (n *CallExpr) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s(%s)", n.Func, strings.Join(args, ", "))
+}
+
+func (n *ListExpr) String() string {
+	elems := make([]string, len(n.Elems))
+	for i, elem := range n.Elems {
+		elems[i] = elem.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+func (n *ListRef) String() string { return "$" + n.Name }
+
+func (n *FieldExpr) String() string {
+	if n.X == nil {
+		return "." + n.Name
+	}
+	return n.X.String() + "." + n.Name
+}
+
+func (n *IndexExpr) String() string {
+	return fmt.Sprintf("%s[%s]", n.X, n.Index)
+}
+
+func (n *QuantifierExpr) String() string {
+	if n.Body == nil {
+		return fmt.Sprintf("%s(%s)", n.Func, n.Collection)
+	}
+	return fmt.Sprintf("%s(%s, %s)", n.Func, n.Collection, n.Body)
+}
+
+// PatternExpr 调用模式条件（type: call_pattern），调用栈中存在匹配路径时为真
+type PatternExpr struct {
+	Pattern *CallPattern
+	Column  int
+}
+
+func (n *PatternExpr) Pos() int { return n.Column }
+
+func (n *PatternExpr) String() string {
+	return fmt.Sprintf("pattern(%q)", n.Pattern.Source)
+}
+
+// WindowExpr 跨交易窗口条件（type: repeated_call / velocity）
+// Count 为 false 时表示本交易是否计入窗口，为 true 时为窗口内的次数
+type WindowExpr struct {
+	Window *WindowCondition
+	Count  bool
+	Column int
+}
+
+func (n *WindowExpr) Pos() int { return n.Column }
+
+func (n *WindowExpr) String() string {
+	if n.Count {
+		return fmt.Sprintf("count(%s)", n.Window)
+	}
+	return n.Window.String()
+}
+
+// rewriteNode 后序遍历语法树，用 fn 的返回值替换每个节点
+func rewriteNode(node Node, fn func(Node) (Node, error)) (Node, error) {
+	var err error
+	rewrite := func(child Node) Node {
+		if child == nil || err != nil {
+			return child
+		}
+		var replaced Node
+		replaced, err = rewriteNode(child, fn)
+		return replaced
+	}
+
+	switch n := node.(type) {
+	case *UnaryExpr:
+		n.X = rewrite(n.X)
+	case *BinaryExpr:
+		n.Left = rewrite(n.Left)
+		n.Right = rewrite(n.Right)
+	case *CallExpr:
+		for i := range n.Args {
+			n.Args[i] = rewrite(n.Args[i])
+		}
+	case *ListExpr:
+		for i := range n.Elems {
+			n.Elems[i] = rewrite(n.Elems[i])
+		}
+	case *FieldExpr:
+		n.X = rewrite(n.X)
+	case *IndexExpr:
+		n.X = rewrite(n.X)
+		n.Index = rewrite(n.Index)
+	case *QuantifierExpr:
+		n.Collection = rewrite(n.Collection)
+		n.Body = rewrite(n.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fn(node)
+}
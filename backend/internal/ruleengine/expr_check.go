@@ -0,0 +1,348 @@
+package ruleengine
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// checker 表达式静态类型检查器
+type checker struct {
+	schema VariableSchema
+	elems  []*ExprType // 量词元素类型栈，.field 访问栈顶元素
+}
+
+// CheckExpression 按变量表检查语法树，返回表达式的结果类型
+// 未知变量、字段以及运算符与类型不匹配都会返回带列号的 ExprError
+func CheckExpression(node Node, schema VariableSchema) (*ExprType, error) {
+	c := &checker{schema: schema}
+	return c.check(node)
+}
+
+// CheckCondition 检查表达式且要求结果为布尔值
+func CheckCondition(node Node, schema VariableSchema) error {
+	t, err := CheckExpression(node, schema)
+	if err != nil {
+		return err
+	}
+	if !t.is(TypeBool) {
+		return nodeErrorf(node, "condition must evaluate to bool, got %s", t)
+	}
+	return nil
+}
+
+func (c *checker) check(node Node) (*ExprType, error) {
+	switch n := node.(type) {
+	case *Literal:
+		return literalType(n.Value), nil
+
+	case *Ident:
+		t, ok := c.schema[n.Name]
+		if !ok {
+			if suggestion := c.schema.suggest(n.Name); suggestion != "" {
+				return nil, nodeErrorf(n, "unknown variable %q, did you mean %q?", n.Name, suggestion)
+			}
+			return nil, nodeErrorf(n, "unknown variable %q", n.Name)
+		}
+		return t, nil
+
+	case *ListRef:
+		return nil, nodeErrorf(n, "$%s is not resolved", n.Name)
+
+	case *UnaryExpr:
+		x, err := c.check(n.X)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == "-" {
+			if !x.isNumeric() {
+				return nil, nodeErrorf(n, "operator '-' requires a number, got %s", x)
+			}
+			return x, nil
+		}
+		if !x.is(TypeBool) {
+			return nil, nodeErrorf(n.X, "operator NOT requires bool, got %s", x)
+		}
+		return boolType, nil
+
+	case *BinaryExpr:
+		return c.checkBinary(n)
+
+	case *ListExpr:
+		elem := anyType
+		for i, item := range n.Elems {
+			t, err := c.check(item)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				elem = t
+			} else if elem.Kind != t.Kind {
+				if !elem.comparableWith(t) {
+					return nil, nodeErrorf(item, "list elements must have the same type, got %s and %s", elem, t)
+				}
+				elem = anyType
+			}
+		}
+		return listOf(elem), nil
+
+	case *CallExpr:
+		if n.Func == "exists" {
+			if _, err := c.check(n.Args[0]); err != nil {
+				return nil, err
+			}
+			return boolType, nil
+		}
+		result := uint256Type
+		for _, arg := range n.Args {
+			t, err := c.check(arg)
+			if err != nil {
+				return nil, err
+			}
+			if !t.isNumeric() {
+				return nil, nodeErrorf(arg, "%s requires numeric arguments, got %s", n.Func, t)
+			}
+			if t.Kind != TypeUint256 {
+				result = numberType
+			}
+		}
+		if n.Func == "pct" {
+			return numberType, nil
+		}
+		return result, nil
+
+	case *FieldExpr:
+		var target *ExprType
+		if n.X == nil {
+			if len(c.elems) == 0 {
+				return nil, nodeErrorf(n, "'.%s' can only be used inside a quantifier", n.Name)
+			}
+			target = c.elems[len(c.elems)-1]
+		} else {
+			t, err := c.check(n.X)
+			if err != nil {
+				return nil, err
+			}
+			target = t
+		}
+		if n.Name == "" || target.Kind == TypeAny {
+			return target, nil
+		}
+		if target.Kind != TypeObject {
+			return nil, nodeErrorf(n, "cannot access field .%s of %s", n.Name, target)
+		}
+		field, ok := target.Fields[n.Name]
+		if !ok {
+			names := make([]string, 0, len(target.Fields))
+			for name := range target.Fields {
+				names = append(names, name)
+			}
+			if suggestion := closestName(n.Name, names); suggestion != "" {
+				return nil, nodeErrorf(n, "%s has no field .%s, did you mean .%s?", target, n.Name, suggestion)
+			}
+			return nil, nodeErrorf(n, "%s has no field .%s", target, n.Name)
+		}
+		return field, nil
+
+	case *IndexExpr:
+		x, err := c.check(n.X)
+		if err != nil {
+			return nil, err
+		}
+		index, err := c.check(n.Index)
+		if err != nil {
+			return nil, err
+		}
+		if !index.is(TypeUint256) {
+			return nil, nodeErrorf(n.Index, "index must be an integer, got %s", index)
+		}
+		if x.Kind == TypeAny {
+			return anyType, nil
+		}
+		if x.Kind != TypeList {
+			return nil, nodeErrorf(n, "cannot index %s", x)
+		}
+		return x.Elem, nil
+
+	case *QuantifierExpr:
+		return c.checkQuantifier(n)
+
+	case *PatternExpr:
+		return boolType, nil
+
+	case *WindowExpr:
+		if n.Count {
+			return uint256Type, nil
+		}
+		if n.Window.filter != nil {
+			if err := CheckCondition(n.Window.filter, c.schema); err != nil {
+				return nil, err
+			}
+		}
+		return boolType, nil
+	}
+
+	return nil, nodeErrorf(node, "unsupported expression %s", node)
+}
+
+func (c *checker) checkBinary(n *BinaryExpr) (*ExprType, error) {
+	left, err := c.check(n.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.check(n.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimPrefix(n.Op, "not ")
+	switch base {
+	case "AND", "OR":
+		if !left.is(TypeBool) {
+			return nil, nodeErrorf(n.Left, "operator %s requires bool operands, got %s", n.Op, left)
+		}
+		if !right.is(TypeBool) {
+			return nil, nodeErrorf(n.Right, "operator %s requires bool operands, got %s", n.Op, right)
+		}
+		return boolType, nil
+
+	case "??":
+		if !left.comparableWith(right) {
+			return nil, nodeErrorf(n, "default value of type %s does not match %s", right, left)
+		}
+		if left.Kind == TypeAny {
+			return right, nil
+		}
+		return left, nil
+
+	case "+", "-", "*", "/", "%":
+		if !left.isNumeric() || !right.isNumeric() {
+			return nil, nodeErrorf(n, "operator '%s' requires numbers, got %s and %s", n.Op, left, right)
+		}
+		if base != "/" && left.Kind == TypeUint256 && right.Kind == TypeUint256 {
+			return uint256Type, nil
+		}
+		return numberType, nil
+
+	case ">", "<", ">=", "<=":
+		if !left.isNumeric() || !right.isNumeric() {
+			return nil, nodeErrorf(n, "operator '%s' requires numbers, got %s and %s", n.Op, left, right)
+		}
+		return boolType, nil
+
+	case "==", "!=":
+		if !left.comparableWith(right) {
+			return nil, nodeErrorf(n, "cannot compare %s with %s", left, right)
+		}
+		return boolType, nil
+
+	case "in":
+		if right.Kind == TypeAny {
+			return boolType, nil
+		}
+		if right.Kind != TypeList {
+			return nil, nodeErrorf(n.Right, "right side of '%s' must be a list, got %s", n.Op, right)
+		}
+		if !left.comparableWith(right.Elem) {
+			return nil, nodeErrorf(n, "cannot look up %s in %s", left, right)
+		}
+		return boolType, nil
+
+	case "contains":
+		if left.Kind == TypeList {
+			if !left.Elem.comparableWith(right) {
+				return nil, nodeErrorf(n, "cannot look up %s in %s", right, left)
+			}
+			return boolType, nil
+		}
+		fallthrough
+
+	case "starts_with", "ends_with":
+		if !left.isStringLike() || !right.isStringLike() {
+			return nil, nodeErrorf(n, "operator '%s' requires strings, got %s and %s", n.Op, left, right)
+		}
+		return boolType, nil
+
+	case "matches":
+		if !left.isStringLike() {
+			return nil, nodeErrorf(n.Left, "left side of '%s' must be a string, got %s", n.Op, left)
+		}
+		if !right.is(TypeRegex) && !right.isStringLike() {
+			return nil, nodeErrorf(n.Right, "right side of '%s' must be a regex, got %s", n.Op, right)
+		}
+		return boolType, nil
+	}
+
+	return nil, nodeErrorf(n, "unsupported operator: %s", n.Op)
+}
+
+func (c *checker) checkQuantifier(n *QuantifierExpr) (*ExprType, error) {
+	collection, err := c.check(n.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	elem := anyType
+	switch collection.Kind {
+	case TypeList:
+		elem = collection.Elem
+	case TypeAny:
+	default:
+		return nil, nodeErrorf(n.Collection, "%s requires a collection, got %s", n.Func, collection)
+	}
+
+	body := boolType
+	if n.Body != nil {
+		c.elems = append(c.elems, elem)
+		body, err = c.check(n.Body)
+		c.elems = c.elems[:len(c.elems)-1]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch n.Func {
+	case "any", "all", "none":
+		if !body.is(TypeBool) {
+			return nil, nodeErrorf(n.Body, "%s expects a bool condition, got %s", n.Func, body)
+		}
+		return boolType, nil
+	case "count":
+		if !body.is(TypeBool) {
+			return nil, nodeErrorf(n.Body, "count expects a bool condition, got %s", body)
+		}
+		return uint256Type, nil
+	case "sum":
+		if !body.isNumeric() {
+			return nil, nodeErrorf(n.Body, "sum expects a numeric expression, got %s", body)
+		}
+		return body, nil
+	case "avg":
+		if !body.isNumeric() {
+			return nil, nodeErrorf(n.Body, "avg expects a numeric expression, got %s", body)
+		}
+		return numberType, nil
+	}
+	return nil, nodeErrorf(n, "unsupported quantifier %s", n.Func)
+}
+
+// literalType 字面量的静态类型
+func literalType(value interface{}) *ExprType {
+	switch v := value.(type) {
+	case bool:
+		return boolType
+	case *big.Int:
+		return uint256Type
+	case float64:
+		return numberType
+	case *regexp.Regexp:
+		return regexType
+	case string:
+		if isHexString(v) && len(v) == 42 {
+			return addressType
+		}
+		return stringType
+	default:
+		return anyType
+	}
+}
@@ -0,0 +1,111 @@
+package ruleengine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckExpression(t *testing.T) {
+	schema := DefaultSchema()
+	tests := []struct {
+		expr    string
+		want    string // 表达式类型
+		column  int
+		wantErr string
+	}{
+		{expr: `value > 1 ether`, want: "bool"},
+		{expr: `value + gas_used`, want: "uint256"},
+		{expr: `from_address`, want: "address"},
+		{expr: `count(call_stack, .type == "CALL")`, want: "uint256"},
+		{expr: `[1, 2]`, want: "list<uint256>"},
+		{expr: `gas_price ?? 0`, want: "uint256"},
+		{expr: `valeu > 1`, column: 1, wantErr: `unknown variable "valeu", did you mean "value"?`},
+		{expr: `value > "1"`, column: 7, wantErr: "operator '>' requires numbers, got uint256 and string"},
+		{expr: `value AND true`, column: 1, wantErr: "operator AND requires bool operands, got uint256"},
+		{expr: `NOT value`, column: 5, wantErr: "operator NOT requires bool, got uint256"},
+		{expr: `from_address + 1`, column: 14, wantErr: "operator '+' requires numbers, got address and uint256"},
+		{expr: `[1, "a"]`, column: 5, wantErr: "list elements must have the same type, got uint256 and string"},
+		{expr: `value matches /1/`, column: 1, wantErr: "left side of 'matches' must be a string, got uint256"},
+		{expr: `value in 5`, column: 10, wantErr: "right side of 'in' must be a list, got uint256"},
+		{expr: `any(call_stack, .too == "0x1")`, column: 17, wantErr: `call_frame has no field .too, did you mean .to?`},
+		{expr: `any(value, . > 1)`, column: 5, wantErr: "any requires a collection, got uint256"},
+		{expr: `sum(call_stack, .type)`, column: 17, wantErr: "sum expects a numeric expression, got string"},
+		{expr: `max(value, "a")`, column: 12, wantErr: "max requires numeric arguments, got string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := CheckExpression(node, schema)
+			if tt.wantErr != "" {
+				var exprErr *ExprError
+				if !errors.As(err, &exprErr) {
+					t.Fatalf("CheckExpression(%q) error = %v, want *ExprError", tt.expr, err)
+				}
+				if exprErr.Column != tt.column || exprErr.Msg != tt.wantErr {
+					t.Errorf("CheckExpression(%q) error = column %d: %s, want column %d: %s",
+						tt.expr, exprErr.Column, exprErr.Msg, tt.column, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("CheckExpression(%q) = %s, want %s", tt.expr, got, tt.want)
+			}
+		})
+	}
+
+	// 触发条件必须是 bool
+	node, _ := ParseExpression(`value + 1`)
+	if err := CheckCondition(node, schema); err == nil || err.Error() != "column 7: condition must evaluate to bool, got uint256" {
+		t.Errorf("CheckCondition() error = %v", err)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	rule := testRule("broken")
+	rule.Config.Severity = "urgent"
+	rule.Config.Hooks = []string{"contract_function_call", "block_mined"}
+	rule.Config.OnMissing = "ignore"
+	rule.Config.Suppresses = []string{"broken"}
+	rule.Variables = map[string]RuleVariable{
+		"avg_value": {Type: "uint256", Default: "abc"},
+		"label":     {Type: "text"},
+	}
+	rule.Triggers.Conditions = []RuleCondition{
+		{Expression: `value > avg_value`},
+		{Expression: `value > `},
+		{Type: "value", Operator: ">", Value: "1 ether"},
+		{Expression: `gas_price + 1`},
+	}
+	rule.Scoring.Factors = []ScoreFactor{{Condition: `valeu > 1`, Score: 10}}
+	rule.Actions = []RuleAction{{Type: "sms"}}
+
+	var got []string
+	for _, d := range rule.Validate() {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`config.severity: unknown severity "urgent", expected one of low, medium, high, critical`,
+		`config.hooks[1]: unknown hook "block_mined", expected one of contract_function_call`,
+		`actions[0].type: unknown action type "sms", expected one of alert, log_risk_event`,
+		`config.on_missing: on_missing must be "no_match" or "error"`,
+		`config.suppresses[0]: rule cannot suppress itself`,
+		`variables.avg_value.default: default value of type string does not match uint256`,
+		`variables.label.type: unknown type "text"`,
+		// 引用了默认值非法的变量的条件同样报错
+		`triggers.conditions[0].expression: default value of type string does not match uint256`,
+		`triggers.conditions[1].expression: unexpected end of expression`,
+		`triggers.conditions[3].expression: condition must evaluate to bool, got uint256`,
+		`scoring.factors[0].condition: unknown variable "valeu", did you mean "value"?`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,95 @@
+package ruleengine
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// builtinFunc 表达式内置函数
+type builtinFunc struct {
+	minArgs  int
+	maxArgs  int  // -1 表示不限
+	nullable bool // 参数缺失时仍然调用；其他函数遇到缺失参数直接返回缺失
+	call     func(args []interface{}) (interface{}, error)
+}
+
+// builtinFuncs 可在表达式中调用的内置函数
+var builtinFuncs = map[string]*builtinFunc{
+	// min(a, b, ...) 最小值
+	"min": {minArgs: 1, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		return pickNumber(args, -1)
+	}},
+	// max(a, b, ...) 最大值
+	"max": {minArgs: 1, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		return pickNumber(args, 1)
+	}},
+	// abs(x) 绝对值
+	"abs": {minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		cmp, ok := compareNumbers(args[0], big.NewInt(0))
+		if !ok {
+			return nil, fmt.Errorf("requires a numeric argument, got %T", args[0])
+		}
+		if cmp < 0 {
+			return negate(args[0])
+		}
+		return args[0], nil
+	}},
+	// exists(x) 变量是否存在，如 exists(avg_value) AND value > avg_value * 10
+	"exists": {minArgs: 1, maxArgs: 1, nullable: true, call: func(args []interface{}) (interface{}, error) {
+		return !isMissing(args[0]), nil
+	}},
+	// pct(part, whole) 百分比，如 pct(gas_used, gas_limit) > 95
+	"pct": {minArgs: 2, maxArgs: 2, call: func(args []interface{}) (interface{}, error) {
+		ratio, err := arith(args[0], "/", args[1])
+		if err != nil {
+			return nil, err
+		}
+		return arith(ratio, "*", big.NewInt(100))
+	}},
+}
+
+// quantifierFuncs 集合量词与聚合函数，值表示第二个参数（元素表达式）是否必填
+//
+//	any(coll, pred)   任一元素满足
+//	all(coll, pred)   全部元素满足（空集合为 true）
+//	none(coll, pred)  没有元素满足
+//	count(coll[, pred]) 满足条件的元素个数
+//	sum(coll, expr)   元素表达式之和
+//	avg(coll, expr)   元素表达式平均值（空集合为 0）
+var quantifierFuncs = map[string]bool{
+	"any":   true,
+	"all":   true,
+	"none":  true,
+	"count": false,
+	"sum":   true,
+	"avg":   true,
+}
+
+// checkArity 校验参数个数
+func (f *builtinFunc) checkArity(n int) error {
+	if n < f.minArgs {
+		return fmt.Errorf("expects at least %d argument(s), got %d", f.minArgs, n)
+	}
+	if f.maxArgs >= 0 && n > f.maxArgs {
+		return fmt.Errorf("expects at most %d argument(s), got %d", f.maxArgs, n)
+	}
+	return nil
+}
+
+// pickNumber 返回参数中的最小值（sign=-1）或最大值（sign=1）
+func pickNumber(args []interface{}, sign int) (interface{}, error) {
+	best := args[0]
+	for _, arg := range args[1:] {
+		cmp, ok := compareNumbers(arg, best)
+		if !ok {
+			return nil, fmt.Errorf("arguments must be numeric, got %T and %T", arg, best)
+		}
+		if cmp*sign > 0 {
+			best = arg
+		}
+	}
+	if _, ok := compareNumbers(best, best); !ok {
+		return nil, fmt.Errorf("arguments must be numeric, got %T", best)
+	}
+	return best, nil
+}
@@ -0,0 +1,84 @@
+package ruleengine
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateArithmetic(t *testing.T) {
+	ctx := newTestContext(map[string]interface{}{
+		"value":     big.NewInt(3000000000000000000),
+		"gas_used":  big.NewInt(96000),
+		"gas_limit": big.NewInt(100000),
+		"balance":   big.NewInt(-40),
+		"ratio":     0.25,
+	})
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`1 + 2 * 3 == 7`, true},
+		{`(1 + 2) * 3 == 9`, true},
+		{`10 - 4 - 3 == 3`, true}, // 左结合
+		{`7 % 4 == 3`, true},
+		{`-3 + 5 == 2`, true},
+		{`value / 3 == 1 ether`, true},
+		{`value / 2 == 1.5 ether`, true},
+		{`7 / 2 == 3.5`, true}, // 不能整除时得到小数
+		{`value * 2 > 5 ether`, true},
+		{`value - 4 ether < 0`, true},
+		{`ratio * 4 == 1`, true},
+		{`ratio + 1 > 1.2`, true},
+		{`gas_used * 100 / gas_limit > 95`, true},
+		{`min(3, 1, 2) == 1`, true},
+		{`max(value, 2 ether) == value`, true},
+		{`max(ratio, 1) == 1`, true},
+		{`abs(balance) == 40`, true},
+		{`abs(-2.5) == 2.5`, true},
+		{`abs(value) == value`, true},
+		{`pct(gas_used, gas_limit) == 96`, true},
+		{`pct(1, 3) > 33.3 AND pct(1, 3) < 33.4`, true},
+		{`pct(1, 8) == 12.5`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateArithmeticErrors(t *testing.T) {
+	ctx := newTestContext(map[string]interface{}{
+		"zero": big.NewInt(0),
+		"name": "vault",
+	})
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{`1 / zero > 0`, "division by zero"},
+		{`1 % zero > 0`, "modulo by zero"},
+		{`1.5 / 0 > 0`, "division by zero"},
+		{`name + 1 > 0`, "operator '+' requires numeric operands, got string and *big.Int"},
+		{`pct(1, zero) > 0`, "division by zero"},
+		{`abs(name) > 0`, "requires a numeric argument, got string"},
+		{`max(1, name) > 0`, "arguments must be numeric"},
+		{`min() > 0`, "expects at least 1 argument(s), got 0"},
+		{`abs(1, 2) > 0`, "expects at most 1 argument(s), got 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Evaluate(%q) error = %v, want %s", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokTrue
+	tokFalse
+	tokAnd
+	tokOr
+	tokNot
+	tokCompare // > < >= <= == != in contains starts_with ends_with matches
+	tokArith   // + - * / %
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokListRef  // $name 命名列表或常量引用
+	tokRegex    // /pattern/
+	tokField    // .name 元素字段访问
+	tokCoalesce // ?? 缺失值默认
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of expression"
+	case tokIdent:
+		return "identifier"
+	case tokNumber:
+		return "number"
+	case tokString:
+		return "string"
+	case tokTrue, tokFalse:
+		return "boolean"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	case tokCompare:
+		return "comparison operator"
+	case tokArith:
+		return "arithmetic operator"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokLBracket:
+		return "'['"
+	case tokRBracket:
+		return "']'"
+	case tokComma:
+		return "','"
+	case tokListRef:
+		return "list reference"
+	case tokRegex:
+		return "regex"
+	case tokField:
+		return "field"
+	case tokCoalesce:
+		return "'??'"
+	default:
+		return "unknown token"
+	}
+}
+
+// token 词法单元
+type token struct {
+	kind tokenKind
+	text string // 原始文本（字符串字面量为解码后的内容）
+	pos  int    // 起始列（从 1 开始）
+}
+
+// ExprError 表达式错误，携带出错的列号
+type ExprError struct {
+	Expr   string
+	Column int
+	Msg    string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// lexer 表达式词法分析器
+type lexer struct {
+	src  []rune
+	pos  int
+	prev token // 上一个词法单元，用于区分正则字面量与除号
+}
+
+// tokenize 将表达式切分为词法单元
+func tokenize(expression string) ([]token, error) {
+	lx := &lexer{src: []rune(expression)}
+	var tokens []token
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			return nil, err
+		}
+		lx.prev = tok
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (lx *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return &ExprError{Expr: string(lx.src), Column: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (lx *lexer) peekRune(offset int) rune {
+	if lx.pos+offset < len(lx.src) {
+		return lx.src[lx.pos+offset]
+	}
+	return 0
+}
+
+func (lx *lexer) next() (token, error) {
+	for lx.pos < len(lx.src) && isSpace(lx.src[lx.pos]) {
+		lx.pos++
+	}
+
+	start := lx.pos
+	if start >= len(lx.src) {
+		return token{kind: tokEOF, pos: start + 1}, nil
+	}
+
+	c := lx.src[start]
+	switch {
+	case isIdentStart(c):
+		for lx.pos < len(lx.src) && isIdentPart(lx.src[lx.pos]) {
+			lx.pos++
+		}
+		text := string(lx.src[start:lx.pos])
+		kind := keywordKind(text)
+		if kind == tokCompare {
+			text = strings.ToLower(text)
+		}
+		return token{kind: kind, text: text, pos: start + 1}, nil
+
+	case isDigit(c):
+		return lx.lexNumber()
+
+	case c == '"' || c == '\'':
+		return lx.lexString(c)
+
+	case c == '(':
+		lx.pos++
+		return token{kind: tokLParen, text: "(", pos: start + 1}, nil
+
+	case c == ')':
+		lx.pos++
+		return token{kind: tokRParen, text: ")", pos: start + 1}, nil
+
+	case c == ',':
+		lx.pos++
+		return token{kind: tokComma, text: ",", pos: start + 1}, nil
+
+	case c == '[':
+		lx.pos++
+		return token{kind: tokLBracket, text: "[", pos: start + 1}, nil
+
+	case c == ']':
+		lx.pos++
+		return token{kind: tokRBracket, text: "]", pos: start + 1}, nil
+
+	case c == '$':
+		lx.pos++
+		for lx.pos < len(lx.src) && isIdentPart(lx.src[lx.pos]) {
+			lx.pos++
+		}
+		if lx.pos == start+1 {
+			return token{}, lx.errorf(start, "expected list name after '$'")
+		}
+		return token{kind: tokListRef, text: string(lx.src[start+1 : lx.pos]), pos: start + 1}, nil
+
+	case c == '/' && lx.prev.kind == tokCompare && lx.prev.text == "matches":
+		return lx.lexRegex()
+
+	case c == '.':
+		// .name 访问元素字段；单独的 . 表示当前元素本身
+		lx.pos++
+		for lx.pos < len(lx.src) && isIdentPart(lx.src[lx.pos]) && lx.src[lx.pos] != '.' {
+			lx.pos++
+		}
+		return token{kind: tokField, text: string(lx.src[start+1 : lx.pos]), pos: start + 1}, nil
+	}
+
+	// 双字符运算符
+	two := string([]rune{c, lx.peekRune(1)})
+	switch two {
+	case ">=", "<=", "==", "!=":
+		lx.pos += 2
+		return token{kind: tokCompare, text: two, pos: start + 1}, nil
+	case "&&":
+		lx.pos += 2
+		return token{kind: tokAnd, text: two, pos: start + 1}, nil
+	case "||":
+		lx.pos += 2
+		return token{kind: tokOr, text: two, pos: start + 1}, nil
+	case "??":
+		lx.pos += 2
+		return token{kind: tokCoalesce, text: two, pos: start + 1}, nil
+	}
+
+	// 单字符运算符
+	switch c {
+	case '>', '<':
+		lx.pos++
+		return token{kind: tokCompare, text: string(c), pos: start + 1}, nil
+	case '!':
+		lx.pos++
+		return token{kind: tokNot, text: "!", pos: start + 1}, nil
+	case '+', '-', '*', '/', '%':
+		lx.pos++
+		return token{kind: tokArith, text: string(c), pos: start + 1}, nil
+	case '=':
+		return token{}, lx.errorf(start, "unexpected '=', did you mean '=='?")
+	}
+
+	return token{}, lx.errorf(start, "unexpected character %q", c)
+}
+
+// lexNumber 解析整数、小数以及科学计数法（如 1e18、2.5e-3）
+func (lx *lexer) lexNumber() (token, error) {
+	start := lx.pos
+	lx.skipDigits()
+	if lx.peekRune(0) == '.' && isDigit(lx.peekRune(1)) {
+		lx.pos++
+		lx.skipDigits()
+	}
+	if c := lx.peekRune(0); c == 'e' || c == 'E' {
+		offset := 1
+		if sign := lx.peekRune(1); sign == '+' || sign == '-' {
+			offset = 2
+		}
+		if isDigit(lx.peekRune(offset)) {
+			lx.pos += offset
+			lx.skipDigits()
+		}
+	}
+	if lx.pos < len(lx.src) && isIdentStart(lx.src[lx.pos]) {
+		return token{}, lx.errorf(lx.pos, "invalid character %q in number", lx.src[lx.pos])
+	}
+	return token{kind: tokNumber, text: string(lx.src[start:lx.pos]), pos: start + 1}, nil
+}
+
+func (lx *lexer) skipDigits() {
+	for lx.pos < len(lx.src) && isDigit(lx.src[lx.pos]) {
+		lx.pos++
+	}
+}
+
+// lexString 解析带引号的字符串字面量，支持 \" \' \\ 转义
+func (lx *lexer) lexString(quote rune) (token, error) {
+	start := lx.pos
+	lx.pos++
+
+	var sb strings.Builder
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case c == quote:
+			lx.pos++
+			return token{kind: tokString, text: sb.String(), pos: start + 1}, nil
+		case c == '\\' && lx.pos+1 < len(lx.src):
+			lx.pos++
+			switch esc := lx.src[lx.pos]; esc {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				sb.WriteRune(esc)
+			}
+		default:
+			sb.WriteRune(c)
+		}
+		lx.pos++
+	}
+
+	return token{}, lx.errorf(start, "unterminated string literal")
+}
+
+// lexRegex 解析 /pattern/ 形式的正则字面量，\/ 表示字面斜杠
+func (lx *lexer) lexRegex() (token, error) {
+	start := lx.pos
+	lx.pos++
+
+	var sb strings.Builder
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case c == '/':
+			lx.pos++
+			return token{kind: tokRegex, text: sb.String(), pos: start + 1}, nil
+		case c == '\\' && lx.peekRune(1) == '/':
+			sb.WriteRune('/')
+			lx.pos++
+		default:
+			sb.WriteRune(c)
+		}
+		lx.pos++
+	}
+
+	return token{}, lx.errorf(start, "unterminated regex literal")
+}
+
+// keywordKind 识别关键字（大小写不敏感）
+func keywordKind(word string) tokenKind {
+	switch strings.ToUpper(word) {
+	case "AND":
+		return tokAnd
+	case "OR":
+		return tokOr
+	case "NOT":
+		return tokNot
+	case "TRUE":
+		return tokTrue
+	case "FALSE":
+		return tokFalse
+	case "IN", "CONTAINS", "STARTS_WITH", "ENDS_WITH", "MATCHES":
+		return tokCompare
+	}
+	return tokIdent
+}
+
+// isWordOperator 判断是否为可加 NOT 前缀的单词运算符
+func isWordOperator(op string) bool {
+	switch op {
+	case "in", "contains", "starts_with", "ends_with", "matches":
+		return true
+	}
+	return false
+}
+
+// isCompareOperator 判断是否为符号比较运算符
+func isCompareOperator(op string) bool {
+	switch op {
+	case ">", "<", ">=", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.'
+}
@@ -0,0 +1,154 @@
+package ruleengine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// matchOperator 执行集合与字符串匹配运算：
+// in、contains、starts_with、ends_with、matches 及其 "not " 前缀形式
+func matchOperator(left interface{}, operator string, right interface{}) (bool, error) {
+	base := strings.TrimPrefix(operator, "not ")
+	negated := base != operator
+
+	var result bool
+	switch base {
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("right side of '%s' must be a list, got %T", operator, right)
+		}
+		result = listContains(list, left)
+
+	case "contains":
+		if list, ok := left.([]interface{}); ok {
+			result = listContains(list, right)
+			break
+		}
+		haystack, needle, err := stringOperands(operator, left, right)
+		if err != nil {
+			return false, err
+		}
+		result = strings.Contains(haystack, needle)
+
+	case "starts_with":
+		haystack, needle, err := stringOperands(operator, left, right)
+		if err != nil {
+			return false, err
+		}
+		result = strings.HasPrefix(haystack, needle)
+
+	case "ends_with":
+		haystack, needle, err := stringOperands(operator, left, right)
+		if err != nil {
+			return false, err
+		}
+		result = strings.HasSuffix(haystack, needle)
+
+	case "matches":
+		s, ok := left.(string)
+		if !ok {
+			return false, fmt.Errorf("left side of '%s' must be a string, got %T", operator, left)
+		}
+		re, ok := right.(*regexp.Regexp)
+		if !ok {
+			pattern, isStr := right.(string)
+			if !isStr {
+				return false, fmt.Errorf("right side of '%s' must be a regex, got %T", operator, right)
+			}
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, fmt.Errorf("invalid regex: %v", err)
+			}
+			re = compiled
+		}
+		if isHexString(s) {
+			re = foldRegex(re)
+		}
+		result = re.MatchString(s)
+
+	default:
+		return false, fmt.Errorf("unsupported operator: %s", operator)
+	}
+
+	if negated {
+		return !result, nil
+	}
+	return result, nil
+}
+
+// foldedRegexes 十六进制数据使用的不区分大小写正则缓存
+var foldedRegexes sync.Map
+
+// foldRegex 返回不区分大小写的正则，十六进制地址的匹配不受大小写影响
+func foldRegex(re *regexp.Regexp) *regexp.Regexp {
+	if cached, ok := foldedRegexes.Load(re.String()); ok {
+		return cached.(*regexp.Regexp)
+	}
+	folded, err := regexp.Compile("(?i)" + re.String())
+	if err != nil {
+		return re
+	}
+	foldedRegexes.Store(re.String(), folded)
+	return folded
+}
+
+// stringOperands 取出字符串运算的两侧操作数；左侧为十六进制数据时统一转为小写
+func stringOperands(operator string, left, right interface{}) (string, string, error) {
+	haystack, ok := left.(string)
+	if !ok {
+		return "", "", fmt.Errorf("left side of '%s' must be a string, got %T", operator, left)
+	}
+	needle, ok := right.(string)
+	if !ok {
+		return "", "", fmt.Errorf("right side of '%s' must be a string, got %T", operator, right)
+	}
+	if isHexString(haystack) {
+		return strings.ToLower(haystack), strings.ToLower(needle), nil
+	}
+	return haystack, needle, nil
+}
+
+// listContains 判断列表中是否存在与 value 相等的元素
+func listContains(list []interface{}, value interface{}) bool {
+	for _, elem := range list {
+		if valuesEqual(elem, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesEqual 判断两个值是否相等
+// 数值按大小比较；十六进制字符串（地址、选择器、哈希）不区分大小写
+func valuesEqual(left, right interface{}) bool {
+	if cmp, ok := compareNumbers(left, right); ok {
+		return cmp == 0
+	}
+
+	leftStr, leftIsStr := left.(string)
+	rightStr, rightIsStr := right.(string)
+	if leftIsStr && rightIsStr {
+		if isHexString(leftStr) && isHexString(rightStr) {
+			return strings.EqualFold(leftStr, rightStr)
+		}
+		return leftStr == rightStr
+	}
+
+	return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
+}
+
+// isHexString 判断字符串是否为 0x 前缀的十六进制数据
+func isHexString(s string) bool {
+	if len(s) < 3 || s[0] != '0' || (s[1] != 'x' && s[1] != 'X') {
+		return false
+	}
+	for _, c := range s[2:] {
+		if !isDigit(c) && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
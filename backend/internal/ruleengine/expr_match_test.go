@@ -0,0 +1,149 @@
+package ruleengine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestEvaluateMatch(t *testing.T) {
+	ctx := newTestContext(map[string]interface{}{
+		"selector": "0xA9059CBB",
+		"to":       "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+		"input":    "0xa9059cbb000000000000000000000000BBBB",
+		"label":    "Uniswap V3: Router",
+		"tags":     []interface{}{"dex", "router"},
+		"amounts":  []interface{}{1, 2, 3},
+	})
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`selector in ["0xa9059cbb", "0x095ea7b3"]`, true}, // 十六进制不区分大小写
+		{`selector not in ["0x095ea7b3"]`, true},
+		{`to in ["0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"]`, true},
+		{`2 in amounts`, true},
+		{`4 in amounts`, false},
+		{`2.0 in [1, 2]`, true}, // 数值按大小比较
+		{`tags contains "router"`, true},
+		{`tags not contains "lending"`, true},
+		{`input contains "bbbb"`, true},
+		{`input starts_with "0xA9059CBB"`, true},
+		{`label contains "router"`, false}, // 普通字符串区分大小写
+		{`label starts_with "Uniswap"`, true},
+		{`label ends_with "Router"`, true},
+		{`label not ends_with "Router"`, false},
+		{`label matches /^Uniswap V\d+/`, true},
+		{`label matches "V2"`, false},
+		{`to matches /^0xaaaa/`, true}, // 十六进制数据的正则不区分大小写
+		{`label not matches /sushi/`, true},
+		{`selector in [] OR true`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMatchErrors(t *testing.T) {
+	ctx := newTestContext(map[string]interface{}{"label": "vault", "n": 5})
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{`label in "vault"`, "right side of 'in' must be a list, got string"},
+		{`n starts_with "5"`, "left side of 'starts_with' must be a string"},
+		{`label ends_with 5`, "right side of 'ends_with' must be a string"},
+		{`n matches /5/`, "left side of 'matches' must be a string"},
+		{`label matches "("`, "invalid regex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Evaluate(%q) error = %v, want %s", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamedLists(t *testing.T) {
+	dir := writeRulesDir(t, map[string]string{
+		"lists/common.yaml": `lists:
+  admin_selectors: ["0xf2fde38b", "0x3659cfe6"]
+  trusted: ["0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"]
+constants:
+  TRANSFER: "0xddf252ad"
+`,
+		"admin.yaml": `metadata:
+  name: "admin"
+  enabled: true
+config:
+  hooks: [contract_function_call]
+lists:
+  trusted: ["0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"] # 规则内定义的同名列表优先
+triggers:
+  operator: "AND"
+  conditions:
+    - expression: 'function_selector in $admin_selectors'
+    - expression: 'to_address not in $trusted'
+    - expression: 'any(events, .topics[0] == $TRANSFER)'
+`,
+	})
+	loader := NewRuleLoader(dir, zap.NewNop())
+	if err := loader.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := loader.GetRule("admin")
+	if !ok {
+		t.Fatal("rule admin not loaded")
+	}
+	if got, want := rule.Lists["trusted"], []string{"0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trusted = %v, want rule-local %v", got, want)
+	}
+	compiled, err := CompileRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		to       string
+		want     bool
+	}{
+		{"admin call to untrusted contract", "0xF2FDE38B", addrA, true},
+		{"admin call to trusted contract", "0x3659cfe6", addrB, false},
+		{"other function", "0xa9059cbb", addrA, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := txContext("0x1")
+			ctx.Transaction.ToAddress = tt.to
+			ctx.Transaction.InputData = tt.selector + "00"
+			ctx.Message = &TransactionData{FunctionSelector: tt.selector}
+			ctx.Logs = []EventLog{{Topics: []string{"0xDDF252AD"}}}
+			matched, err := compiled.Match(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tt.want {
+				t.Errorf("Match() = %v, want %v", matched, tt.want)
+			}
+		})
+	}
+
+	_, err = testRule("bad").ParseExpression(`to_address in $missing`)
+	if want := "column 15: unknown list or constant $missing"; err == nil || err.Error() != want {
+		t.Errorf("ParseExpression() error = %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,46 @@
+package ruleengine
+
+import "fmt"
+
+// missingValue 缺失值：变量在当前交易中不存在（如未提取到的数据、缺少区块信息）
+// 缺失值在运算中向上传播：算术结果缺失，比较结果为"未知"，
+// 逻辑运算按三值逻辑处理（false AND 未知 = false，true OR 未知 = true）
+type missingValue struct {
+	name string // 最先缺失的变量名
+}
+
+func (m *missingValue) String() string {
+	return "<missing " + m.name + ">"
+}
+
+// isMissing 判断值是否缺失
+func isMissing(value interface{}) bool {
+	_, ok := value.(*missingValue)
+	return ok
+}
+
+// firstMissing 返回参数中第一个缺失值
+func firstMissing(values ...interface{}) (*missingValue, bool) {
+	for _, value := range values {
+		if m, ok := value.(*missingValue); ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// MissingValueError 表达式因变量缺失而无法得出结果
+type MissingValueError struct {
+	Name string
+	Expr string
+}
+
+func (e *MissingValueError) Error() string {
+	return fmt.Sprintf("variable %s is missing in %s", e.Name, e.Expr)
+}
+
+// 缺失变量处理策略（RuleConfig.OnMissing）
+const (
+	OnMissingNoMatch = "no_match" // 结果未知时视为不匹配（默认）
+	OnMissingError   = "error"    // 结果未知时报错
+)
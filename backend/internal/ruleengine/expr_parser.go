@@ -0,0 +1,640 @@
+package ruleengine
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// 运算符优先级（从低到高）:
+//   OR  ||
+//   AND &&
+//   NOT !
+//   > < >= <= == != in / not in / contains / starts_with / ends_with / matches
+//   + -
+//   * / %
+//   -x（取负）
+//   x[i]、x.field（后缀访问）
+//   字面量、变量、函数调用、量词、括号
+
+// parser 递归下降语法分析器
+type parser struct {
+	expr       string
+	tokens     []token
+	pos        int
+	quantDepth int // 当前所在量词的嵌套层数，.field 只能出现在量词内
+}
+
+// ParseExpression 将表达式解析为语法树
+func ParseExpression(expression string) (Node, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{expr: expression, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s %q", tok.kind, tok.text)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &ExprError{Expr: p.expr, Column: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		op := p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "OR", Left: left, Right: right, Column: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		op := p.advance()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "AND", Left: left, Right: right, Column: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind == tokNot {
+		op := p.advance()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", X: x, Column: op.pos}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseCoalesce()
+	if err != nil {
+		return nil, err
+	}
+	// 单词运算符支持 NOT 前缀，如 "not in"、"not contains"
+	negated := false
+	if p.peek().kind == tokNot && p.peekAt(1).kind == tokCompare && isWordOperator(p.peekAt(1).text) {
+		p.advance()
+		negated = true
+	}
+
+	if p.peek().kind == tokCompare {
+		op := p.advance()
+		right, err := p.parseCoalesce()
+		if err != nil {
+			return nil, err
+		}
+		opText := op.text
+		if negated {
+			opText = "not " + opText
+		}
+		left = &BinaryExpr{Op: opText, Left: left, Right: right, Column: op.pos}
+
+		if op.text == "matches" {
+			if err := p.compileRegexOperand(left.(*BinaryExpr)); err != nil {
+				return nil, err
+			}
+		}
+
+		if next := p.peek(); next.kind == tokCompare {
+			return nil, p.errorf(next, "comparison operators cannot be chained, use AND")
+		}
+	}
+	return left, nil
+}
+
+// parseCoalesce 解析 ??：左侧缺失时取右侧的值，优先级低于算术、高于比较，
+// 如 amount ?? 0 > 100 等价于 (amount ?? 0) > 100
+func (p *parser) parseCoalesce() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokCoalesce {
+		op := p.advance()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "??", Left: left, Right: right, Column: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for next := p.peek(); next.kind == tokArith && (next.text == "+" || next.text == "-"); next = p.peek() {
+		op := p.advance()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op.text, Left: left, Right: right, Column: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for next := p.peek(); next.kind == tokArith && (next.text == "*" || next.text == "/" || next.text == "%"); next = p.peek() {
+		op := p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op.text, Left: left, Right: right, Column: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if next := p.peek(); next.kind == tokArith && next.text == "-" {
+		op := p.advance()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "-", X: x, Column: op.pos}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix 解析下标与字段访问后缀，如 .topics[0]、call_stack[0].to
+func (p *parser) parsePostfix() (Node, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch next := p.peek(); next.kind {
+		case tokLBracket:
+			p.advance()
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.advance(); closing.kind != tokRBracket {
+				return nil, p.errorf(closing, "expected ']' to close index at column %d, got %s", next.pos, closing.kind)
+			}
+			node = &IndexExpr{X: node, Index: index, Column: next.pos}
+		case tokField:
+			p.advance()
+			if next.text == "" {
+				return nil, p.errorf(next, "expected field name after '.'")
+			}
+			node = &FieldExpr{X: node, Name: next.text, Column: next.pos}
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			return p.parseCall(tok)
+		}
+		return &Ident{Name: tok.text, Column: tok.pos}, nil
+	case tokNumber:
+		// 数字后可跟金额单位，如 10 ether、5 gwei
+		unit := ""
+		if next := p.peek(); next.kind == tokIdent && isEtherUnit(next.text) {
+			unit = p.advance().text
+		}
+		value, err := parseNumberLiteral(tok.text, unit)
+		if err != nil {
+			return nil, p.errorf(tok, "%v", err)
+		}
+		return &Literal{Value: value, Column: tok.pos}, nil
+	case tokString:
+		return &Literal{Value: tok.text, Column: tok.pos}, nil
+	case tokTrue:
+		return &Literal{Value: true, Column: tok.pos}, nil
+	case tokFalse:
+		return &Literal{Value: false, Column: tok.pos}, nil
+	case tokRegex:
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid regex: %v", err)
+		}
+		return &Literal{Value: re, Column: tok.pos}, nil
+	case tokListRef:
+		return &ListRef{Name: tok.text, Column: tok.pos}, nil
+	case tokField:
+		if p.quantDepth == 0 {
+			return nil, p.errorf(tok, "'.%s' can only be used inside a quantifier such as any(call_stack, ...)", tok.text)
+		}
+		return &FieldExpr{Name: tok.text, Column: tok.pos}, nil
+	case tokLBracket:
+		return p.parseList(tok)
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "expected ')' to close '(' at column %d, got %s", tok.pos, closing.kind)
+		}
+		return node, nil
+	case tokEOF:
+		return nil, p.errorf(tok, "unexpected end of expression")
+	default:
+		return nil, p.errorf(tok, "unexpected %s %q", tok.kind, tok.text)
+	}
+}
+
+// parseList 解析列表字面量
+func (p *parser) parseList(open token) (Node, error) {
+	list := &ListExpr{Column: open.pos}
+	if p.peek().kind != tokRBracket {
+		for {
+			elem, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			list.Elems = append(list.Elems, elem)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.advance()
+		}
+	}
+	if closing := p.advance(); closing.kind != tokRBracket {
+		return nil, p.errorf(closing, "expected ']' to close '[' at column %d, got %s", open.pos, closing.kind)
+	}
+	return list, nil
+}
+
+// compileRegexOperand 在解析阶段把 matches 右侧的字符串字面量编译为正则
+func (p *parser) compileRegexOperand(expr *BinaryExpr) error {
+	lit, ok := expr.Right.(*Literal)
+	if !ok {
+		return nil
+	}
+	if err := compileRegexLiteral(lit); err != nil {
+		return &ExprError{Expr: p.expr, Column: lit.Column, Msg: err.Error()}
+	}
+	return nil
+}
+
+// compileRegexLiteral 将字符串字面量编译为正则，非字符串字面量保持不变
+func compileRegexLiteral(lit *Literal) error {
+	pattern, ok := lit.Value.(string)
+	if !ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %v", err)
+	}
+	lit.Value = re
+	return nil
+}
+
+// parseQuantifier 解析量词与聚合：func(collection[, body])
+// body 中可用 .field 访问当前元素
+func (p *parser) parseQuantifier(name token) (Node, error) {
+	p.advance() // (
+
+	collection, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	quant := &QuantifierExpr{Func: name.text, Collection: collection, Column: name.pos}
+
+	if p.peek().kind == tokComma {
+		p.advance()
+		p.quantDepth++
+		body, err := p.parseOr()
+		p.quantDepth--
+		if err != nil {
+			return nil, err
+		}
+		quant.Body = body
+	}
+
+	if closing := p.advance(); closing.kind != tokRParen {
+		return nil, p.errorf(closing, "expected ')' to close call to %s, got %s", name.text, closing.kind)
+	}
+	if quant.Body == nil && quantifierFuncs[name.text] {
+		return nil, p.errorf(name, "%s: expects a collection and an expression, e.g. %s(call_stack, .depth > 3)", name.text, name.text)
+	}
+	return quant, nil
+}
+
+// parseCall 解析函数调用，函数名与参数个数在解析阶段校验
+func (p *parser) parseCall(name token) (Node, error) {
+	if _, ok := quantifierFuncs[name.text]; ok {
+		return p.parseQuantifier(name)
+	}
+
+	fn, ok := builtinFuncs[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %q", name.text)
+	}
+	p.advance() // (
+
+	call := &CallExpr{Func: name.text, Column: name.pos}
+	if p.peek().kind != tokRParen {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.advance()
+		}
+	}
+	if closing := p.advance(); closing.kind != tokRParen {
+		return nil, p.errorf(closing, "expected ')' to close call to %s, got %s", name.text, closing.kind)
+	}
+
+	if err := fn.checkArity(len(call.Args)); err != nil {
+		return nil, p.errorf(name, "%s: %v", name.text, err)
+	}
+	return call, nil
+}
+
+// ParseExpression 解析规则中的表达式，并把 $name 替换为规则可见的命名列表或常量
+// 空表达式视为恒真
+func (r *Rule) ParseExpression(expression string) (Node, error) {
+	if strings.TrimSpace(expression) == "" {
+		return &Literal{Value: true, Column: 1}, nil
+	}
+
+	node, err := ParseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	return r.resolve(node)
+}
+
+// resolve 替换 $name 引用，并为声明了默认值的变量补上 ?? 默认值
+func (r *Rule) resolve(node Node) (Node, error) {
+	node, err := ResolveRefs(node, r.Lists, r.Constants)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Variables) == 0 {
+		return node, nil
+	}
+
+	return rewriteNode(node, func(n Node) (Node, error) {
+		ident, ok := n.(*Ident)
+		if !ok {
+			return n, nil
+		}
+		variable, ok := r.Variables[ident.Name]
+		if !ok || variable.Default == nil {
+			return n, nil
+		}
+		def, err := valueNode(variable.Default)
+		if err != nil {
+			return nil, &ExprError{Column: ident.Column, Msg: fmt.Sprintf("invalid default for %s: %v", ident.Name, err)}
+		}
+		return &BinaryExpr{Op: "??", Left: ident, Right: def, Column: ident.Column}, nil
+	})
+}
+
+// ParseCondition 将触发条件转换为语法树
+// 设置了 expression 时直接解析；否则由 type/operator/value 组合成比较表达式，
+// 省略 operator 时 type 本身作为布尔表达式；设置了 pattern 时为调用模式条件
+func (r *Rule) ParseCondition(cond RuleCondition) (Node, error) {
+	if cond.Expression != "" {
+		return r.ParseExpression(cond.Expression)
+	}
+
+	if cond.Pattern != "" {
+		if cond.Type != "" && cond.Type != ConditionCallPattern {
+			return nil, fmt.Errorf("pattern requires type %q, got %q", ConditionCallPattern, cond.Type)
+		}
+		pattern, err := ParseCallPattern(cond.Pattern, cond.Within)
+		if err != nil {
+			return nil, err
+		}
+		return &PatternExpr{Pattern: pattern, Column: 1}, nil
+	}
+
+	if cond.Type == "" {
+		return nil, fmt.Errorf("condition requires either type or expression")
+	}
+
+	switch cond.Type {
+	case ConditionRepeatedCall, ConditionVelocity:
+		return r.parseWindowExpr(cond)
+	case ConditionBalanceChange, ConditionStateChange, ConditionCodeChange:
+		return r.parseStateCondition(cond)
+	}
+
+	left, err := ParseExpression(cond.Type)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition type %q: %w", cond.Type, err)
+	}
+
+	if cond.Operator == "" {
+		return r.resolve(left)
+	}
+
+	operator, err := conditionOperator(cond.Operator)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimPrefix(operator, "not ")
+
+	right, err := valueNode(cond.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition value: %w", err)
+	}
+
+	if base == "matches" {
+		// value 可以写成 "/pattern/" 或直接写 "pattern"
+		if lit, ok := right.(*Literal); ok {
+			if pattern, ok := lit.Value.(string); ok && len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+				lit.Value = pattern[1 : len(pattern)-1]
+			}
+			if err := compileRegexLiteral(lit); err != nil {
+				return nil, fmt.Errorf("invalid condition value: %w", err)
+			}
+		}
+	}
+
+	expr := &BinaryExpr{Op: operator, Left: left, Right: right, Column: 1}
+	return r.resolve(expr)
+}
+
+// conditionOperator 规范化条件中的运算符（小写、合并空白）并检查是否支持
+func conditionOperator(op string) (string, error) {
+	operator := strings.ToLower(strings.Join(strings.Fields(op), " "))
+	base := strings.TrimPrefix(operator, "not ")
+	if !isWordOperator(base) && (base != operator || !isCompareOperator(operator)) {
+		return "", fmt.Errorf("unsupported condition operator: %s", op)
+	}
+	return operator, nil
+}
+
+// parseWindowExpr 将窗口条件转换为“本交易计入窗口 AND 窗口内次数 operator value”，operator 默认为 >=
+func (r *Rule) parseWindowExpr(cond RuleCondition) (Node, error) {
+	window, err := r.parseWindowCondition(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	operator := ">="
+	if cond.Operator != "" {
+		if operator, err = conditionOperator(cond.Operator); err != nil {
+			return nil, err
+		}
+		if !isCompareOperator(operator) {
+			return nil, fmt.Errorf("%s requires a comparison operator, got %s", cond.Type, cond.Operator)
+		}
+	}
+	value, err := valueNode(cond.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition value: %w", err)
+	}
+
+	count := &BinaryExpr{Op: operator, Left: &WindowExpr{Window: window, Count: true, Column: 1}, Right: value, Column: 1}
+	expr := &BinaryExpr{Op: "AND", Left: &WindowExpr{Window: window, Column: 1}, Right: count, Column: 1}
+	return r.resolve(expr)
+}
+
+// valueNode 将 YAML 中的条件值转换为语法树节点
+// 数字形式的字符串（如 "1000"、"1e21"、"10 ether"）按数字处理，"$name" 为命名列表引用，
+// YAML 序列转换为列表字面量
+func valueNode(value interface{}) (Node, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("value is required")
+	case bool:
+		return &Literal{Value: v, Column: 1}, nil
+	case int, int64, uint64:
+		return &Literal{Value: normalizeValue(v), Column: 1}, nil
+	case float64:
+		// YAML 会把超出 uint64 的整数解析为浮点数，整数值仍按整数处理
+		if f := big.NewFloat(v); f.IsInt() {
+			n, _ := f.Int(nil)
+			return &Literal{Value: n, Column: 1}, nil
+		}
+		return &Literal{Value: v, Column: 1}, nil
+	case string:
+		if node, err := ParseExpression(v); err == nil {
+			switch n := node.(type) {
+			case *Literal:
+				if !isStringLiteral(n) {
+					n.Column = 1
+					return n, nil
+				}
+			case *ListRef:
+				n.Column = 1
+				return n, nil
+			}
+		}
+		return &Literal{Value: v, Column: 1}, nil
+	case []interface{}:
+		list := &ListExpr{Column: 1}
+		for _, elem := range v {
+			node, err := valueNode(elem)
+			if err != nil {
+				return nil, err
+			}
+			list.Elems = append(list.Elems, node)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+func isStringLiteral(lit *Literal) bool {
+	_, ok := lit.Value.(string)
+	return ok
+}
+
+// ResolveRefs 将语法树中的 $name 引用替换为命名列表或常量，引用不存在时报错
+func ResolveRefs(node Node, lists map[string][]string, constants map[string]string) (Node, error) {
+	return rewriteNode(node, func(n Node) (Node, error) {
+		ref, ok := n.(*ListRef)
+		if !ok {
+			return n, nil
+		}
+
+		if values, ok := lists[ref.Name]; ok {
+			list := &ListExpr{Column: ref.Column, Elems: make([]Node, len(values))}
+			for i, value := range values {
+				list.Elems[i] = &Literal{Value: value, Column: ref.Column}
+			}
+			return list, nil
+		}
+
+		if value, ok := constants[ref.Name]; ok {
+			node, err := valueNode(value)
+			if err != nil {
+				return nil, &ExprError{Column: ref.Column, Msg: fmt.Sprintf("invalid constant $%s: %v", ref.Name, err)}
+			}
+			if lit, ok := node.(*Literal); ok {
+				lit.Column = ref.Column
+				return lit, nil
+			}
+			return nil, &ExprError{Column: ref.Column, Msg: fmt.Sprintf("constant $%s must be a scalar value", ref.Name)}
+		}
+
+		return nil, &ExprError{Column: ref.Column, Msg: fmt.Sprintf("unknown list or constant $%s", ref.Name)}
+	})
+}
@@ -0,0 +1,253 @@
+package ruleengine
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // 语法树的括号形式，体现优先级与结合性
+	}{
+		{`a > 1`, `(a > 1)`},
+		{`a > 1 AND b < 2 OR c == 3`, `(((a > 1) AND (b < 2)) OR (c == 3))`},
+		{`a > 1 OR b < 2 AND c == 3`, `((a > 1) OR ((b < 2) AND (c == 3)))`},
+		{`a > 1 AND (b < 2 OR c == 3)`, `((a > 1) AND ((b < 2) OR (c == 3)))`},
+		{`NOT a == 1 AND b`, `((NOT (a == 1)) AND b)`},
+		{`NOT (a AND b)`, `(NOT (a AND b))`},
+		{`NOT NOT a`, `(NOT (NOT a))`},
+		{`a && b || !c`, `((a AND b) OR (NOT c))`},
+		{`tag == "a > b AND c"`, `(tag == "a > b AND c")`},
+		{`tag != 'x OR y'`, `(tag != "x OR y")`},
+		{`status == true`, `(status == true)`},
+		{`((a))`, `a`},
+		{`value > 1.5 ether`, `(value > 1500000000000000000)`},
+		{`gas_price >= 100 gwei`, `(gas_price >= 100000000000)`},
+		{`value == 1e18`, `(value == 1000000000000000000)`},
+		// ?? 的优先级低于算术、高于比较，左结合
+		{`a ?? 1 + 2 > 3`, `((a ?? (1 + 2)) > 3)`},
+		{`a + b ?? 0 > 3`, `(((a + b) ?? 0) > 3)`},
+		{`a ?? b ?? 0 > 1`, `(((a ?? b) ?? 0) > 1)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error: %v", tt.expr, err)
+			}
+			if got := node.String(); got != tt.want {
+				t.Errorf("ParseExpression(%q) = %s, want %s", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		column int
+		msg    string
+	}{
+		{`a > `, 5, `unexpected end of expression`},
+		{`a > 1 AND`, 10, `unexpected end of expression`},
+		{`a > 1 )`, 7, `unexpected ')' ")"`},
+		{`(a > 1`, 7, `expected ')' to close '(' at column 1, got end of expression`},
+		{`a >> 1`, 4, `unexpected comparison operator ">"`},
+		{`tag == "abc`, 8, `unterminated string literal`},
+		{`a @ b`, 3, `unexpected character '@'`},
+		{`foo(1)`, 1, `unknown function "foo"`},
+		{`1 ether ether`, 9, `unexpected identifier "ether"`},
+		{`a ?? `, 6, `unexpected end of expression`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseExpression(tt.expr)
+			var exprErr *ExprError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("ParseExpression(%q) error = %v, want *ExprError", tt.expr, err)
+			}
+			if exprErr.Column != tt.column || exprErr.Msg != tt.msg {
+				t.Errorf("ParseExpression(%q) error = column %d: %s, want column %d: %s",
+					tt.expr, exprErr.Column, exprErr.Msg, tt.column, tt.msg)
+			}
+		})
+	}
+}
+
+// newTestContext 构造只包含提取数据的求值上下文，变量通过 ExtractedData 提供
+func newTestContext(vars map[string]interface{}) *EvaluationContext {
+	ctx := NewEvaluationContext(nil, nil)
+	for name, value := range vars {
+		ctx.ExtractedData[name] = value
+	}
+	return ctx
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := newTestContext(map[string]interface{}{
+		"a":   5,
+		"t":   true,
+		"f":   false,
+		"tag": "a > b AND c",
+	})
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{``, true},
+		{`a > 1`, true},
+		{`a >= 5 AND a <= 5`, true},
+		{`a != 5`, false},
+		{`f AND t OR t`, true},
+		{`f AND (t OR t)`, false},
+		{`NOT f AND t`, true},
+		{`NOT (f OR t)`, false},
+		{`tag == "a > b AND c"`, true},
+		{`tag == "a > b"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateBigInt(t *testing.T) {
+	maxUint256, _ := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+	ctx := newTestContext(map[string]interface{}{
+		"max":    maxUint256,
+		"amount": big.NewInt(1500000000000000000),
+	})
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`max == 115792089237316195423570985008687907853269984665640564039457584007913129639935`, true},
+		{`max > 115792089237316195423570985008687907853269984665640564039457584007913129639934`, true},
+		{`max + 1 > max`, true},
+		{`max - 1 < max`, true},
+		{`amount == 1.5 ether`, true},
+		{`amount > 1 ether AND amount < 2 ether`, true},
+		{`amount == 1500000000 gwei`, true},
+		{`1 ether == 1e18`, true},
+		{`1 gwei == 1000000000`, true},
+		{`1 wei == 1`, true},
+		{`amount / 3 == 500000000000000000`, true},
+		{`amount > 1.2`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateQuantifiers(t *testing.T) {
+	ctx := newTestContext(nil)
+	ctx.CallStack = []CallFrame{
+		{Type: "CALL", To: "0xAA", Value: "1000000000000000000", Input: "0xa9059cbb"},
+		{Type: "DELEGATECALL", To: "0xbb", Value: "2000000000000000000", Error: "execution reverted"},
+	}
+	ctx.Logs = []EventLog{
+		{Address: "0xcc", Topics: []string{"0xddf252ad"}},
+		{Address: "0xcc", Topics: []string{"0x8c5be1e5"}},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`any(call_stack, .type == "DELEGATECALL")`, true},
+		{`any(call_stack, .to == "0xaa")`, true}, // 地址比较不区分大小写
+		{`all(call_stack, .value > 0)`, true},
+		{`all(call_stack, .type == "CALL")`, false},
+		{`none(call_stack, .to == "0xdd")`, true},
+		{`count(call_stack) == 2`, true},
+		{`count(call_stack, .value >= 2 ether) == 1`, true},
+		{`sum(call_stack, .value) == 3 ether`, true},
+		{`any(call_stack, .failed && .error matches /revert/)`, true},
+		{`count(events) == 2`, true},
+		{`any(events, .topics[0] == "0xddf252ad")`, true},
+		{`count(events, .topics[0] == "0x8c5be1e5") > 1`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMissing(t *testing.T) {
+	ctx := newTestContext(map[string]interface{}{
+		"a": 5,
+		"t": true,
+		"f": false,
+	})
+	tests := []struct {
+		expr    string
+		want    bool
+		missing bool // 结果未知，返回 *MissingValueError
+	}{
+		{expr: `x > 1`, missing: true},
+		{expr: `NOT x > 1`, missing: true},
+		{expr: `NOT NOT x > 1`, missing: true},
+		{expr: `x + 1 > 1`, missing: true},
+		// 三值逻辑：false AND 未知 = false，true OR 未知 = true
+		{expr: `f AND x > 1`, want: false},
+		{expr: `x > 1 AND f`, want: false},
+		{expr: `t AND x > 1`, missing: true},
+		{expr: `t OR x > 1`, want: true},
+		{expr: `x > 1 OR t`, want: true},
+		{expr: `f OR x > 1`, missing: true},
+		{expr: `NOT (f AND x > 1)`, want: true},
+		{expr: `NOT (t OR x > 1)`, want: false},
+		{expr: `x ?? 3 > 2`, want: true},
+		{expr: `(x ?? 3) > 2`, want: true},
+		{expr: `x + 1 ?? 10 > 9`, want: true},
+		{expr: `a ?? 0 == 5`, want: true},
+		{expr: `x ?? y ?? 1 == 1`, want: true},
+		{expr: `exists(x)`, want: false},
+		{expr: `exists(a)`, want: true},
+		{expr: `NOT exists(x) OR x > 1`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := NewEvaluator().Evaluate(tt.expr, ctx)
+			var missing *MissingValueError
+			if tt.missing {
+				if !errors.As(err, &missing) {
+					t.Fatalf("Evaluate(%q) = %v, %v, want *MissingValueError", tt.expr, got, err)
+				}
+				if missing.Name != "x" {
+					t.Errorf("Evaluate(%q) missing %s, want x", tt.expr, missing.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
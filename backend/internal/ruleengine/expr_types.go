@@ -0,0 +1,181 @@
+package ruleengine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TypeKind 表达式值的静态类型
+type TypeKind int
+
+const (
+	TypeAny     TypeKind = iota // 未知类型（如提取的数据），与任意类型兼容
+	TypeBool                    // 布尔
+	TypeUint256                 // 任意精度整数（wei 金额、gas、区块号）
+	TypeNumber                  // 小数（比值、百分比）
+	TypeString                  // 字符串
+	TypeAddress                 // 地址（0x 开头的十六进制字符串）
+	TypeList                    // 列表，元素类型为 Elem
+	TypeObject                  // 结构体（调用帧、事件），字段类型为 Fields
+	TypeRegex                   // 正则
+)
+
+// ExprType 表达式类型
+type ExprType struct {
+	Kind   TypeKind
+	Name   string               // 结构体类型名，如 call_frame
+	Elem   *ExprType            // 列表元素类型
+	Fields map[string]*ExprType // 结构体字段
+}
+
+var (
+	anyType     = &ExprType{Kind: TypeAny}
+	boolType    = &ExprType{Kind: TypeBool}
+	uint256Type = &ExprType{Kind: TypeUint256}
+	numberType  = &ExprType{Kind: TypeNumber}
+	stringType  = &ExprType{Kind: TypeString}
+	addressType = &ExprType{Kind: TypeAddress}
+	regexType   = &ExprType{Kind: TypeRegex}
+)
+
+// listOf 构造列表类型
+func listOf(elem *ExprType) *ExprType {
+	return &ExprType{Kind: TypeList, Elem: elem}
+}
+
+func (t *ExprType) String() string {
+	switch t.Kind {
+	case TypeBool:
+		return "bool"
+	case TypeUint256:
+		return "uint256"
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeAddress:
+		return "address"
+	case TypeList:
+		return "list<" + t.Elem.String() + ">"
+	case TypeObject:
+		return t.Name
+	case TypeRegex:
+		return "regex"
+	default:
+		return "any"
+	}
+}
+
+// isNumeric 是否为数值类型
+func (t *ExprType) isNumeric() bool {
+	return t.Kind == TypeAny || t.Kind == TypeUint256 || t.Kind == TypeNumber
+}
+
+// isStringLike 是否为字符串类型（地址也是字符串）
+func (t *ExprType) isStringLike() bool {
+	return t.Kind == TypeAny || t.Kind == TypeString || t.Kind == TypeAddress
+}
+
+// is 是否为指定类型，未知类型视为匹配
+func (t *ExprType) is(kind TypeKind) bool {
+	return t.Kind == TypeAny || t.Kind == kind
+}
+
+// comparableWith 判断两个类型能否做相等比较
+func (t *ExprType) comparableWith(other *ExprType) bool {
+	switch {
+	case t.Kind == TypeAny || other.Kind == TypeAny:
+		return true
+	case t.isNumeric() && other.isNumeric():
+		return true
+	case t.isStringLike() && other.isStringLike():
+		return true
+	default:
+		return t.Kind == other.Kind
+	}
+}
+
+// ParseTypeName 解析类型名：address、uint256、number、bool、string、any、list<T>
+func ParseTypeName(name string) (*ExprType, error) {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "list<") && strings.HasSuffix(name, ">") {
+		elem, err := ParseTypeName(name[5 : len(name)-1])
+		if err != nil {
+			return nil, err
+		}
+		return listOf(elem), nil
+	}
+
+	switch name {
+	case "any":
+		return anyType, nil
+	case "bool":
+		return boolType, nil
+	case "uint256", "int":
+		return uint256Type, nil
+	case "number":
+		return numberType, nil
+	case "string":
+		return stringType, nil
+	case "address":
+		return addressType, nil
+	case "list":
+		return listOf(anyType), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", name)
+}
+
+// VariableSchema 表达式可引用的变量及其类型
+type VariableSchema map[string]*ExprType
+
+// Clone 复制变量表，用于在内置变量之外追加规则自己的变量
+func (s VariableSchema) Clone() VariableSchema {
+	clone := make(VariableSchema, len(s))
+	for name, t := range s {
+		clone[name] = t
+	}
+	return clone
+}
+
+// suggest 为拼写错误的变量名给出最接近的候选
+func (s VariableSchema) suggest(name string) string {
+	names := make([]string, 0, len(s))
+	for candidate := range s {
+		names = append(names, candidate)
+	}
+	return closestName(name, names)
+}
+
+// closestName 返回编辑距离不超过 2 的最接近候选
+func closestName(name string, candidates []string) string {
+	sort.Strings(candidates)
+	best, bestDist := "", 3
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance 计算两个字符串的 Levenshtein 距离
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
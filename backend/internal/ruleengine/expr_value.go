@@ -0,0 +1,266 @@
+package ruleengine
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// 表达式中的数值统一使用以下表示：
+//   - 整数: *big.Int（任意精度，wei 金额不会溢出）
+//   - 小数: float64
+// 其它 Go 整数类型在进入求值器时由 normalizeValue 转换为 *big.Int
+
+// etherUnits 以太坊金额单位（换算为 wei）
+var etherUnits = map[string]*big.Int{
+	"wei":    big.NewInt(1),
+	"kwei":   big.NewInt(1e3),
+	"mwei":   big.NewInt(1e6),
+	"gwei":   big.NewInt(1e9),
+	"szabo":  big.NewInt(1e12),
+	"finney": big.NewInt(1e15),
+	"ether":  big.NewInt(1e18),
+}
+
+// isEtherUnit 判断标识符是否为金额单位
+func isEtherUnit(name string) bool {
+	_, ok := etherUnits[strings.ToLower(name)]
+	return ok
+}
+
+// parseNumberLiteral 解析数字字面量，可选金额单位
+// "1e18"、"10" 以及 "1.5 ether" 这类结果为整数的字面量返回 *big.Int，其余返回 float64
+func parseNumberLiteral(text string, unit string) (interface{}, error) {
+	r, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", text)
+	}
+
+	if unit != "" {
+		multiplier, ok := etherUnits[strings.ToLower(unit)]
+		if !ok {
+			return nil, fmt.Errorf("unknown unit %q", unit)
+		}
+		r.Mul(r, new(big.Rat).SetInt(multiplier))
+		if !r.IsInt() {
+			return nil, fmt.Errorf("%s %s is not a whole number of wei", text, unit)
+		}
+	}
+
+	if r.IsInt() {
+		return new(big.Int).Set(r.Num()), nil
+	}
+	f, _ := r.Float64()
+	return f, nil
+}
+
+// parseBigInt 解析十进制或 0x 前缀的十六进制整数字符串
+func parseBigInt(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	base := 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+		base = 16
+	}
+	if s == "" {
+		return new(big.Int), nil
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	return n, nil
+}
+
+// normalizeValue 将 Go 原生数值类型统一为 *big.Int / float64
+func normalizeValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int:
+		return big.NewInt(int64(v))
+	case int8:
+		return big.NewInt(int64(v))
+	case int16:
+		return big.NewInt(int64(v))
+	case int32:
+		return big.NewInt(int64(v))
+	case int64:
+		return big.NewInt(v)
+	case uint:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint8:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint16:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case big.Int:
+		return new(big.Int).Set(&v)
+	case float32:
+		return float64(v)
+	default:
+		return val
+	}
+}
+
+// toBigInt 尝试将值转换为 *big.Int
+func toBigInt(val interface{}) (*big.Int, bool) {
+	if n, ok := normalizeValue(val).(*big.Int); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// toBigFloat 尝试将数值转换为 *big.Float（用于整数与小数的混合比较）
+func toBigFloat(val interface{}) (*big.Float, bool) {
+	switch v := normalizeValue(val).(type) {
+	case *big.Int:
+		return new(big.Float).SetInt(v), true
+	case float64:
+		if math.IsNaN(v) {
+			return nil, false
+		}
+		return big.NewFloat(v), true
+	default:
+		return nil, false
+	}
+}
+
+// compareOrdered 根据 Cmp 结果判断比较运算
+func compareOrdered(cmp int, operator string) (bool, error) {
+	switch operator {
+	case ">":
+		return cmp > 0, nil
+	case "<":
+		return cmp < 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	default:
+		return false, fmt.Errorf("unsupported operator: %s", operator)
+	}
+}
+
+// arith 执行算术运算
+// 整数之间的 + - * % 保持任意精度；除法结果为整数时返回 *big.Int，否则返回 float64
+func arith(left interface{}, operator string, right interface{}) (interface{}, error) {
+	leftInt, leftIsInt := toBigInt(left)
+	rightInt, rightIsInt := toBigInt(right)
+
+	if leftIsInt && rightIsInt {
+		switch operator {
+		case "+":
+			return new(big.Int).Add(leftInt, rightInt), nil
+		case "-":
+			return new(big.Int).Sub(leftInt, rightInt), nil
+		case "*":
+			return new(big.Int).Mul(leftInt, rightInt), nil
+		case "/":
+			if rightInt.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			r := new(big.Rat).SetFrac(leftInt, rightInt)
+			if r.IsInt() {
+				return new(big.Int).Set(r.Num()), nil
+			}
+			f, _ := r.Float64()
+			return f, nil
+		case "%":
+			if rightInt.Sign() == 0 {
+				return nil, fmt.Errorf("modulo by zero")
+			}
+			return new(big.Int).Rem(leftInt, rightInt), nil
+		}
+		return nil, fmt.Errorf("unsupported operator: %s", operator)
+	}
+
+	leftFloat, leftIsNum := toFloat64(left)
+	rightFloat, rightIsNum := toFloat64(right)
+	if !leftIsNum || !rightIsNum {
+		return nil, fmt.Errorf("operator '%s' requires numeric operands, got %T and %T", operator, left, right)
+	}
+
+	switch operator {
+	case "+":
+		return leftFloat + rightFloat, nil
+	case "-":
+		return leftFloat - rightFloat, nil
+	case "*":
+		return leftFloat * rightFloat, nil
+	case "/":
+		if rightFloat == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return leftFloat / rightFloat, nil
+	case "%":
+		if rightFloat == 0 {
+			return nil, fmt.Errorf("modulo by zero")
+		}
+		return math.Mod(leftFloat, rightFloat), nil
+	}
+	return nil, fmt.Errorf("unsupported operator: %s", operator)
+}
+
+// negate 对数值取负
+func negate(val interface{}) (interface{}, error) {
+	switch v := normalizeValue(val).(type) {
+	case *big.Int:
+		return new(big.Int).Neg(v), nil
+	case float64:
+		return -v, nil
+	default:
+		return nil, fmt.Errorf("operator '-' requires a numeric operand, got %T", val)
+	}
+}
+
+// toFloat64 尝试将数值转换为 float64
+func toFloat64(val interface{}) (float64, bool) {
+	switch v := normalizeValue(val).(type) {
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// compareNumbers 比较两个数值，返回 -1/0/1
+func compareNumbers(left, right interface{}) (int, bool) {
+	leftInt, leftIsInt := toBigInt(left)
+	rightInt, rightIsInt := toBigInt(right)
+	if leftIsInt && rightIsInt {
+		return leftInt.Cmp(rightInt), true
+	}
+
+	leftFloat, leftIsNum := toBigFloat(left)
+	rightFloat, rightIsNum := toBigFloat(right)
+	if leftIsNum && rightIsNum {
+		return leftFloat.Cmp(rightFloat), true
+	}
+	return 0, false
+}
+
+// indexValue 按下标访问列表元素，越界时报错
+func indexValue(x interface{}, index interface{}) (interface{}, error) {
+	items, ok := x.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot index %T", x)
+	}
+	i, ok := toBigInt(index)
+	if !ok || !i.IsInt64() {
+		return nil, fmt.Errorf("index must be an integer, got %v", index)
+	}
+	if i.Sign() < 0 || i.Int64() >= int64(len(items)) {
+		return nil, fmt.Errorf("index %s out of range (length %d)", i, len(items))
+	}
+	return normalizeValue(items[i.Int64()]), nil
+}
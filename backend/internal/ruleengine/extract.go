@@ -0,0 +1,555 @@
+package ruleengine
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transactionAliases transaction 段的简写字段，其余字段直接使用内置变量名
+var transactionAliases = map[string]string{
+	"hash":     "tx_hash",
+	"from":     "from_address",
+	"to":       "to_address",
+	"input":    "input_data",
+	"selector": "function_selector",
+}
+
+// extractAggregates 集合字段的聚合方式，写作 <聚合>.<字段>，如 max.depth、sum.value
+var extractAggregates = map[string]bool{
+	"first":  true, // 第一个元素的字段（只写字段名时的默认方式）
+	"last":   true, // 最后一个元素的字段
+	"min":    true,
+	"max":    true,
+	"sum":    true,
+	"all":    true, // 所有元素的字段值列表
+	"unique": true, // 去重后的字段值列表
+}
+
+// Extractor 规则的数据提取阶段
+// 在触发条件之前执行，按 extract 中声明的顺序把字段写入 ExtractedData，
+// 触发条件、评分因子和动作模板（{{name}}）都可以引用提取的变量
+type Extractor struct {
+	steps []extractStep
+	types map[string]*ExprType // 提取变量的类型，声明有误的字段为 any
+}
+
+// extractStep 单个提取字段，ok 为 false 表示当前交易中取不到该字段
+type extractStep struct {
+	name string
+	get  func(run *extractRun) (value interface{}, ok bool, err error)
+}
+
+// extractRun 单次提取的状态，同一事件只解码一次
+type extractRun struct {
+	ctx    *EvaluationContext
+	events map[*eventSpec][]interface{}
+}
+
+// Run 在 ctx 的副本上执行提取，返回规则作用域的上下文，原上下文不受影响；
+// 取不到的字段（如没有匹配的事件）不写入，在表达式中按缺失值处理
+func (x *Extractor) Run(ctx *EvaluationContext) (*EvaluationContext, error) {
+	if len(x.steps) == 0 {
+		return ctx, nil
+	}
+
+	run := &extractRun{ctx: ctx.WithExtractedData(nil)}
+	for _, step := range x.steps {
+		value, ok, err := step.get(run)
+		if err != nil {
+			return nil, fmt.Errorf("extract %s: %w", step.name, err)
+		}
+		if ok {
+			run.ctx.ExtractedData[step.name] = value
+		}
+	}
+	return run.ctx, nil
+}
+
+// ruleIssue 规则声明（extract、filters）中的错误，keys 为其在规则 YAML 中的路径
+type ruleIssue struct {
+	keys []interface{}
+	err  error
+}
+
+func (i ruleIssue) Error() string {
+	return formatPath(i.keys) + ": " + i.err.Error()
+}
+
+// extractCompiler 编译 extract 声明
+type extractCompiler struct {
+	rule      *Rule
+	schema    VariableSchema // custom 表达式可引用的变量，随提取字段逐个扩充
+	extractor *Extractor
+	issues    []ruleIssue
+}
+
+// compileExtract 编译规则的 extract 声明，返回的 Extractor 只包含声明正确的字段
+// schema 为 custom 表达式可引用的变量，编译过程中会加入提取的变量
+func (r *Rule) compileExtract(schema VariableSchema) (*Extractor, []ruleIssue) {
+	c := &extractCompiler{
+		rule:      r,
+		schema:    schema,
+		extractor: &Extractor{types: make(map[string]*ExprType)},
+	}
+
+	e := r.Extract
+	for i, f := range e.Transaction {
+		t, get, err := transactionField(f.Field)
+		c.add(f, t, get, err, "extract", "transaction", i)
+	}
+	for i, f := range e.CallStack {
+		t, get, err := callStackField(f.Field)
+		c.add(f, t, get, err, "extract", "call_stack", i)
+	}
+	for i, f := range e.StateChanges {
+		t, get, err := stateChangeField(f.Field)
+		c.add(f, t, get, err, "extract", "state_changes", i)
+	}
+	for i, event := range e.Events {
+		c.addEvent(event, i)
+	}
+	for _, group := range sortedKeys(e.Custom) {
+		for i, f := range e.Custom[group] {
+			t, get, err := c.customField(f.Field)
+			c.add(f, t, get, err, "extract", "custom", group, i)
+		}
+	}
+
+	return c.extractor, c.issues
+}
+
+// add 登记一个提取字段，err 不为空时记录为 field 的错误
+func (c *extractCompiler) add(f ExtractField, t *ExprType, get func(*extractRun) (interface{}, bool, error), err error, keys ...interface{}) {
+	if err != nil {
+		c.report(err, append(keys, "field")...)
+		t, get = anyType, nil
+	}
+	if !c.declare(f.As, t, keys) {
+		return
+	}
+	if get != nil {
+		c.extractor.steps = append(c.extractor.steps, extractStep{name: f.As, get: get})
+	}
+}
+
+// declare 声明提取变量，名称为空或重复时记录错误
+func (c *extractCompiler) declare(name string, t *ExprType, keys []interface{}) bool {
+	if name == "" {
+		c.report(fmt.Errorf("as is required"), append(keys, "as")...)
+		return false
+	}
+	if _, ok := c.extractor.types[name]; ok {
+		c.report(fmt.Errorf("variable %s is already extracted", name), append(keys, "as")...)
+		return false
+	}
+	c.extractor.types[name] = t
+	c.schema[name] = t
+	return true
+}
+
+func (c *extractCompiler) report(err error, keys ...interface{}) {
+	c.issues = append(c.issues, ruleIssue{keys: append([]interface{}(nil), keys...), err: err})
+}
+
+// addEvent 编译事件提取：as 为匹配的事件数量，fields 为解码后的事件参数
+func (c *extractCompiler) addEvent(event ExtractEventField, i int) {
+	spec, err := parseEventSignature(event.Event)
+	if err != nil {
+		c.report(err, "extract", "events", i, "event")
+		if event.As != "" {
+			c.declare(event.As, uint256Type, []interface{}{"extract", "events", i})
+		}
+		for j, f := range event.Fields {
+			c.declare(f.As, anyType, []interface{}{"extract", "events", i, "fields", j})
+		}
+		return
+	}
+
+	if event.As != "" && c.declare(event.As, uint256Type, []interface{}{"extract", "events", i}) {
+		c.extractor.steps = append(c.extractor.steps, extractStep{
+			name: event.As,
+			get: func(run *extractRun) (interface{}, bool, error) {
+				return len(run.decoded(spec)), true, nil
+			},
+		})
+	}
+
+	for j, f := range event.Fields {
+		t, selectFn, err := selectField(f.Field, spec.fields)
+		var get func(*extractRun) (interface{}, bool, error)
+		if err == nil {
+			get = func(run *extractRun) (interface{}, bool, error) {
+				value, ok := selectFn(run.decoded(spec))
+				return value, ok, nil
+			}
+		} else {
+			err = fmt.Errorf("event %s: %w", spec.name, err)
+		}
+		c.add(f, t, get, err, "extract", "events", i, "fields", j)
+	}
+}
+
+// customField 编译自定义字段：field 为表达式，可引用内置变量和之前提取的变量
+func (c *extractCompiler) customField(expression string) (*ExprType, func(*extractRun) (interface{}, bool, error), error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil, fmt.Errorf("expression is required")
+	}
+	node, err := c.rule.ParseExpression(expression)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := CheckExpression(node, c.schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	program, err := NewEvaluator().Compile(node)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t, func(run *extractRun) (interface{}, bool, error) {
+		value, err := program.Value(run.ctx)
+		var missing *MissingValueError
+		if errors.As(err, &missing) {
+			return nil, false, nil
+		}
+		return value, err == nil, err
+	}, nil
+}
+
+// transactionField 编译 transaction 段的字段：简写（hash、from、to、input、selector）或内置变量名
+func transactionField(name string) (*ExprType, func(*extractRun) (interface{}, bool, error), error) {
+	if alias, ok := transactionAliases[name]; ok {
+		name = alias
+	}
+	field, ok := LookupField(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown transaction field %q", name)
+	}
+	return field.Type, func(run *extractRun) (interface{}, bool, error) {
+		value, ok := field.Get(run.ctx)
+		return value, ok, nil
+	}, nil
+}
+
+// callStackField 编译 call_stack 段的字段：count、depth（最大调用深度）或调用帧字段选择器
+func callStackField(name string) (*ExprType, func(*extractRun) (interface{}, bool, error), error) {
+	if name == "depth" {
+		name = "max.depth"
+	}
+	t, selectFn, err := selectField(name, callFrameType.Fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("call_stack: %w", err)
+	}
+	return t, func(run *extractRun) (interface{}, bool, error) {
+		items, _ := run.ctx.collection("call_stack")
+		value, ok := selectFn(items)
+		return value, ok, nil
+	}, nil
+}
+
+// stateChangeField 编译 state_changes 段的字段：count（变化数量）、keys（变化的键）
+func stateChangeField(name string) (*ExprType, func(*extractRun) (interface{}, bool, error), error) {
+	switch name {
+	case "count":
+		return uint256Type, func(run *extractRun) (interface{}, bool, error) {
+			return len(run.ctx.StateChanges), true, nil
+		}, nil
+	case "keys":
+		return listOf(stringType), func(run *extractRun) (interface{}, bool, error) {
+			keys := sortedKeys(run.ctx.StateChanges)
+			list := make([]interface{}, len(keys))
+			for i, key := range keys {
+				list[i] = key
+			}
+			return list, true, nil
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown state_changes field %q (expected count or keys)", name)
+	}
+}
+
+// selectField 编译集合字段选择器：count、<字段>（第一个元素）或 <聚合>.<字段>
+// fields 为集合元素的字段类型
+func selectField(selector string, fields map[string]*ExprType) (*ExprType, func(items []interface{}) (interface{}, bool), error) {
+	if selector == "count" {
+		return uint256Type, func(items []interface{}) (interface{}, bool) {
+			return len(items), true
+		}, nil
+	}
+
+	agg, name := "first", selector
+	if prefix, rest, ok := strings.Cut(selector, "."); ok {
+		if !extractAggregates[prefix] {
+			return nil, nil, fmt.Errorf("unknown aggregate %q in %q", prefix, selector)
+		}
+		agg, name = prefix, rest
+	}
+
+	ft, ok := fields[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown field %q (available: %s)", name, strings.Join(sortedKeys(fields), ", "))
+	}
+
+	t := ft
+	switch agg {
+	case "min", "max", "sum":
+		if !ft.isNumeric() {
+			return nil, nil, fmt.Errorf("%s.%s requires a numeric field, got %s", agg, name, ft)
+		}
+	case "all", "unique":
+		t = listOf(ft)
+	}
+
+	return t, func(items []interface{}) (interface{}, bool) {
+		return aggregate(agg, name, items)
+	}, nil
+}
+
+// aggregate 对集合元素的字段做聚合，集合为空时 first/last/min/max 取不到值
+func aggregate(agg, field string, items []interface{}) (interface{}, bool) {
+	values := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if fields, ok := item.(map[string]interface{}); ok {
+			if value, ok := fields[field]; ok {
+				values = append(values, normalizeValue(value))
+			}
+		}
+	}
+
+	switch agg {
+	case "all":
+		return values, true
+	case "unique":
+		unique := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			seen := false
+			for _, u := range unique {
+				if valuesEqual(u, value) {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				unique = append(unique, value)
+			}
+		}
+		return unique, true
+	case "sum":
+		var total interface{} = new(big.Int)
+		for _, value := range values {
+			sum, err := arith(total, "+", value)
+			if err != nil {
+				return nil, false
+			}
+			total = sum
+		}
+		return total, true
+	}
+
+	if len(values) == 0 {
+		return nil, false
+	}
+	switch agg {
+	case "last":
+		return values[len(values)-1], true
+	case "min", "max":
+		best := values[0]
+		for _, value := range values[1:] {
+			cmp, ok := compareNumbers(value, best)
+			if !ok {
+				return nil, false
+			}
+			if (agg == "min" && cmp < 0) || (agg == "max" && cmp > 0) {
+				best = value
+			}
+		}
+		return best, true
+	default:
+		return values[0], true
+	}
+}
+
+// eventSpec 解析后的事件签名
+type eventSpec struct {
+	name    string
+	id      common.Hash
+	indexed abi.Arguments
+	data    abi.Arguments
+	fields  map[string]*ExprType // 解码后可访问的字段：参数名加上 address（发出事件的合约）
+}
+
+// parseEventSignature 解析事件签名，如 "Transfer(address indexed from, address indexed to, uint256 value)"
+// 未命名的参数依次命名为 arg0、arg1 ...；不支持 tuple 参数
+func parseEventSignature(signature string) (*eventSpec, error) {
+	signature = strings.TrimSpace(signature)
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("event must be a full signature such as %q", "Transfer(address indexed from, address indexed to, uint256 value)")
+	}
+
+	spec := &eventSpec{
+		name:   strings.TrimSpace(signature[:open]),
+		fields: map[string]*ExprType{"address": addressType},
+	}
+	params := strings.TrimSpace(signature[open+1 : len(signature)-1])
+	if strings.ContainsAny(params, "()") {
+		return nil, fmt.Errorf("event %s: tuple parameters are not supported", spec.name)
+	}
+
+	var types []string
+	if params != "" {
+		for i, param := range strings.Split(params, ",") {
+			parts := strings.Fields(param)
+			if len(parts) == 0 {
+				return nil, fmt.Errorf("event %s: parameter %d is empty", spec.name, i)
+			}
+			typ, err := abi.NewType(parts[0], "", nil)
+			if err != nil {
+				return nil, fmt.Errorf("event %s: %w", spec.name, err)
+			}
+
+			arg := abi.Argument{Name: fmt.Sprintf("arg%d", i), Type: typ}
+			rest := parts[1:]
+			if len(rest) > 0 && rest[0] == "indexed" {
+				arg.Indexed = true
+				rest = rest[1:]
+			}
+			switch len(rest) {
+			case 0:
+			case 1:
+				arg.Name = rest[0]
+			default:
+				return nil, fmt.Errorf("event %s: invalid parameter %q", spec.name, strings.TrimSpace(param))
+			}
+			if _, ok := spec.fields[arg.Name]; ok {
+				return nil, fmt.Errorf("event %s: duplicate parameter %s", spec.name, arg.Name)
+			}
+
+			spec.fields[arg.Name] = abiExprType(typ)
+			if arg.Indexed && (typ.T == abi.SliceTy || typ.T == abi.ArrayTy) {
+				spec.fields[arg.Name] = stringType // indexed 的动态类型只保存哈希
+			}
+			if arg.Indexed {
+				spec.indexed = append(spec.indexed, arg)
+			} else {
+				spec.data = append(spec.data, arg)
+			}
+			types = append(types, typ.String())
+		}
+	}
+
+	spec.id = crypto.Keccak256Hash([]byte(spec.name + "(" + strings.Join(types, ",") + ")"))
+	return spec, nil
+}
+
+// abiExprType ABI 类型对应的表达式类型
+func abiExprType(t abi.Type) *ExprType {
+	switch t.T {
+	case abi.AddressTy:
+		return addressType
+	case abi.IntTy, abi.UintTy:
+		return uint256Type
+	case abi.BoolTy:
+		return boolType
+	case abi.StringTy, abi.BytesTy, abi.FixedBytesTy, abi.HashTy:
+		return stringType
+	default:
+		return anyType
+	}
+}
+
+// decoded 返回交易中匹配该事件的日志，已解码为字段映射
+func (run *extractRun) decoded(spec *eventSpec) []interface{} {
+	if items, ok := run.events[spec]; ok {
+		return items
+	}
+
+	var items []interface{}
+	for _, log := range run.ctx.Logs {
+		if fields, ok := spec.decode(log); ok {
+			items = append(items, fields)
+		}
+	}
+
+	if run.events == nil {
+		run.events = make(map[*eventSpec][]interface{})
+	}
+	run.events[spec] = items
+	return items
+}
+
+// decode 解码单条日志，topic0 或参数布局不匹配时返回 false
+// （如 ERC-721 的 Transfer 与 ERC-20 同名同 topic0，但 tokenId 是 indexed 的）
+func (spec *eventSpec) decode(log EventLog) (map[string]interface{}, bool) {
+	if len(log.Topics) != len(spec.indexed)+1 || common.HexToHash(log.Topics[0]) != spec.id {
+		return nil, false
+	}
+
+	values := make(map[string]interface{}, len(spec.indexed)+len(spec.data))
+	if len(spec.indexed) > 0 {
+		topics := make([]common.Hash, len(spec.indexed))
+		for i, topic := range log.Topics[1:] {
+			topics[i] = common.HexToHash(topic)
+		}
+		if err := abi.ParseTopicsIntoMap(values, spec.indexed, topics); err != nil {
+			return nil, false
+		}
+	}
+	if len(spec.data) > 0 {
+		unpacked, err := spec.data.UnpackValues(common.FromHex(log.Data))
+		if err != nil {
+			return nil, false
+		}
+		for i, arg := range spec.data {
+			values[arg.Name] = unpacked[i]
+		}
+	}
+
+	fields := make(map[string]interface{}, len(values)+1)
+	fields["address"] = log.Address
+	for name, value := range values {
+		fields[name] = abiValue(value)
+	}
+	return fields, true
+}
+
+// abiValue 把 ABI 解码结果转换为表达式中的值：整数为 *big.Int，地址、哈希和字节为十六进制字符串
+func abiValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case *big.Int, bool, string:
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = abiValue(rv.Index(i).Interface())
+		}
+		return list
+	}
+	return normalizeValue(value)
+}
@@ -0,0 +1,244 @@
+package ruleengine
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSelectField(t *testing.T) {
+	frames := []CallFrame{
+		{Type: "call", To: addrA, Value: "3", Depth: 0},
+		{Type: "call", To: addrB, Value: "1", Depth: 1},
+		{Type: "delegatecall", To: addrA, Value: "0", Depth: 2},
+		{Type: "staticcall", To: addrC, Value: "5", Depth: 1},
+	}
+	items := make([]interface{}, len(frames))
+	for i, frame := range frames {
+		items[i] = frame.fields()
+	}
+
+	tests := []struct {
+		selector string
+		items    []interface{}
+		want     string // fmt.Sprint 的结果
+		missing  bool   // 取不到值
+		wantErr  string
+	}{
+		{selector: "count", items: items, want: "4"},
+		{selector: "to", items: items, want: addrA},
+		{selector: "first.type", items: items, want: "CALL"},
+		{selector: "last.type", items: items, want: "STATICCALL"},
+		{selector: "min.value", items: items, want: "0"},
+		{selector: "max.value", items: items, want: "5"},
+		{selector: "max.depth", items: items, want: "2"},
+		{selector: "sum.value", items: items, want: "9"},
+		{selector: "all.to", items: items, want: fmt.Sprint([]interface{}{addrA, addrB, addrA, addrC})},
+		{selector: "unique.to", items: items, want: fmt.Sprint([]interface{}{addrA, addrB, addrC})},
+		{selector: "unique.type", items: items, want: "[CALL DELEGATECALL STATICCALL]"},
+		// 空集合：count、sum 为 0，all/unique 为空列表，其余取不到值
+		{selector: "count", want: "0"},
+		{selector: "sum.value", want: "0"},
+		{selector: "all.to", want: "[]"},
+		{selector: "to", missing: true},
+		{selector: "last.to", missing: true},
+		{selector: "max.depth", missing: true},
+		{selector: "median.value", wantErr: `unknown aggregate "median" in "median.value"`},
+		{selector: "sum.to", wantErr: "sum.to requires a numeric field, got address"},
+		{selector: "max.selector", wantErr: "max.selector requires a numeric field, got string"},
+		{selector: "first.callee", wantErr: `unknown field "callee" (available: `},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.selector, len(tt.items)), func(t *testing.T) {
+			_, selectFn, err := selectField(tt.selector, callFrameType.Fields)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("selectField(%q) error = %v, want %s", tt.selector, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := selectFn(tt.items)
+			if ok == tt.missing {
+				t.Fatalf("selectField(%q) = %v, %v, want missing %v", tt.selector, got, ok, tt.missing)
+			}
+			if ok && fmt.Sprint(got) != tt.want {
+				t.Errorf("selectField(%q) = %v, want %s", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+// transferLog 构造 ERC-20 Transfer 日志，nft 为 true 时按 ERC-721 的布局（tokenId 为 indexed）
+func transferLog(contract, from, to string, amount int64, nft bool) EventLog {
+	topics := []string{
+		crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex(),
+		common.BytesToHash(common.HexToAddress(from).Bytes()).Hex(),
+		common.BytesToHash(common.HexToAddress(to).Bytes()).Hex(),
+	}
+	word := common.BigToHash(big.NewInt(amount))
+	if nft {
+		return EventLog{Address: contract, Topics: append(topics, word.Hex()), Data: "0x"}
+	}
+	return EventLog{Address: contract, Topics: topics, Data: word.Hex()}
+}
+
+func TestExtractEvents(t *testing.T) {
+	rule := testRule("transfers")
+	rule.Extract.Events = []ExtractEventField{{
+		Event: "Transfer(address indexed from, address indexed to, uint256 value)",
+		As:    "transfer_count",
+		Fields: []ExtractField{
+			{Field: "from", As: "first_sender"},
+			{Field: "sum.value", As: "total"},
+			{Field: "max.value", As: "largest"},
+			{Field: "unique.address", As: "tokens"},
+			{Field: "last.to", As: "last_recipient"},
+		},
+	}, {
+		Event:  "Approval(address indexed owner, address indexed spender, uint256)",
+		As:     "approvals",
+		Fields: []ExtractField{{Field: "arg2", As: "allowance"}},
+	}}
+	extractor, issues := rule.compileExtract(rule.variableSchema())
+	if len(issues) > 0 {
+		t.Fatal(issues)
+	}
+
+	ctx := NewEvaluationContext(nil, nil)
+	ctx.Logs = []EventLog{
+		transferLog(addrC, addrA, addrB, 100, false),
+		transferLog(addrD, addrA, addrB, 7, true), // 同名同 topic0 的 ERC-721 Transfer 不匹配
+		transferLog(addrD, addrB, addrC, 250, false),
+		{Address: addrC, Topics: []string{crypto.Keccak256Hash([]byte("Approval(address,address,uint256)")).Hex()}},
+		transferLog(addrC, addrA, addrD, 50, false),
+	}
+	scoped, err := extractor.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"transfer_count": "3",
+		"first_sender":   common.HexToAddress(addrA).Hex(),
+		"total":          "400",
+		"largest":        "250",
+		"tokens":         fmt.Sprint([]interface{}{addrC, addrD}),
+		"last_recipient": common.HexToAddress(addrD).Hex(),
+		"approvals":      "0", // topic 数量与签名不符的日志不匹配
+	}
+	for name, value := range want {
+		if got, ok := scoped.ExtractedData[name]; !ok || fmt.Sprint(got) != value {
+			t.Errorf("%s = %v (present %v), want %s", name, got, ok, value)
+		}
+	}
+	if got, ok := scoped.ExtractedData["allowance"]; ok {
+		t.Errorf("allowance = %v, want missing without matching events", got)
+	}
+	if len(ctx.ExtractedData) != 0 {
+		t.Errorf("original context modified: %v", ctx.ExtractedData)
+	}
+}
+
+func TestParseEventSignatureErrors(t *testing.T) {
+	tests := []struct {
+		signature string
+		wantErr   string
+	}{
+		{"Transfer", `event must be a full signature such as "Transfer(address indexed from, address indexed to, uint256 value)"`},
+		{"Swap((uint256,address) data)", "event Swap: tuple parameters are not supported"},
+		{"Transfer(address from, , uint256 value)", "event Transfer: parameter 1 is empty"},
+		{"Transfer(address indexed from to)", `event Transfer: invalid parameter "address indexed from to"`},
+		{"Transfer(address value, uint256 value)", "event Transfer: duplicate parameter value"},
+		{"Transfer(address address)", "event Transfer: duplicate parameter address"},
+		{"Transfer(uint value)", "event Transfer: unsupported arg type: uint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			_, err := parseEventSignature(tt.signature)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("parseEventSignature(%q) error = %v, want %s", tt.signature, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractCustom(t *testing.T) {
+	rule := testRule("custom")
+	rule.Extract.CallStack = []ExtractField{{Field: "count", As: "calls"}, {Field: "depth", As: "max_depth"}}
+	rule.Extract.Custom = map[string][]ExtractField{
+		// 分组按名称排序执行，同一分组内按声明顺序，可引用之前提取的变量
+		"b_derived": {
+			{Field: "deep_calls * 2", As: "double"},
+		},
+		"a_base": {
+			{Field: "calls - max_depth", As: "deep_calls"},
+			{Field: "nonce + 1", As: "next_nonce"}, // 没有交易时取不到，不写入
+			{Field: "nonce ?? 0", As: "nonce_or_zero"},
+			{Field: `any(call_stack, .type == "DELEGATECALL")`, As: "delegates"},
+		},
+	}
+	extractor, issues := rule.compileExtract(rule.variableSchema())
+	if len(issues) > 0 {
+		t.Fatal(issues)
+	}
+
+	ctx := NewEvaluationContext(nil, nil)
+	ctx.CallStack = reentrancyCallStack()
+	scoped, err := extractor.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"calls":         "5",
+		"max_depth":     "2",
+		"deep_calls":    "3",
+		"double":        "6",
+		"nonce_or_zero": "0",
+		"delegates":     "true",
+	}
+	for name, value := range want {
+		if got, ok := scoped.ExtractedData[name]; !ok || fmt.Sprint(got) != value {
+			t.Errorf("%s = %v (present %v), want %s", name, got, ok, value)
+		}
+	}
+	if got, ok := scoped.ExtractedData["next_nonce"]; ok {
+		t.Errorf("next_nonce = %v, want missing", got)
+	}
+}
+
+func TestExtractCustomErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  map[string][]ExtractField
+		wantErr string
+	}{
+		{"empty expression", map[string][]ExtractField{"g": {{Field: " ", As: "x"}}},
+			"extract.custom.g[0].field: expression is required"},
+		{"unknown variable", map[string][]ExtractField{"g": {{Field: "unknown_var + 1", As: "x"}}},
+			`extract.custom.g[0].field: column 1: unknown variable "unknown_var"`},
+		// 分组按名称排序，a 中不能引用 b 中提取的变量
+		{"later group", map[string][]ExtractField{"a": {{Field: "y + 1", As: "x"}}, "b": {{Field: "1", As: "y"}}},
+			`extract.custom.a[0].field: column 1: unknown variable "y"`},
+		{"duplicate variable", map[string][]ExtractField{"g": {{Field: "1", As: "x"}, {Field: "2", As: "x"}}},
+			"extract.custom.g[1].as: variable x is already extracted"},
+		{"missing as", map[string][]ExtractField{"g": {{Field: "1"}}},
+			"extract.custom.g[0].as: as is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := testRule("custom")
+			rule.Extract.Custom = tt.custom
+			_, issues := rule.compileExtract(rule.variableSchema())
+			if len(issues) != 1 || issues[0].Error() != tt.wantErr {
+				t.Errorf("compileExtract() issues = %v, want %s", issues, tt.wantErr)
+			}
+		})
+	}
+}
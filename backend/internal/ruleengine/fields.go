@@ -0,0 +1,244 @@
+package ruleengine
+
+//go:generate go run gen_fields.go
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Field 规则表达式可引用的内置变量
+// 变量表（类型检查）、求值器取值和变量文档都由 fieldRegistry 生成
+type Field struct {
+	Name        string
+	Group       string // 文档分组：交易、费用、区块、调用、事件、状态
+	Type        *ExprType
+	Description string
+	// Get 从上下文中取值，数据不可用（如缺少区块信息）时返回 false
+	Get func(ctx *EvaluationContext) (interface{}, bool)
+}
+
+// fieldGroups 文档中的分组顺序
+var fieldGroups = []string{"交易", "费用", "区块", "调用", "事件", "状态"}
+
+// fieldRegistry 内置变量注册表
+var fieldRegistry = []*Field{
+	// 交易
+	{Name: "tx_hash", Group: "交易", Type: stringType, Description: "交易哈希",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.TxHash })},
+	{Name: "from_address", Group: "交易", Type: addressType, Description: "发送方地址",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.FromAddress })},
+	{Name: "to_address", Group: "交易", Type: addressType, Description: "接收方地址，合约创建交易为空字符串",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.ToAddress })},
+	{Name: "is_contract_creation", Group: "交易", Type: boolType, Description: "是否为合约创建交易",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.ToAddress == "" })},
+	{Name: "value", Group: "交易", Type: uint256Type, Description: "转账金额（wei）",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) {
+			if ctx.Transaction == nil {
+				return nil, false
+			}
+			// wei 金额可能超过 int64，按任意精度整数解析
+			val, err := parseBigInt(ctx.Transaction.Value)
+			if err != nil {
+				return nil, false
+			}
+			return val, true
+		}},
+	{Name: "nonce", Group: "交易", Type: uint256Type, Description: "发送方 nonce",
+		Get: msgField(func(m *TransactionData) interface{} { return m.Nonce })},
+	{Name: "tx_type", Group: "交易", Type: uint256Type, Description: "交易类型：0 legacy、1 access list、2 EIP-1559、3 blob",
+		Get: msgField(func(m *TransactionData) interface{} { return m.TxType })},
+	{Name: "status", Group: "交易", Type: uint256Type, Description: "执行状态：1 成功、0 失败",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.Status })},
+	{Name: "input_data", Group: "交易", Type: stringType, Description: "调用数据（0x 开头的十六进制）",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.InputData })},
+	{Name: "function_selector", Group: "交易", Type: stringType, Description: "函数选择器（input 前 4 字节）",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) {
+			if ctx.Message != nil {
+				return ctx.Message.FunctionSelector, true
+			}
+			if ctx.Transaction != nil && len(ctx.Transaction.InputData) >= 10 {
+				return ctx.Transaction.InputData[:10], true
+			}
+			return nil, false
+		}},
+	{Name: "timestamp", Group: "交易", Type: uint256Type, Description: "交易所在区块的时间（Unix 秒）",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) {
+			switch {
+			case ctx.Block != nil && !ctx.Block.Timestamp.IsZero():
+				return ctx.Block.Timestamp.Unix(), true
+			case ctx.Transaction != nil && !ctx.Transaction.Timestamp.IsZero():
+				return ctx.Transaction.Timestamp.Unix(), true
+			}
+			return nil, false
+		}},
+
+	// 费用
+	{Name: "gas_price", Group: "费用", Type: uint256Type, Description: "实际 gas 价格（wei）",
+		Get: txField(func(ctx *EvaluationContext) interface{} { return ctx.Transaction.GasPrice })},
+	{Name: "gas_used", Group: "费用", Type: uint256Type, Description: "实际消耗的 gas",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.GasUsed, true }},
+	{Name: "gas_limit", Group: "费用", Type: uint256Type, Description: "交易 gas 上限",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.GasLimit, true }},
+	{Name: "base_fee", Group: "费用", Type: uint256Type, Description: "所在区块的 base fee（wei），EIP-1559 之前为 0",
+		Get: blockMsgField(func(b *BlockData) interface{} { return b.BaseFee })},
+	{Name: "priority_fee", Group: "费用", Type: uint256Type, Description: "实际支付的小费（gas_price - base_fee）",
+		Get: msgField(func(m *TransactionData) interface{} { return m.PriorityFee() })},
+	{Name: "max_fee_per_gas", Group: "费用", Type: uint256Type, Description: "EIP-1559 最高 gas 价格，legacy 交易与 gas_price 相同",
+		Get: msgField(func(m *TransactionData) interface{} { return m.MaxFeePerGas })},
+	{Name: "max_priority_fee_per_gas", Group: "费用", Type: uint256Type, Description: "EIP-1559 最高小费",
+		Get: msgField(func(m *TransactionData) interface{} { return m.MaxPriorityFeePerGas })},
+
+	// 区块
+	{Name: "block_number", Group: "区块", Type: uint256Type, Description: "区块号",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) {
+			switch {
+			case ctx.Block != nil:
+				return ctx.Block.BlockNumber, true
+			case ctx.Transaction != nil:
+				return ctx.Transaction.BlockNumber, true
+			}
+			return nil, false
+		}},
+	{Name: "block.number", Group: "区块", Type: uint256Type, Description: "区块号",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.BlockNumber })},
+	{Name: "block.hash", Group: "区块", Type: stringType, Description: "区块哈希",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.BlockHash })},
+	{Name: "block.parent_hash", Group: "区块", Type: stringType, Description: "父区块哈希",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.ParentHash })},
+	{Name: "block.miner", Group: "区块", Type: addressType, Description: "出块者（coinbase）地址",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.Miner })},
+	{Name: "block.timestamp", Group: "区块", Type: uint256Type, Description: "区块时间（Unix 秒）",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.Timestamp.Unix() })},
+	{Name: "block.gas_used", Group: "区块", Type: uint256Type, Description: "区块总 gas 消耗",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.GasUsed })},
+	{Name: "block.gas_limit", Group: "区块", Type: uint256Type, Description: "区块 gas 上限",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.GasLimit })},
+	{Name: "block.base_fee", Group: "区块", Type: uint256Type, Description: "区块 base fee（wei）",
+		Get: blockMsgField(func(b *BlockData) interface{} { return b.BaseFee })},
+	{Name: "block.transaction_count", Group: "区块", Type: uint256Type, Description: "区块内交易数",
+		Get: blockField(func(ctx *EvaluationContext) interface{} { return ctx.Block.TransactionCount })},
+
+	// 调用
+	{Name: "call_depth", Group: "调用", Type: uint256Type, Description: "最大调用深度",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.CallDepth, true }},
+	{Name: "call_count", Group: "调用", Type: uint256Type, Description: "调用帧数量",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.CallCount, true }},
+	{Name: "call_trace", Group: "调用", Type: listOf(addressType), Description: "按调用顺序排列的被调用地址",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) {
+			trace := make([]interface{}, len(ctx.CallTrace))
+			for i, addr := range ctx.CallTrace {
+				trace[i] = addr
+			}
+			return trace, true
+		}},
+	{Name: "call_stack", Group: "调用", Type: listOf(callFrameType), Description: "调用帧列表，配合量词使用",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.collection("call_stack") }},
+	{Name: "reentrancy_detected", Group: "调用", Type: boolType, Description: "调用栈中是否出现重入模式（A->B->A）",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return false, true }},
+
+	// 事件
+	{Name: "events", Group: "事件", Type: listOf(eventLogType), Description: "事件日志列表，配合量词使用",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.collection("events") }},
+	{Name: "event_count", Group: "事件", Type: uint256Type, Description: "事件日志数量",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return len(ctx.Logs), true }},
+
+	// 状态
+	{Name: "state_diff", Group: "状态", Type: listOf(accountDiffType), Description: "被修改账户的状态差异（余额、nonce、代码），配合量词使用",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.collection("state_diff") }},
+	{Name: "storage_changes", Group: "状态", Type: listOf(storageChangeType), Description: "被修改的存储槽，配合量词使用",
+		Get: func(ctx *EvaluationContext) (interface{}, bool) { return ctx.collection("storage_changes") }},
+}
+
+// fieldIndex 按变量名索引注册表
+var fieldIndex = func() map[string]*Field {
+	index := make(map[string]*Field, len(fieldRegistry))
+	for _, f := range fieldRegistry {
+		index[f.Name] = f
+	}
+	return index
+}()
+
+// txField 读取 models.Transaction 中的字段
+func txField(get func(ctx *EvaluationContext) interface{}) func(*EvaluationContext) (interface{}, bool) {
+	return func(ctx *EvaluationContext) (interface{}, bool) {
+		if ctx.Transaction == nil {
+			return nil, false
+		}
+		return get(ctx), true
+	}
+}
+
+// blockField 读取 models.Block 中的字段
+func blockField(get func(ctx *EvaluationContext) interface{}) func(*EvaluationContext) (interface{}, bool) {
+	return func(ctx *EvaluationContext) (interface{}, bool) {
+		if ctx.Block == nil {
+			return nil, false
+		}
+		return get(ctx), true
+	}
+}
+
+// msgField 读取原始消息中 models 没有的字段
+func msgField(get func(m *TransactionData) interface{}) func(*EvaluationContext) (interface{}, bool) {
+	return func(ctx *EvaluationContext) (interface{}, bool) {
+		if ctx.Message == nil {
+			return nil, false
+		}
+		return get(ctx.Message), true
+	}
+}
+
+// blockMsgField 读取原始消息中的区块字段
+func blockMsgField(get func(b *BlockData) interface{}) func(*EvaluationContext) (interface{}, bool) {
+	return func(ctx *EvaluationContext) (interface{}, bool) {
+		if ctx.Message == nil || ctx.Message.Block == nil {
+			return nil, false
+		}
+		return get(ctx.Message.Block), true
+	}
+}
+
+// LookupField 按名称查找内置变量
+func LookupField(name string) (*Field, bool) {
+	f, ok := fieldIndex[name]
+	return f, ok
+}
+
+// Fields 返回所有内置变量
+func Fields() []*Field {
+	return fieldRegistry
+}
+
+// FieldsMarkdown 生成内置变量文档（Markdown），包括调用帧与事件元素的字段
+func FieldsMarkdown() string {
+	var sb strings.Builder
+	sb.WriteString("# 规则变量\n\n")
+	sb.WriteString("<!-- 由 go generate ./internal/ruleengine 生成，请勿手工修改 -->\n\n")
+	sb.WriteString("规则表达式可以直接引用以下内置变量；`extract` 中声明的 `as` 名称同样可以引用，类型在运行时确定。\n")
+
+	for _, group := range fieldGroups {
+		fmt.Fprintf(&sb, "\n## %s\n\n", group)
+		sb.WriteString("| 变量 | 类型 | 说明 |\n|------|------|------|\n")
+		for _, f := range fieldRegistry {
+			if f.Group == group {
+				fmt.Fprintf(&sb, "| `%s` | `%s` | %s |\n", f.Name, f.Type, f.Description)
+			}
+		}
+	}
+
+	for _, t := range []*ExprType{callFrameType, eventLogType, accountDiffType, storageChangeType} {
+		fmt.Fprintf(&sb, "\n## %s 字段\n\n", t.Name)
+		sb.WriteString("| 字段 | 类型 |\n|------|------|\n")
+		names := make([]string, 0, len(t.Fields))
+		for name := range t.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&sb, "| `.%s` | `%s` |\n", name, t.Fields[name])
+		}
+	}
+	return sb.String()
+}
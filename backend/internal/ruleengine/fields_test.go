@@ -0,0 +1,144 @@
+package ruleengine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestFieldRegistry(t *testing.T) {
+	schema := DefaultSchema()
+	groups := make(map[string]bool, len(fieldGroups))
+	for _, group := range fieldGroups {
+		groups[group] = true
+	}
+	seen := make(map[string]bool, len(fieldRegistry))
+	for _, f := range Fields() {
+		if seen[f.Name] {
+			t.Errorf("field %s registered twice", f.Name)
+		}
+		seen[f.Name] = true
+		if !groups[f.Group] {
+			t.Errorf("field %s has unknown group %q", f.Name, f.Group)
+		}
+		if f.Get == nil || f.Type == nil || f.Description == "" {
+			t.Errorf("field %s is incomplete", f.Name)
+		}
+		if got, ok := LookupField(f.Name); !ok || got != f {
+			t.Errorf("LookupField(%q) = %v, %v", f.Name, got, ok)
+		}
+		if got := schema[f.Name]; got != f.Type {
+			t.Errorf("DefaultSchema()[%q] = %v, want %v", f.Name, got, f.Type)
+		}
+	}
+	if _, ok := LookupField("valeu"); ok {
+		t.Error(`LookupField("valeu") found a field`)
+	}
+
+	// 变量文档由注册表生成，修改注册表后须重新生成
+	doc, err := os.ReadFile("../../../docs/rule-variables.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != FieldsMarkdown() {
+		t.Error("docs/rule-variables.md is out of date, run go generate ./internal/ruleengine")
+	}
+}
+
+func TestFieldGet(t *testing.T) {
+	message := &TransactionData{
+		TxHash:               "0x01",
+		BlockNumber:          100,
+		FromAddress:          addrA,
+		ToAddress:            addrB,
+		Value:                "115792089237316195423570985008687907853269984665640564039457584007913129639935",
+		Nonce:                7,
+		TxType:               2,
+		GasPrice:             30,
+		MaxFeePerGas:         50,
+		MaxPriorityFeePerGas: 5,
+		GasUsed:              21000,
+		GasLimit:             30000,
+		Status:               1,
+		Timestamp:            1700000000,
+		FunctionSelector:     "0xa9059cbb",
+		InputData:            "0xa9059cbb00",
+		Block: &BlockData{
+			Number:           100,
+			Hash:             "0xb1",
+			ParentHash:       "0xb0",
+			Miner:            addrC,
+			Timestamp:        1700000012,
+			GasUsed:          1000000,
+			GasLimit:         30000000,
+			BaseFee:          26,
+			TransactionCount: 3,
+		},
+	}
+	// legacy 消息没有 block 字段，区块号与时间取自交易
+	legacy := *message
+	legacy.Block = nil
+
+	newContext := func(m *TransactionData) *EvaluationContext {
+		ctx := NewEvaluationContext(m.ToTransaction(), m.ToBlock())
+		ctx.Message = m
+		return ctx
+	}
+	full, old, empty := newContext(message), newContext(&legacy), NewEvaluationContext(nil, nil)
+
+	tests := []struct {
+		field string
+		ctx   *EvaluationContext
+		want  string // fmt.Sprint 后的值，空字符串表示数据不可用
+	}{
+		{"tx_hash", full, "0x01"},
+		{"from_address", full, addrA},
+		{"is_contract_creation", full, "false"},
+		{"value", full, message.Value},
+		{"nonce", full, "7"},
+		{"tx_type", full, "2"},
+		{"status", full, "1"},
+		{"function_selector", full, "0xa9059cbb"},
+		{"timestamp", full, "1700000012"},
+		{"base_fee", full, "26"},
+		{"priority_fee", full, "4"},
+		{"max_fee_per_gas", full, "50"},
+		{"block_number", full, "100"},
+		{"block.hash", full, "0xb1"},
+		{"block.parent_hash", full, "0xb0"},
+		{"block.miner", full, addrC},
+		{"block.timestamp", full, "1700000012"},
+		{"block.gas_limit", full, "30000000"},
+		{"block.base_fee", full, "26"},
+		{"block.transaction_count", full, "3"},
+		{"timestamp", old, "1700000000"},
+		{"block.number", old, "100"},
+		{"base_fee", old, ""},
+		{"priority_fee", old, "0"},
+		{"tx_hash", empty, ""},
+		{"value", empty, ""},
+		{"nonce", empty, ""},
+		{"function_selector", empty, ""},
+		{"timestamp", empty, ""},
+		{"block_number", empty, ""},
+		{"block.number", empty, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			f, ok := LookupField(tt.field)
+			if !ok {
+				t.Fatalf("unknown field %s", tt.field)
+			}
+			val, ok := f.Get(tt.ctx)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("%s = %v, want unavailable", tt.field, val)
+				}
+				return
+			}
+			if !ok || fmt.Sprint(val) != tt.want {
+				t.Errorf("%s = %v, %v, want %s", tt.field, val, ok, tt.want)
+			}
+		})
+	}
+}
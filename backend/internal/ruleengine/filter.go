@@ -0,0 +1,144 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FilterDecision 地址过滤结果
+type FilterDecision int
+
+const (
+	FilterNone  FilterDecision = iota // 未命中任何名单，正常评估
+	FilterAllow                       // 命中白名单/允许名单，跳过
+	FilterDeny                        // 命中黑名单/拒绝名单，升级处理
+)
+
+func (d FilterDecision) String() string {
+	switch d {
+	case FilterAllow:
+		return "allow"
+	case FilterDeny:
+		return "deny"
+	default:
+		return "none"
+	}
+}
+
+// NormalizeAddress 规范化地址：去掉首尾空白并转为小写，非法地址返回错误
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if !common.IsHexAddress(address) || !strings.HasPrefix(strings.ToLower(address), "0x") {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	return strings.ToLower(address), nil
+}
+
+// addressSet 小写地址集合
+type addressSet map[string]struct{}
+
+// newAddressSet 由地址列表构造集合，返回第一个非法地址的下标及错误
+func newAddressSet(addresses []string) (addressSet, int, error) {
+	set := make(addressSet, len(addresses))
+	for i, address := range addresses {
+		normalized, err := NormalizeAddress(address)
+		if err != nil {
+			return nil, i, err
+		}
+		set[normalized] = struct{}{}
+	}
+	return set, 0, nil
+}
+
+// match 返回 candidates 中第一个在集合内的地址
+func (s addressSet) match(candidates ...string) (string, bool) {
+	if len(s) == 0 {
+		return "", false
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		address := strings.ToLower(candidate)
+		if _, ok := s[address]; ok {
+			return address, true
+		}
+	}
+	return "", false
+}
+
+// txParties 返回交易的发送方与接收方（合约创建时接收方为空）
+func txParties(ctx *EvaluationContext) (from, to string) {
+	if ctx.Transaction != nil {
+		return ctx.Transaction.FromAddress, ctx.Transaction.ToAddress
+	}
+	return "", ""
+}
+
+// callParticipants 返回交易及调用栈中出现的所有地址
+func callParticipants(ctx *EvaluationContext) []string {
+	from, to := txParties(ctx)
+	addresses := make([]string, 0, 2+2*len(ctx.CallStack))
+	addresses = append(addresses, from, to)
+	for _, frame := range ctx.CallStack {
+		addresses = append(addresses, frame.From, frame.To)
+	}
+	return addresses
+}
+
+// ruleFilter 规则级的 whitelist/blacklist
+// whitelist.addresses 匹配交易发送方，whitelist.contracts 匹配交易直接调用的合约，命中时跳过规则；
+// blacklist.addresses 匹配发送方或接收方，blacklist.contracts 匹配调用栈中的任意合约，命中时不受白名单影响，仍按触发条件评估
+// 白名单只看交易本身，避免攻击经由白名单合约中转时被放过
+type ruleFilter struct {
+	whitelistAddresses addressSet
+	whitelistContracts addressSet
+	blacklistAddresses addressSet
+	blacklistContracts addressSet
+}
+
+// compileFilters 编译规则的 filters，地址非法时返回其在规则 YAML 中的路径
+func compileFilters(filters RuleFilters) (*ruleFilter, []ruleIssue) {
+	f := &ruleFilter{}
+	var issues []ruleIssue
+	build := func(addresses []string, keys ...interface{}) addressSet {
+		set, i, err := newAddressSet(addresses)
+		if err != nil {
+			issues = append(issues, ruleIssue{keys: append(keys, i), err: err})
+		}
+		return set
+	}
+
+	f.whitelistAddresses = build(filters.Whitelist.Addresses, "filters", "whitelist", "addresses")
+	f.whitelistContracts = build(filters.Whitelist.Contracts, "filters", "whitelist", "contracts")
+	f.blacklistAddresses = build(filters.Blacklist.Addresses, "filters", "blacklist", "addresses")
+	f.blacklistContracts = build(filters.Blacklist.Contracts, "filters", "blacklist", "contracts")
+	return f, issues
+}
+
+// Check 检查交易是否命中规则的名单，黑名单优先
+func (f *ruleFilter) Check(ctx *EvaluationContext) (FilterDecision, string) {
+	from, to := txParties(ctx)
+	if address, ok := f.blacklistAddresses.match(from, to); ok {
+		return FilterDeny, address
+	}
+	if address, ok := f.blacklistContracts.match(to); ok {
+		return FilterDeny, address
+	}
+	if len(f.blacklistContracts) > 0 {
+		for _, frame := range ctx.CallStack {
+			if address, ok := f.blacklistContracts.match(frame.To); ok {
+				return FilterDeny, address
+			}
+		}
+	}
+	if address, ok := f.whitelistAddresses.match(from); ok {
+		return FilterAllow, address
+	}
+	if address, ok := f.whitelistContracts.match(to); ok {
+		return FilterAllow, address
+	}
+	return FilterNone, ""
+}
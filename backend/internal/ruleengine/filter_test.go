@@ -0,0 +1,67 @@
+package ruleengine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/haswell/bcscan/internal/models"
+)
+
+func TestCompiledRuleMatchFilters(t *testing.T) {
+	// addrA 为白名单中的机器人，addrB 为黑名单中的攻击者，addrC 为白名单合约，addrD 为黑名单合约
+	const other = "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+	rule := testRule("filtered")
+	rule.Filters = RuleFilters{
+		// 名单地址不区分大小写
+		Whitelist: FilterList{Addresses: []string{addrA}, Contracts: []string{"0x" + strings.ToUpper(addrC[2:])}},
+		Blacklist: FilterList{Addresses: []string{addrB}, Contracts: []string{addrD}},
+	}
+	rule.Triggers.Conditions = []RuleCondition{{Expression: "flagged"}}
+	compiled, err := CompileRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		calls    []string // 调用栈中的合约
+		flagged  bool     // 触发条件的结果
+		decision FilterDecision
+		matched  bool
+	}{
+		{name: "unlisted", from: other, to: other, flagged: true, decision: FilterNone, matched: true},
+		{name: "unlisted without trigger", from: other, to: other, decision: FilterNone},
+		{name: "whitelisted sender", from: addrA, to: other, flagged: true, decision: FilterAllow},
+		{name: "whitelisted contract", from: other, to: strings.ToUpper(addrC), flagged: true, decision: FilterAllow},
+		// 白名单只看交易本身，经由白名单合约中转的调用不被放过
+		{name: "whitelisted contract in call stack", from: other, to: other, calls: []string{addrC}, flagged: true, decision: FilterNone, matched: true},
+		{name: "blacklisted sender", from: addrB, to: other, flagged: true, decision: FilterDeny, matched: true},
+		// 黑名单只决定优先级，本身不会让规则命中
+		{name: "blacklisted without trigger", from: addrB, to: other, decision: FilterDeny},
+		{name: "blacklisted contract in call stack", from: other, to: addrC, calls: []string{addrC, addrD}, flagged: true, decision: FilterDeny, matched: true},
+		// 同时命中时黑名单优先，照常评估触发条件
+		{name: "whitelisted and blacklisted", from: addrA, to: addrD, flagged: true, decision: FilterDeny, matched: true},
+		{name: "whitelisted and blacklisted without trigger", from: addrA, to: addrD, decision: FilterDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewEvaluationContext(&models.Transaction{FromAddress: tt.from, ToAddress: tt.to}, nil)
+			ctx.ExtractedData["flagged"] = tt.flagged
+			for _, address := range tt.calls {
+				ctx.CallStack = append(ctx.CallStack, CallFrame{To: address})
+			}
+
+			if decision, _ := compiled.Filter.Check(ctx); decision != tt.decision {
+				t.Errorf("Check() = %s, want %s", decision, tt.decision)
+			}
+			matched, err := compiled.Match(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tt.matched {
+				t.Errorf("Match() = %v, want %v", matched, tt.matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+//go:build ignore
+
+// 生成规则变量文档：go generate ./internal/ruleengine
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/haswell/bcscan/internal/ruleengine"
+)
+
+func main() {
+	const output = "../../../docs/rule-variables.md"
+	if err := os.WriteFile(output, []byte(ruleengine.FieldsMarkdown()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
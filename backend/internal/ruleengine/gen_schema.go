@@ -0,0 +1,22 @@
+//go:build ignore
+
+// 生成规则文件 JSON Schema：go generate ./internal/ruleengine
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/haswell/bcscan/internal/ruleengine"
+)
+
+func main() {
+	const output = "../../../docs/rule.schema.json"
+	schema, err := ruleengine.RuleJSONSchema()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(output, append(schema, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
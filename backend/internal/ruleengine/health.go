@@ -0,0 +1,307 @@
+package ruleengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/haswell/bcscan/internal/cache"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	RuleHealthKey     = "rules:health"        // Redis hash：规则名 -> RuleStatus
+	RuleHealthChannel = "rules:health:update" // 规则状态变更通知（隔离、重新启用）
+
+	// DefaultMaxRuleFailures 规则出错超过该次数后自动隔离
+	DefaultMaxRuleFailures = 10
+)
+
+// 规则运行状态
+const (
+	RuleStateActive      = "active"
+	RuleStateQuarantined = "quarantined"
+)
+
+// RuleStatus 规则运行状态
+type RuleStatus struct {
+	Name          string     `json:"name"`
+	State         string     `json:"state"`
+	Failures      int        `json:"failures"` // 自上次启用以来出错的交易数
+	LastError     string     `json:"last_error,omitempty"`
+	LastTx        string     `json:"last_tx,omitempty"` // 最近出错的交易哈希，同一交易多次出错只计一次
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+}
+
+// failureScript 记录一次失败并在超过阈值时隔离规则，计数与隔离在同一个脚本中原子更新，所有 RDS 实例累计同一个计数
+// KEYS[1] 规则状态（hash），ARGV[1] 规则名，ARGV[2] 交易哈希，ARGV[3] 错误信息，ARGV[4] 当前时间（RFC 3339），ARGV[5] 失败次数阈值
+// 同一交易只计一次；已隔离的规则保持隔离，只更新最近的错误
+// 返回 {状态 JSON, 是否本次隔离}
+var failureScript = redis.NewScript(`
+local raw = redis.call('HGET', KEYS[1], ARGV[1])
+local status = {name = ARGV[1], state = 'active', failures = 0}
+if raw then
+	status = cjson.decode(raw)
+	if ARGV[2] ~= '' and status.last_tx == ARGV[2] then
+		return {raw, 0}
+	end
+end
+status.failures = (tonumber(status.failures) or 0) + 1
+status.last_error = ARGV[3]
+status.last_failure_at = ARGV[4]
+status.last_tx = ARGV[2]
+local quarantined = 0
+if status.state ~= 'quarantined' and status.failures > tonumber(ARGV[5]) then
+	status.state = 'quarantined'
+	status.quarantined_at = ARGV[4]
+	quarantined = 1
+end
+local data = cjson.encode(status)
+redis.call('HSET', KEYS[1], ARGV[1], data)
+return {data, quarantined}
+`)
+
+// RuleError 单条规则执行失败
+type RuleError struct {
+	Rule string
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule %s: %v", e.Rule, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// RuleHealth 规则故障隔离
+// 每条规则的执行相互隔离（包括 panic），出错的交易数按规则统计（同一交易在评估、评分等阶段多次出错只计一次），
+// 超过阈值的规则被自动隔离，不再参与匹配，直到通过 Enable 重新启用；
+// 计数与状态保存在 Redis 中，所有 RDS 实例累计同一个计数，隔离与重新启用都会通知其他实例
+type RuleHealth struct {
+	mu          sync.RWMutex
+	statuses    map[string]*RuleStatus
+	maxFailures int
+	redis       *cache.RedisClient // 为空时只在内存中统计
+	logger      *zap.Logger
+}
+
+// NewRuleHealth 创建规则故障统计，maxFailures <= 0 时使用 DefaultMaxRuleFailures
+func NewRuleHealth(redis *cache.RedisClient, logger *zap.Logger, maxFailures int) *RuleHealth {
+	if maxFailures <= 0 {
+		maxFailures = DefaultMaxRuleFailures
+	}
+	return &RuleHealth{
+		statuses:    make(map[string]*RuleStatus),
+		maxFailures: maxFailures,
+		redis:       redis,
+		logger:      logger,
+	}
+}
+
+// Load 从 Redis 加载规则状态，已隔离的规则在重启后保持隔离
+func (h *RuleHealth) Load(ctx context.Context) error {
+	if h.redis == nil {
+		return nil
+	}
+	values, err := h.redis.HGetAll(ctx, RuleHealthKey)
+	if err != nil {
+		return fmt.Errorf("failed to load rule health: %w", err)
+	}
+
+	statuses := make(map[string]*RuleStatus, len(values))
+	for name, data := range values {
+		var status RuleStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			h.logger.Warn("Invalid rule status", zap.String("rule", name), zap.Error(err))
+			continue
+		}
+		statuses[name] = &status
+	}
+
+	h.mu.Lock()
+	h.statuses = statuses
+	h.mu.Unlock()
+	return nil
+}
+
+// Guard 隔离执行单条规则：捕获 panic，出错时计入该规则的失败次数并返回 *RuleError
+// evalCtx 为正在处理的交易，同一交易多次出错只计一次失败
+func (h *RuleHealth) Guard(ctx context.Context, rule *Rule, evalCtx *EvaluationContext, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			h.logger.Error("Rule panicked",
+				zap.String("rule", rule.Metadata.Name),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+		}
+		if err != nil {
+			var txHash string
+			if evalCtx != nil && evalCtx.Transaction != nil {
+				txHash = evalCtx.Transaction.TxHash
+			}
+			h.recordFailure(ctx, rule.Metadata.Name, txHash, err)
+			err = &RuleError{Rule: rule.Metadata.Name, Err: err}
+		}
+	}()
+	return fn()
+}
+
+// recordFailure 记录失败，超过阈值时隔离规则并通知其他实例；Redis 不可用时只在本实例内统计
+func (h *RuleHealth) recordFailure(ctx context.Context, name, txHash string, err error) {
+	now := time.Now()
+
+	status, quarantined, ok := h.recordRemote(ctx, name, txHash, err, now)
+	if !ok {
+		status, quarantined = h.recordLocal(name, txHash, err, now)
+	}
+	if !quarantined {
+		return
+	}
+
+	h.logger.Error("Rule quarantined after repeated failures",
+		zap.String("rule", name),
+		zap.Int("failures", status.Failures),
+		zap.String("last_error", status.LastError))
+	if h.redis == nil {
+		return
+	}
+	if err := h.redis.Publish(ctx, RuleHealthChannel, map[string]interface{}{
+		"rule":   name,
+		"action": "quarantine",
+	}); err != nil {
+		h.logger.Warn("Failed to publish rule quarantine", zap.String("rule", name), zap.Error(err))
+	}
+}
+
+// recordRemote 在 Redis 中累计失败次数（见 failureScript）并以结果更新本地状态，ok 为 false 表示 Redis 不可用
+func (h *RuleHealth) recordRemote(ctx context.Context, name, txHash string, err error, now time.Time) (RuleStatus, bool, bool) {
+	if h.redis == nil {
+		return RuleStatus{}, false, false
+	}
+	result, scriptErr := h.redis.RunScript(ctx, failureScript, []string{RuleHealthKey},
+		name, txHash, err.Error(), now.Format(time.RFC3339Nano), h.maxFailures)
+	if scriptErr != nil {
+		h.logger.Warn("Failed to record rule failure", zap.String("rule", name), zap.Error(scriptErr))
+		return RuleStatus{}, false, false
+	}
+
+	values, _ := result.([]interface{})
+	var status RuleStatus
+	if len(values) != 2 {
+		h.logger.Warn("Unexpected rule failure result", zap.String("rule", name), zap.Any("result", result))
+		return RuleStatus{}, false, false
+	}
+	data, _ := values[0].(string)
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		h.logger.Warn("Invalid rule status", zap.String("rule", name), zap.Error(err))
+		return RuleStatus{}, false, false
+	}
+	quarantined, _ := values[1].(int64)
+
+	h.mu.Lock()
+	h.statuses[name] = &status
+	h.mu.Unlock()
+	return status, quarantined == 1, true
+}
+
+// recordLocal 在本实例内累计失败次数
+func (h *RuleHealth) recordLocal(name, txHash string, err error, now time.Time) (RuleStatus, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := h.statusLocked(name)
+	if txHash != "" && status.LastTx == txHash {
+		return *status, false
+	}
+	status.Failures++
+	status.LastError = err.Error()
+	status.LastFailureAt = &now
+	status.LastTx = txHash
+	quarantined := status.State == RuleStateActive && status.Failures > h.maxFailures
+	if quarantined {
+		status.State = RuleStateQuarantined
+		status.QuarantinedAt = &now
+	}
+	return *status, quarantined
+}
+
+// statusLocked 返回规则状态，不存在时创建（调用方持有写锁）
+func (h *RuleHealth) statusLocked(name string) *RuleStatus {
+	status, ok := h.statuses[name]
+	if !ok {
+		status = &RuleStatus{Name: name, State: RuleStateActive}
+		h.statuses[name] = status
+	}
+	return status
+}
+
+// IsQuarantined 规则是否已被隔离
+func (h *RuleHealth) IsQuarantined(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.statuses[name]
+	return ok && status.State == RuleStateQuarantined
+}
+
+// Status 返回规则状态，没有记录的规则为 active
+func (h *RuleHealth) Status(name string) RuleStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if status, ok := h.statuses[name]; ok {
+		return *status
+	}
+	return RuleStatus{Name: name, State: RuleStateActive}
+}
+
+// Enable 重新启用规则并清零失败次数，通知其他实例刷新状态
+func (h *RuleHealth) Enable(ctx context.Context, name string) error {
+	h.mu.Lock()
+	status := h.statusLocked(name)
+	status.State = RuleStateActive
+	status.Failures = 0
+	status.LastTx = ""
+	status.QuarantinedAt = nil
+	snapshot := *status
+	h.mu.Unlock()
+
+	if h.redis == nil {
+		return nil
+	}
+	if err := h.redis.HSet(ctx, RuleHealthKey, name, &snapshot); err != nil {
+		return fmt.Errorf("failed to save rule status: %w", err)
+	}
+	return h.redis.Publish(ctx, RuleHealthChannel, map[string]interface{}{
+		"rule":   name,
+		"action": "enable",
+	})
+}
+
+// SubscribeUpdates 订阅规则状态变更，收到通知后从 Redis 刷新
+func (h *RuleHealth) SubscribeUpdates(ctx context.Context) {
+	if h.redis == nil {
+		return
+	}
+	pubsub := h.redis.Subscribe(ctx, RuleHealthChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-ch:
+			h.logger.Info("Received rule status update", zap.String("message", msg.Payload))
+			if err := h.Load(ctx); err != nil {
+				h.logger.Error("Failed to reload rule health", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package ruleengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/haswell/bcscan/internal/cache"
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+// testRedis 返回 BCSCAN_TEST_REDIS 指定的 Redis（如 localhost:6379），未设置时跳过测试
+// 测试只使用带唯一后缀的键或规则名，并在结束时清理
+func testRedis(t *testing.T) *cache.RedisClient {
+	t.Helper()
+	addr := os.Getenv("BCSCAN_TEST_REDIS")
+	if addr == "" {
+		t.Skip("BCSCAN_TEST_REDIS is not set")
+	}
+	client := cache.NewRedisClient(addr)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// txContext 构造只包含交易哈希的求值上下文
+func txContext(hash string) *EvaluationContext {
+	return NewEvaluationContext(&models.Transaction{TxHash: hash}, nil)
+}
+
+func testRule(name string) *Rule {
+	rule := &Rule{}
+	rule.Metadata.Name = name
+	return rule
+}
+
+func TestRuleHealthQuarantine(t *testing.T) {
+	tests := []struct {
+		name        string
+		txs         []string // 每个元素为一次失败所在的交易
+		failures    int
+		quarantined bool
+	}{
+		{name: "below threshold", txs: []string{"0x1", "0x2", "0x3"}, failures: 3},
+		{name: "above threshold", txs: []string{"0x1", "0x2", "0x3", "0x4"}, failures: 4, quarantined: true},
+		{name: "same transaction counts once", txs: []string{"0x1", "0x1", "0x1", "0x1"}, failures: 1},
+		{name: "repeated transaction in between", txs: []string{"0x1", "0x1", "0x2", "0x2", "0x3"}, failures: 3},
+		{name: "transactions without hash all count", txs: []string{"", "", "", ""}, failures: 4, quarantined: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewRuleHealth(nil, zap.NewNop(), 3)
+			rule := testRule("failing")
+			for _, tx := range tt.txs {
+				err := health.Guard(context.Background(), rule, txContext(tx), func() error {
+					return errors.New("boom")
+				})
+				var ruleErr *RuleError
+				if !errors.As(err, &ruleErr) || ruleErr.Rule != "failing" {
+					t.Fatalf("Guard error = %v, want *RuleError for failing", err)
+				}
+			}
+
+			status := health.Status("failing")
+			if status.Failures != tt.failures {
+				t.Errorf("failures = %d, want %d", status.Failures, tt.failures)
+			}
+			if got := health.IsQuarantined("failing"); got != tt.quarantined {
+				t.Errorf("quarantined = %v, want %v", got, tt.quarantined)
+			}
+			if status.LastError != "boom" {
+				t.Errorf("last error = %q, want boom", status.LastError)
+			}
+		})
+	}
+}
+
+func TestRuleHealthGuardPanic(t *testing.T) {
+	health := NewRuleHealth(nil, zap.NewNop(), 0)
+	err := health.Guard(context.Background(), testRule("panicking"), txContext("0x1"), func() error {
+		panic("nil map")
+	})
+	var ruleErr *RuleError
+	if !errors.As(err, &ruleErr) || ruleErr.Err.Error() != "panic: nil map" {
+		t.Fatalf("Guard error = %v, want panic wrapped in *RuleError", err)
+	}
+	if got := health.Status("panicking").Failures; got != 1 {
+		t.Errorf("failures = %d, want 1", got)
+	}
+
+	if err := health.Guard(context.Background(), testRule("ok"), txContext("0x1"), func() error { return nil }); err != nil {
+		t.Errorf("Guard error = %v, want nil", err)
+	}
+	if got := health.Status("ok"); got.State != RuleStateActive || got.Failures != 0 {
+		t.Errorf("status = %+v, want active without failures", got)
+	}
+}
+
+func TestRuleHealthEnable(t *testing.T) {
+	health := NewRuleHealth(nil, zap.NewNop(), 1)
+	rule := testRule("flaky")
+	fail := func() error { return errors.New("boom") }
+	for _, tx := range []string{"0x1", "0x2"} {
+		health.Guard(context.Background(), rule, txContext(tx), fail)
+	}
+	if !health.IsQuarantined("flaky") {
+		t.Fatal("rule not quarantined")
+	}
+
+	if err := health.Enable(context.Background(), "flaky"); err != nil {
+		t.Fatal(err)
+	}
+	if health.IsQuarantined("flaky") || health.Status("flaky").Failures != 0 {
+		t.Fatalf("status after enable = %+v", health.Status("flaky"))
+	}
+	// 启用后同一交易再次出错重新计数
+	health.Guard(context.Background(), rule, txContext("0x2"), fail)
+	if got := health.Status("flaky").Failures; got != 1 {
+		t.Errorf("failures after enable = %d, want 1", got)
+	}
+}
+
+// TestRuleHealthRedis 多个实例通过 failureScript 累计同一个计数，已隔离的规则不会被改回 active
+func TestRuleHealthRedis(t *testing.T) {
+	redis := testRedis(t)
+	ctx := context.Background()
+	name := fmt.Sprintf("test-health-%d", time.Now().UnixNano())
+	t.Cleanup(func() { redis.HDel(ctx, RuleHealthKey, name) })
+
+	a := NewRuleHealth(redis, zap.NewNop(), 2)
+	b := NewRuleHealth(redis, zap.NewNop(), 2)
+	rule := testRule(name)
+	fail := func() error { return errors.New("boom") }
+
+	a.Guard(ctx, rule, txContext("0x1"), fail)
+	b.Guard(ctx, rule, txContext("0x1"), fail) // 同一交易在另一个实例上出错，不重复计数
+	b.Guard(ctx, rule, txContext("0x2"), fail)
+	if got := b.Status(name).Failures; got != 2 {
+		t.Fatalf("failures = %d, want 2", got)
+	}
+	a.Guard(ctx, rule, txContext("0x3"), fail)
+	if !a.IsQuarantined(name) {
+		t.Fatalf("rule not quarantined after 3 failures across instances: %+v", a.Status(name))
+	}
+
+	b.Guard(ctx, rule, txContext("0x4"), fail)
+	if err := b.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status := b.Status(name)
+	if status.State != RuleStateQuarantined || status.Failures != 4 {
+		t.Errorf("status = %+v, want quarantined with 4 failures", status)
+	}
+}
@@ -0,0 +1,31 @@
+package hooks
+
+import (
+	"github.com/haswell/bcscan/internal/ruleengine"
+)
+
+// NewEvaluationContext 由交易消息创建评估上下文并填充运行时数据（调用栈、事件、状态差异等）
+// RDS 与规则测试共用，保证规则在两处看到相同的数据
+func NewEvaluationContext(txData *TransactionData) *ruleengine.EvaluationContext {
+	ctx := ruleengine.NewEvaluationContext(txData.ToTransaction(), txData.ToBlock())
+	ctx.Message = txData
+	ctx.CallStack = txData.CallStack
+	ctx.Logs = txData.Events
+	ctx.StateDiff = txData.StateDiff
+	ctx.StateChanges = ruleengine.StateChanges(txData.StateDiff)
+	ctx.CallDepth = CalculateMaxCallDepth(txData.CallStack)
+	ctx.CallCount = len(txData.CallStack)
+	ctx.GasUsed = txData.GasUsed
+	ctx.GasLimit = txData.GasLimit
+
+	// 填充调用轨迹
+	for _, frame := range txData.CallStack {
+		ctx.CallTrace = append(ctx.CallTrace, frame.To)
+	}
+
+	// 检测重入模式
+	if DetectReentrancyPattern(txData.CallStack) {
+		ctx.ExtractedData["reentrancy_detected"] = true
+	}
+	return ctx
+}
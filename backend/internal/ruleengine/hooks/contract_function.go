@@ -1,25 +1,37 @@
 package hooks
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"strings"
 
 	"github.com/haswell/bcscan/internal/ruleengine"
+	"go.uber.org/zap"
 )
 
 // ContractFunctionHook 合约函数调用钩子
 type ContractFunctionHook struct {
-	evaluator *ruleengine.Evaluator
+	programs *ruleengine.ProgramCache
+	health   *ruleengine.RuleHealth
 }
 
-func NewContractFunctionHook() *ContractFunctionHook {
+// NewContractFunctionHook 创建钩子
+// programs 为规则编译缓存，health 为规则故障统计，为空时分别使用独立的实例
+func NewContractFunctionHook(programs *ruleengine.ProgramCache, health *ruleengine.RuleHealth) *ContractFunctionHook {
+	if programs == nil {
+		programs = ruleengine.NewProgramCache()
+	}
+	if health == nil {
+		health = ruleengine.NewRuleHealth(nil, zap.NewNop(), 0)
+	}
 	return &ContractFunctionHook{
-		evaluator: ruleengine.NewEvaluator(),
+		programs: programs,
+		health:   health,
 	}
 }
 
 func (h *ContractFunctionHook) Name() string {
-	return "contract_function_call"
+	return ruleengine.HookContractFunctionCall
 }
 
 func (h *ContractFunctionHook) Match(txData *TransactionData) bool {
@@ -27,32 +39,61 @@ func (h *ContractFunctionHook) Match(txData *TransactionData) bool {
 	return txData.FunctionSelector != ""
 }
 
+// Execute 按 rules 的顺序依次评估规则，每条规则相互隔离：
+// 单条规则出错（包括 panic）不影响其他规则，错误汇总后与已产生的风险事件一起返回；
+// 设置了 stop_on_match 的规则命中后停止评估后续规则，只有影子规则及其比较的线上规则继续评估，
+// 产生的事件标记为 SilencedStopped，只用于影子比较
 func (h *ContractFunctionHook) Execute(ctx *ruleengine.EvaluationContext, rules []*ruleengine.Rule) ([]*RiskEvent, error) {
 	var events []*RiskEvent
+	var errs []error
+	compared := ruleengine.ShadowTargets(rules)
+	stopped := false
 
 	for _, rule := range rules {
 		if !rule.Metadata.Enabled {
 			continue
 		}
+		if stopped && !rule.IsShadow() && !compared[rule.Metadata.Name] {
+			continue
+		}
 
 		// 检查规则是否包含此 hook
-		if !h.hasHook(rule, "contract_function_call") {
+		if !h.hasHook(rule, ruleengine.HookContractFunctionCall) {
+			continue
+		}
+
+		// 跳过已隔离的规则
+		if h.health.IsQuarantined(rule.Metadata.Name) {
 			continue
 		}
 
 		// 评估规则
-		matched, err := h.evaluateRule(rule, ctx)
+		var matched bool
+		var ruleCtx *ruleengine.EvaluationContext
+		err := h.health.Guard(context.Background(), rule, ctx, func() (err error) {
+			matched, ruleCtx, err = h.evaluateRule(rule, ctx)
+			return err
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate rule %s: %w", rule.Metadata.Name, err)
+			errs = append(errs, err)
+			continue
 		}
 
 		if matched {
-			event := h.createRiskEvent(rule, ctx)
+			event := h.createRiskEvent(rule, ruleCtx)
+			if stopped {
+				event.Silenced = SilencedStopped
+			}
 			events = append(events, event)
+
+			// 命中后不再评估优先级更低的规则，影子规则不影响其他规则
+			if rule.Config.StopOnMatch && !rule.IsShadow() {
+				stopped = true
+			}
 		}
 	}
 
-	return events, nil
+	return events, errors.Join(errs...)
 }
 
 func (h *ContractFunctionHook) hasHook(rule *ruleengine.Rule, hookName string) bool {
@@ -64,49 +105,18 @@ func (h *ContractFunctionHook) hasHook(rule *ruleengine.Rule, hookName string) b
 	return false
 }
 
-func (h *ContractFunctionHook) evaluateRule(rule *ruleengine.Rule, ctx *ruleengine.EvaluationContext) (bool, error) {
-	if len(rule.Triggers.Conditions) == 0 {
-		return true, nil
-	}
-
-	operator := rule.Triggers.Operator
-	if operator == "" {
-		operator = "AND"
+// evaluateRule 先执行规则的数据提取，再在规则作用域的上下文上匹配触发条件
+func (h *ContractFunctionHook) evaluateRule(rule *ruleengine.Rule, ctx *ruleengine.EvaluationContext) (bool, *ruleengine.EvaluationContext, error) {
+	compiled, err := h.programs.Get(rule)
+	if err != nil {
+		return false, nil, err
 	}
-
-	results := make([]bool, 0, len(rule.Triggers.Conditions))
-
-	for _, condition := range rule.Triggers.Conditions {
-		result, err := h.evaluateCondition(condition, ctx)
-		if err != nil {
-			return false, err
-		}
-		results = append(results, result)
+	ruleCtx, err := compiled.Extract(ctx)
+	if err != nil {
+		return false, nil, err
 	}
-
-	if operator == "AND" {
-		for _, r := range results {
-			if !r {
-				return false, nil
-			}
-		}
-		return true, nil
-	} else if operator == "OR" {
-		for _, r := range results {
-			if r {
-				return true, nil
-			}
-		}
-		return false, nil
-	}
-
-	return false, fmt.Errorf("unsupported operator: %s", operator)
-}
-
-func (h *ContractFunctionHook) evaluateCondition(condition ruleengine.RuleCondition, ctx *ruleengine.EvaluationContext) (bool, error) {
-	// 构建表达式
-	expression := fmt.Sprintf("%s %s %v", condition.Type, condition.Operator, condition.Value)
-	return h.evaluator.Evaluate(expression, ctx)
+	matched, err := compiled.Match(ruleCtx)
+	return matched, ruleCtx, err
 }
 
 func (h *ContractFunctionHook) createRiskEvent(rule *ruleengine.Rule, ctx *ruleengine.EvaluationContext) *RiskEvent {
@@ -116,6 +126,7 @@ func (h *ContractFunctionHook) createRiskEvent(rule *ruleengine.Rule, ctx *rulee
 		Severity: rule.Config.Severity,
 		Score:    rule.Scoring.BaseScore,
 		Metadata: make(map[string]interface{}),
+		Context:  ctx,
 	}
 
 	if ctx.Transaction != nil {
@@ -39,12 +39,52 @@ func (m *Manager) Get(name string) (Hook, error) {
 	return hook, nil
 }
 
-// Trigger 触发指定钩子
-func (m *Manager) Trigger(hookName string, ctx *ruleengine.EvaluationContext, rules []*ruleengine.Rule) ([]*RiskEvent, error) {
+// Trigger 触发指定钩子，只评估规则集中作用域包含该交易的规则（见 ruleengine.RuleSet.Select）
+// 被其他规则抑制的事件与 stop_on_match 之后仅为影子比较产生的事件也会返回，带有 Silenced 标记，调用方不应对其执行动作
+func (m *Manager) Trigger(hookName string, ctx *ruleengine.EvaluationContext, set *ruleengine.RuleSet) ([]*RiskEvent, error) {
 	hook, err := m.Get(hookName)
 	if err != nil {
 		return nil, err
 	}
 
-	return hook.Execute(ctx, rules)
+	rules := set.Select(ctx)
+	events, err := hook.Execute(ctx, rules)
+	return suppress(events, rules), err
+}
+
+// suppress 按规则的 suppresses 关系将被抑制的风险事件标记为 SilencedSuppressed，并记录在抑制方的事件上
+// events 按规则评估顺序排列，只有先命中的规则能抑制后命中的规则；已被抑制或只用于影子比较的事件不再抑制其他事件
+func suppress(events []*RiskEvent, rules []*ruleengine.Rule) []*RiskEvent {
+	if len(events) < 2 {
+		return events
+	}
+
+	byName := make(map[string]*ruleengine.Rule, len(rules))
+	for _, rule := range rules {
+		byName[rule.Metadata.Name] = rule
+	}
+
+	suppressors := make(map[string]*RiskEvent) // 被抑制的规则 -> 抑制方事件
+	for _, event := range events {
+		if event.Silenced != "" {
+			continue
+		}
+		if by, ok := suppressors[event.RuleID]; ok {
+			by.Suppressed = append(by.Suppressed, event.RuleID)
+			event.Silenced = SilencedSuppressed
+			continue
+		}
+
+		// 影子规则会被抑制，但不抑制其他规则
+		rule, ok := byName[event.RuleID]
+		if !ok || rule.IsShadow() {
+			continue
+		}
+		for _, name := range rule.Config.Suppresses {
+			if _, ok := suppressors[name]; !ok {
+				suppressors[name] = event
+			}
+		}
+	}
+	return events
 }
@@ -0,0 +1,143 @@
+package hooks
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/haswell/bcscan/internal/ruleengine"
+)
+
+// ruleSpec 测试规则的配置，matched 为 false 的规则触发条件不成立
+type ruleSpec struct {
+	name       string
+	priority   int
+	matched    bool
+	stop       bool
+	suppresses []string
+	shadowOf   string // 非空时为该规则的影子规则
+}
+
+func buildRules(specs []ruleSpec) []*ruleengine.Rule {
+	rules := make([]*ruleengine.Rule, len(specs))
+	for i, spec := range specs {
+		rule := &ruleengine.Rule{}
+		rule.Metadata.Name = spec.name
+		rule.Metadata.Enabled = true
+		rule.Config.Priority = spec.priority
+		rule.Config.Hooks = []string{ruleengine.HookContractFunctionCall}
+		rule.Config.StopOnMatch = spec.stop
+		rule.Config.Suppresses = spec.suppresses
+		if spec.shadowOf != "" {
+			rule.Config.Mode = ruleengine.RuleModeShadow
+			rule.Config.ShadowOf = spec.shadowOf
+		}
+		if !spec.matched {
+			rule.Triggers.Conditions = []ruleengine.RuleCondition{{Expression: "false"}}
+		}
+		rules[i] = rule
+	}
+	return rules
+}
+
+func TestTrigger(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []ruleSpec
+		want  []string // 按评估顺序：规则名、Silenced 标记与被其抑制的规则
+	}{
+		{
+			name: "evaluation order",
+			rules: []ruleSpec{
+				{name: "b", priority: 10, matched: true},
+				{name: "low", priority: 1, matched: true},
+				{name: "a", priority: 10, matched: true},
+				{name: "high", priority: 100, matched: true},
+				{name: "miss", priority: 50},
+			},
+			want: []string{"high", "a", "b", "low"},
+		},
+		{
+			name: "suppression",
+			rules: []ruleSpec{
+				{name: "critical", priority: 100, matched: true, suppresses: []string{"medium", "unmatched"}},
+				{name: "high", priority: 90, matched: true, suppresses: []string{"low"}},
+				{name: "medium", priority: 50, matched: true, suppresses: []string{"minor"}},
+				{name: "unmatched", priority: 40},
+				{name: "low", priority: 10, matched: true},
+				// 被抑制的规则不再抑制其他规则
+				{name: "minor", priority: 5, matched: true},
+				// 低优先级规则不能抑制先评估的规则
+				{name: "late", priority: 1, matched: true, suppresses: []string{"critical"}},
+			},
+			want: []string{"critical [medium]", "high [low]", "medium suppressed", "low suppressed", "minor", "late"},
+		},
+		{
+			name: "shadow rules are suppressed but do not suppress",
+			rules: []ruleSpec{
+				{name: "live", priority: 100, matched: true, suppresses: []string{"candidate"}},
+				{name: "candidate", priority: 50, matched: true, shadowOf: "live", suppresses: []string{"other"}},
+				{name: "other", priority: 10, matched: true},
+			},
+			want: []string{"live [candidate]", "candidate suppressed", "other"},
+		},
+		{
+			name: "stop on match",
+			rules: []ruleSpec{
+				{name: "critical", priority: 100, matched: true, stop: true, suppresses: []string{"medium"}},
+				{name: "high", priority: 90, matched: true},
+				// 影子规则及其比较的线上规则继续评估，事件只用于影子比较
+				{name: "medium", priority: 50, matched: true, suppresses: []string{"low"}},
+				{name: "medium-v2", priority: 50, matched: true, shadowOf: "medium"},
+				{name: "low", priority: 10, matched: true},
+			},
+			want: []string{"critical", "medium stopped", "medium-v2 stopped"},
+		},
+		{
+			name: "stop on unmatched rule",
+			rules: []ruleSpec{
+				{name: "critical", priority: 100, stop: true},
+				{name: "high", priority: 90, matched: true},
+			},
+			want: []string{"high"},
+		},
+		{
+			name: "shadow rule does not stop",
+			rules: []ruleSpec{
+				{name: "candidate", priority: 100, matched: true, stop: true, shadowOf: "high"},
+				{name: "high", priority: 90, matched: true},
+				{name: "low", priority: 10, matched: true},
+			},
+			want: []string{"candidate", "high", "low"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := buildRules(tt.rules)
+			ruleengine.SortRules(rules)
+
+			manager := NewManager()
+			manager.Register(NewContractFunctionHook(nil, nil))
+			ctx := NewEvaluationContext(&TransactionData{TxHash: "0x1", FunctionSelector: "0x2e1a7d4d"})
+			events, err := manager.Trigger(ruleengine.HookContractFunctionCall, ctx, ruleengine.NewRuleSet(rules))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, event := range events {
+				summary := event.RuleID
+				if event.Silenced != "" {
+					summary += " " + event.Silenced
+				}
+				if len(event.Suppressed) > 0 {
+					summary += " " + fmt.Sprint(event.Suppressed)
+				}
+				got = append(got, summary)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trigger() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
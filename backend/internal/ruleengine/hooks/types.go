@@ -11,43 +11,20 @@ type Hook interface {
 	Execute(ctx *ruleengine.EvaluationContext, rules []*ruleengine.Rule) ([]*RiskEvent, error)
 }
 
-// TransactionData 交易数据（从 Kafka 接收）
-type TransactionData struct {
-	TxHash           string      `json:"tx_hash"`
-	BlockNumber      uint64      `json:"block_number"`
-	FromAddress      string      `json:"from_address"`
-	ToAddress        string      `json:"to_address"`
-	Value            string      `json:"value"`
-	GasPrice         uint64      `json:"gas_price"`
-	GasUsed          uint64      `json:"gas_used"`
-	GasLimit         uint64      `json:"gas_limit"`
-	Status           uint64      `json:"status"`
-	Timestamp        uint64      `json:"timestamp"`
-	FunctionSelector string      `json:"function_selector"`
-	InputData        string      `json:"input_data"`
-	CallStack        []CallFrame `json:"call_stack"`
-	Events           []EventLog  `json:"events"`
-}
+// TransactionData 交易数据（从 Kafka 接收，定义在 ruleengine 中，供表达式直接访问）
+type TransactionData = ruleengine.TransactionData
 
-type CallFrame struct {
-	Type     string `json:"type"`
-	From     string `json:"from"`
-	To       string `json:"to"`
-	Value    string `json:"value"`
-	Gas      uint64 `json:"gas"`
-	GasUsed  uint64 `json:"gas_used"`
-	Input    string `json:"input"`
-	Output   string `json:"output"`
-	Error    string `json:"error"`
-	Depth    int    `json:"depth"`
-	Function string `json:"function"`
-}
+// CallFrame 调用帧（定义在 ruleengine 中，供表达式直接访问）
+type CallFrame = ruleengine.CallFrame
 
-type EventLog struct {
-	Address string   `json:"address"`
-	Topics  []string `json:"topics"`
-	Data    string   `json:"data"`
-}
+// EventLog 事件日志
+type EventLog = ruleengine.EventLog
+
+// 只用于影子比较的风险事件（RiskEvent.Silenced）
+const (
+	SilencedSuppressed = "suppressed"
+	SilencedStopped    = "stopped"
+)
 
 // RiskEvent 风险事件
 type RiskEvent struct {
@@ -59,4 +36,12 @@ type RiskEvent struct {
 	BlockNumber uint64
 	Description string
 	Metadata    map[string]interface{}
+	Suppressed  []string // 被本事件抑制的低优先级规则
+
+	// Silenced 非空的事件只用于影子比较，不执行限流与动作：
+	// SilencedSuppressed 被优先级更高的规则抑制，SilencedStopped 在 stop_on_match 停止评估后仅为影子比较而评估
+	Silenced string
+
+	// Context 规则作用域的求值上下文（包含该规则提取的数据），供评分和执行动作使用
+	Context *ruleengine.EvaluationContext
 }
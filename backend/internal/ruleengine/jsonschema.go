@@ -0,0 +1,95 @@
+package ruleengine
+
+//go:generate go run gen_schema.go
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleSchemaID 规则文件 JSON Schema 的标识
+// 生成的文件位于 docs/rule.schema.json，编辑器中可以在规则文件首行用 # yaml-language-server: $schema=<路径> 引用
+const RuleSchemaID = "urn:bcscan:rule"
+
+// schemaEnums 取值受限的字段，键为 formatPath 形式的路径，数组元素写作 []
+var schemaEnums = map[string][]string{
+	"config.severity":            Severities,
+	"config.hooks[]":             KnownHooks,
+	"config.on_missing":          {OnMissingNoMatch, OnMissingError},
+	"config.mode":                RuleModes,
+	"config.throttle.group_by[]": {ThrottleGroupContract, ThrottleGroupSender},
+	"triggers.operator":          {"AND", "OR"},
+	"actions[].type":             ActionTypes,
+	"actions[].severity":         Severities,
+}
+
+// schemaRequired 必填字段，与 Validate 的检查一致
+var schemaRequired = map[string][]string{
+	"":         {"metadata"},
+	"metadata": {"name"},
+}
+
+// RuleJSONSchema 返回规则文件的 JSON Schema（draft 2020-12）
+// 由 Rule 的 yaml 标签生成，所有对象都不允许未知字段，与加载时的严格解析一致；
+// 表达式的语法与类型只能由 Validate 检查
+func RuleJSONSchema() ([]byte, error) {
+	schema := jsonSchema(reflect.TypeOf(Rule{}), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = RuleSchemaID
+	schema["title"] = "bcscan rule"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func jsonSchema(t reflect.Type, path string) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(yaml.Node{}) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchema(t.Elem(), path)
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if values, ok := schemaEnums[path]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), path+"[]")}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), path+".*")}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for name, field := range yamlFields(t) {
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			properties[name] = jsonSchema(field.Type, fieldPath)
+		}
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if required, ok := schemaRequired[path]; ok {
+			schema["required"] = required
+		}
+		return schema
+	}
+	// interface{}：任意值
+	return map[string]interface{}{}
+}
@@ -0,0 +1,159 @@
+package ruleengine
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LintReport 规则检查结果
+type LintReport struct {
+	Files       int          `json:"files"`
+	Rules       int          `json:"rules"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Lint 检查规则目录下的所有规则文件与测试文件（files 为空时）或给定的文件，不加载任何规则
+// 与 LoadFile 使用相同的严格解析与校验，另外检查共享列表文件、模板及其实例、重名规则、suppresses 关系和未挂载钩子的规则
+// 只有文件无法读取时返回错误，其余问题都记入报告
+func (rl *RuleLoader) Lint(files ...string) (*LintReport, error) {
+	report := &LintReport{Diagnostics: []Diagnostic{}}
+
+	listFiles, err := filepath.Glob(filepath.Join(rl.rulesDir, ListsDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob lists: %w", err)
+	}
+	for _, file := range listFiles {
+		listFile, diags, err := parseListFile(file)
+		if err != nil {
+			return nil, err
+		}
+		report.Files++
+		report.add(diags...)
+		if listFile == nil {
+			continue
+		}
+		for name, values := range listFile.Lists {
+			rl.lists[name] = values
+		}
+		for name, value := range listFile.Constants {
+			rl.constants[name] = value
+		}
+	}
+
+	if len(files) == 0 {
+		if files, err = rl.yamlFiles(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	// 测试文件只检查结构
+	ruleFiles := files[:0:0]
+	for _, file := range files {
+		if !strings.HasSuffix(file, TestFileSuffix) {
+			ruleFiles = append(ruleFiles, file)
+			continue
+		}
+		_, _, diags, err := parseTestFile(file)
+		if err != nil {
+			return nil, err
+		}
+		report.Files++
+		report.add(diags...)
+	}
+
+	templateDiags, err := rl.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	templateFiles, err := rl.templateFiles()
+	if err != nil {
+		return nil, err
+	}
+	report.Files += len(templateFiles)
+	report.add(templateDiags...)
+
+	rules := make(map[string]*Rule)
+	defined := make(map[string]string) // 规则名 -> 文件
+	docs := make(map[string]*yaml.Node)
+	check := func(file string, rule *Rule, doc *yaml.Node) {
+		if rule == nil || rule.Metadata.Name == "" {
+			return
+		}
+		report.Rules++
+
+		name := rule.Metadata.Name
+		if existing, ok := defined[name]; ok {
+			report.add(located(file, doc, Diagnostic{
+				Level:   LevelError,
+				Message: fmt.Sprintf("duplicate rule name %q, already defined in %s", name, existing),
+				keys:    []interface{}{"metadata", "name"},
+			}))
+			return
+		}
+		rules[name], defined[name], docs[name] = rule, file, doc
+
+		if len(rule.Config.Hooks) == 0 {
+			report.add(located(file, doc, Diagnostic{
+				Level:   LevelWarning,
+				Message: "rule has no hooks and will never be evaluated",
+				keys:    []interface{}{"config", "hooks"},
+			}))
+		}
+	}
+
+	for _, file := range ruleFiles {
+		rule, doc, diags, err := rl.parseFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		report.Files++
+		report.add(diags...)
+		check(file, rule, doc)
+	}
+
+	// 模板实例按展开后的规则检查，诊断信息的文件为 <模板文件>#<规则名>
+	sources, instanceDiags := rl.instances()
+	report.add(instanceDiags...)
+	for _, source := range sources {
+		rule, doc, diags := rl.parseSource(source.Name, source.Content)
+		report.add(diags...)
+		check(source.Name, rule, doc)
+	}
+
+	for _, d := range referenceDiagnostics(rules) {
+		report.add(located(defined[d.rule], docs[d.rule], d))
+	}
+	return report, nil
+}
+
+// add 记录诊断信息并按级别计数
+func (r *LintReport) add(diags ...Diagnostic) {
+	for _, d := range diags {
+		if d.Level == LevelWarning {
+			r.Warnings++
+		} else {
+			r.Errors++
+		}
+		r.Diagnostics = append(r.Diagnostics, d)
+	}
+}
+
+// located 将诊断信息定位到 YAML 文档中的节点，缺少的键定位到最近的上级节点
+func located(file string, doc *yaml.Node, d Diagnostic) Diagnostic {
+	d.File = file
+	d.Path = formatPath(d.keys)
+	for n := len(d.keys); n >= 0; n-- {
+		if node := lookupNode(doc, d.keys[:n]); node != nil {
+			d.Line, d.Column = node.Line, node.Column
+			break
+		}
+	}
+	return d
+}
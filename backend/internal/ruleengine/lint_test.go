@@ -0,0 +1,121 @@
+package ruleengine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLint(t *testing.T) {
+	dir := writeRulesDir(t, map[string]string{
+		"large.yaml": `metadata:
+  name: "large"
+config:
+  severity: "high"
+  hooks: [contract_function_call]
+triggers:
+  conditions:
+    - expression: "value > 1 ether"
+`,
+		"typo.yaml": `metadata:
+  name: "typo"
+config:
+  severty: "high"
+  hooks: [contract_function_call]
+triggers:
+  conditions:
+    - expresion: "value > 1"
+`,
+		"badtype.yaml": `metadata:
+  name: "badtype"
+config:
+  priority: "high"
+  hooks: [contract_function_call]
+`,
+		"duplicate.yaml": `metadata:
+  name: "large"
+config:
+  hooks: [contract_function_call]
+`,
+		"unhooked.yaml": `metadata:
+  name: "unhooked"
+triggers:
+  conditions:
+    - expression: "valeu > 1"
+`,
+		"broken.yaml": "metadata: [\n",
+	})
+
+	report, err := NewRuleLoader(dir, zap.NewNop()).Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range report.Diagnostics {
+		got = append(got, strings.ReplaceAll(d.String(), dir+string(filepath.Separator), ""))
+	}
+	want := []string{
+		"badtype.yaml:4:1: cannot unmarshal !!str `high` into int",
+		"broken.yaml:1:1: did not find expected node content",
+		`large.yaml:2:9: duplicate rule name "large", already defined in duplicate.yaml`,
+		`typo.yaml:4:3: unknown field "severty", did you mean "severity"?`,
+		`typo.yaml:8:7: unknown field "expresion", did you mean "expression"?`,
+		"typo.yaml:8:7: condition requires either type or expression",
+		// 表达式中的位置换算为文件中的列
+		`unhooked.yaml:5:20: unknown variable "valeu", did you mean "value"?`,
+		// 缺少 config 时定位到文档开头
+		"unhooked.yaml:1:1: warning: rule has no hooks and will never be evaluated",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() diagnostics =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if report.Files != 6 || report.Rules != 5 || report.Errors != 7 || report.Warnings != 1 {
+		t.Errorf("Lint() = %d files, %d rules, %d errors, %d warnings", report.Files, report.Rules, report.Errors, report.Warnings)
+	}
+}
+
+func TestRuleJSONSchema(t *testing.T) {
+	data, err := RuleJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Required             []string `json:"required"`
+		AdditionalProperties bool     `json:"additionalProperties"`
+		Properties           map[string]struct {
+			Properties map[string]struct {
+				Enum  []string `json:"enum"`
+				Items struct {
+					Enum []string `json:"enum"`
+				} `json:"items"`
+			} `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"metadata"}) || schema.AdditionalProperties {
+		t.Errorf("root required = %v, additionalProperties = %v", schema.Required, schema.AdditionalProperties)
+	}
+	config := schema.Properties["config"].Properties
+	if got := config["severity"].Enum; !reflect.DeepEqual(got, Severities) {
+		t.Errorf("config.severity enum = %v, want %v", got, Severities)
+	}
+	if got := config["hooks"].Items.Enum; !reflect.DeepEqual(got, KnownHooks) {
+		t.Errorf("config.hooks enum = %v, want %v", got, KnownHooks)
+	}
+
+	// docs/rule.schema.json 由 RuleJSONSchema 生成，修改规则结构后须重新生成
+	doc, err := os.ReadFile("../../../docs/rule.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != string(data)+"\n" {
+		t.Error("docs/rule.schema.json is out of date, run go generate ./internal/ruleengine")
+	}
+}
@@ -1,39 +1,65 @@
 package ruleengine
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
+// ListsDir 规则目录下存放共享命名列表的子目录
+const ListsDir = "lists"
+
+// SuppressedRulesKey 随风险事件上报的被抑制规则列表（config.suppresses），动作模板中可以用 {{suppressed_rules}} 引用
+const SuppressedRulesKey = "suppressed_rules"
+
 type RuleLoader struct {
-	rulesDir string
-	logger   *zap.Logger
-	rules    map[string]*Rule
+	rulesDir  string
+	logger    *zap.Logger
+	rules     map[string]*Rule
+	files     map[string]string // 规则名 -> 文件
+	sources   map[string][]byte // 规则名 -> 文件内容，用于记录修订
+	lists     map[string][]string
+	constants map[string]string
+	templates map[string]*ruleTemplate // 模板名 -> 模板
+}
+
+// ListFile 共享命名列表与常量文件
+type ListFile struct {
+	Lists     map[string][]string `yaml:"lists"`
+	Constants map[string]string   `yaml:"constants"`
 }
 
 func NewRuleLoader(rulesDir string, logger *zap.Logger) *RuleLoader {
 	return &RuleLoader{
-		rulesDir: rulesDir,
-		logger:   logger,
-		rules:    make(map[string]*Rule),
+		rulesDir:  rulesDir,
+		logger:    logger,
+		rules:     make(map[string]*Rule),
+		files:     make(map[string]string),
+		sources:   make(map[string][]byte),
+		lists:     make(map[string][]string),
+		constants: make(map[string]string),
+		templates: make(map[string]*ruleTemplate),
 	}
 }
 
+// LoadAll 重新加载规则目录下的所有规则及模板实例，已删除的规则文件不再保留
 func (rl *RuleLoader) LoadAll() error {
-	pattern := filepath.Join(rl.rulesDir, "**/*.yaml")
-	files, err := filepath.Glob(pattern)
+	files, err := rl.ruleFiles()
 	if err != nil {
-		return fmt.Errorf("failed to glob rules: %w", err)
+		return err
 	}
 
-	// Also check direct yaml files in rules directory
-	directFiles, err := filepath.Glob(filepath.Join(rl.rulesDir, "*.yaml"))
-	if err == nil {
-		files = append(files, directFiles...)
+	if err := rl.reset(); err != nil {
+		return err
 	}
 
 	rl.logger.Info("Loading rules", zap.Int("file_count", len(files)))
@@ -47,33 +73,306 @@ func (rl *RuleLoader) LoadAll() error {
 		}
 	}
 
+	sources, diags := rl.instances()
+	for _, d := range diags {
+		rl.logger.Error("Invalid template instance", zap.String("diagnostic", d.String()))
+	}
+	for _, source := range sources {
+		if err := rl.LoadSource(source.Name, source.Content); err != nil {
+			rl.logger.Error("Failed to load template instance",
+				zap.String("source", source.Name),
+				zap.Error(err))
+		}
+	}
+
+	rl.checkReferences()
+
+	rl.logger.Info("Rules loaded successfully", zap.Int("rule_count", len(rl.rules)))
+	return nil
+}
+
+// RuleSource 不来自规则目录的规则内容，如数据库中的规则
+type RuleSource struct {
+	Name    string // 诊断信息与日志中的来源，如 db:<规则名>
+	Content []byte
+}
+
+// LoadSources 重新加载给定的规则内容，替换已加载的规则；共享列表仍从规则目录的 lists 目录加载
+func (rl *RuleLoader) LoadSources(sources []RuleSource) error {
+	if err := rl.reset(); err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		if err := rl.LoadSource(source.Name, source.Content); err != nil {
+			rl.logger.Error("Failed to load rule",
+				zap.String("source", source.Name),
+				zap.Error(err))
+		}
+	}
+
+	rl.checkReferences()
+
 	rl.logger.Info("Rules loaded successfully", zap.Int("rule_count", len(rl.rules)))
 	return nil
 }
 
+// reset 清空已加载的规则并重新加载共享列表与模板
+func (rl *RuleLoader) reset() error {
+	rl.rules = make(map[string]*Rule)
+	rl.files = make(map[string]string)
+	rl.sources = make(map[string][]byte)
+	if err := rl.loadLists(); err != nil {
+		return err
+	}
+
+	diags, err := rl.loadTemplates()
+	if err != nil {
+		return err
+	}
+	for _, d := range diags {
+		rl.logger.Warn("Invalid template file", zap.String("diagnostic", d.String()))
+	}
+	return nil
+}
+
+// ruleFiles 返回规则目录及其子目录下的规则文件，不含 lists 目录下的共享定义与 *_test.yaml 测试文件
+func (rl *RuleLoader) ruleFiles() ([]string, error) {
+	files, err := rl.yamlFiles()
+	if err != nil {
+		return nil, err
+	}
+	ruleFiles := files[:0]
+	for _, file := range files {
+		if !strings.HasSuffix(file, TestFileSuffix) {
+			ruleFiles = append(ruleFiles, file)
+		}
+	}
+	return ruleFiles, nil
+}
+
+// yamlFiles 返回规则目录及其子目录下除 lists 目录外的所有 YAML 文件
+func (rl *RuleLoader) yamlFiles() ([]string, error) {
+	pattern := filepath.Join(rl.rulesDir, "**/*.yaml")
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob rules: %w", err)
+	}
+
+	// Also check direct yaml files in rules directory
+	directFiles, err := filepath.Glob(filepath.Join(rl.rulesDir, "*.yaml"))
+	if err == nil {
+		files = append(files, directFiles...)
+	}
+
+	listsDir := filepath.Join(rl.rulesDir, ListsDir)
+	templatesDir := filepath.Join(rl.rulesDir, TemplatesDir)
+	yamlFiles := files[:0]
+	for _, file := range files {
+		if dir := filepath.Dir(file); dir != listsDir && dir != templatesDir {
+			yamlFiles = append(yamlFiles, file)
+		}
+	}
+	return yamlFiles, nil
+}
+
+// LoadFile 加载单个规则文件
+// 未知字段、类型错误或校验失败的规则不会加载；不同文件中重名的规则只保留先加载的一个
 func (rl *RuleLoader) LoadFile(filename string) error {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
+	return rl.LoadSource(filename, data)
+}
 
-	var rule Rule
-	if err := yaml.Unmarshal(data, &rule); err != nil {
-		return fmt.Errorf("failed to unmarshal yaml: %w", err)
+// LoadSource 加载一条规则的内容，filename 为其来源，校验方式与 LoadFile 相同
+func (rl *RuleLoader) LoadSource(filename string, data []byte) error {
+	rule, _, diags := rl.parseSource(filename, data)
+	if hasErrors(diags) {
+		name := ""
+		if rule != nil {
+			name = rule.Metadata.Name
+		}
+		return &ValidationError{Rule: name, Diagnostics: diags}
 	}
 
-	if rule.Metadata.Name == "" {
-		return fmt.Errorf("rule name is required")
+	name := rule.Metadata.Name
+	if existing, ok := rl.files[name]; ok && existing != filename {
+		return fmt.Errorf("duplicate rule name %q, already defined in %s", name, existing)
 	}
 
-	rl.rules[rule.Metadata.Name] = &rule
+	rl.rules[name] = rule
+	rl.files[name] = filename
+	rl.sources[name] = rule.content
 	rl.logger.Info("Rule loaded",
-		zap.String("name", rule.Metadata.Name),
+		zap.String("name", name),
 		zap.String("file", filename))
 
 	return nil
 }
 
+// parseFile 严格解析规则文件并校验：未知字段、类型错误与 Validate 的诊断信息一并返回并定位到文件行列号
+// 文件无法读取时返回错误；YAML 语法错误时 rule 为 nil
+func (rl *RuleLoader) parseFile(filename string) (*Rule, *yaml.Node, []Diagnostic, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	rule, doc, diags := rl.parseSource(filename, data)
+	return rule, doc, diags, nil
+}
+
+// parseSource 严格解析规则文件内容，filename 只用于诊断信息；规则的 Hash 为内容的 SHA-256
+// 使用模板的规则先展开（见 expand），之后的解析、校验与 Hash 都基于展开后的内容；展开失败时 rule 与文档均为 nil
+func (rl *RuleLoader) parseSource(filename string, data []byte) (*Rule, *yaml.Node, []Diagnostic) {
+	data, filename, diags := rl.expand(filename, data)
+	if diags != nil {
+		return nil, nil, diags
+	}
+
+	// 先解析为节点树，保留行列号用于校验报错
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		diags := decodeDiagnostics(err)
+		locateDiagnostics(filename, data, &doc, diags)
+		return nil, &doc, diags
+	}
+
+	// 类型错误时 yaml 仍会解码其余字段，继续检查以便一次报告所有问题
+	var rule Rule
+	if err := doc.Decode(&rule); err != nil {
+		var typeErr *yaml.TypeError
+		diags = decodeDiagnostics(err)
+		if !errors.As(err, &typeErr) {
+			locateDiagnostics(filename, data, &doc, diags)
+			return nil, &doc, diags
+		}
+	}
+
+	rl.applyLists(&rule)
+	rule.Hash = ContentHash(data)
+	rule.content = data
+
+	// 加载时编译并检查所有表达式，有问题的规则不会进入规则管理器和 Redis
+	diags = append(diags, checkKnownFields(&doc, reflect.TypeOf(rule), nil)...)
+	diags = append(diags, rule.Validate()...)
+	locateDiagnostics(filename, data, &doc, diags)
+	return &rule, &doc, diags
+}
+
+// hasErrors 诊断信息中是否有错误级别的问题
+func hasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Level != LevelWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLists 加载 lists 目录下的共享命名列表与常量
+func (rl *RuleLoader) loadLists() error {
+	files, err := filepath.Glob(filepath.Join(rl.rulesDir, ListsDir, "*.yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to glob lists: %w", err)
+	}
+
+	for _, file := range files {
+		listFile, diags, err := parseListFile(file)
+		if err != nil {
+			return err
+		}
+		for _, d := range diags {
+			rl.logger.Warn("Invalid list file entry", zap.String("diagnostic", d.String()))
+		}
+		if listFile == nil {
+			continue
+		}
+
+		for name, values := range listFile.Lists {
+			if _, exists := rl.lists[name]; exists {
+				rl.logger.Warn("Duplicate list definition, overriding",
+					zap.String("list", name),
+					zap.String("file", file))
+			}
+			rl.lists[name] = values
+		}
+
+		for name, value := range listFile.Constants {
+			if _, exists := rl.constants[name]; exists {
+				rl.logger.Warn("Duplicate constant definition, overriding",
+					zap.String("constant", name),
+					zap.String("file", file))
+			}
+			rl.constants[name] = value
+		}
+	}
+
+	if len(rl.lists) > 0 || len(rl.constants) > 0 {
+		rl.logger.Info("Lists loaded",
+			zap.Int("list_count", len(rl.lists)),
+			zap.Int("constant_count", len(rl.constants)))
+	}
+	return nil
+}
+
+// parseListFile 严格解析共享列表文件，未知的键与类型错误作为诊断信息返回，无法解码时 listFile 为 nil
+func parseListFile(file string) (*ListFile, []Diagnostic, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read list file %s: %w", file, err)
+	}
+
+	var doc yaml.Node
+	var listFile ListFile
+	if err := yaml.Unmarshal(data, &doc); err == nil {
+		err = doc.Decode(&listFile)
+	}
+	if err != nil {
+		diags := decodeDiagnostics(err)
+		locateDiagnostics(file, data, &doc, diags)
+		return nil, diags, nil
+	}
+
+	diags := checkKnownFields(&doc, reflect.TypeOf(listFile), nil)
+	locateDiagnostics(file, data, &doc, diags)
+	return &listFile, diags, nil
+}
+
+// applyLists 将共享列表与常量合并到规则中，规则内定义的同名项优先
+func (rl *RuleLoader) applyLists(rule *Rule) {
+	if len(rl.lists) > 0 && rule.Lists == nil {
+		rule.Lists = make(map[string][]string)
+	}
+	for name, values := range rl.lists {
+		if _, ok := rule.Lists[name]; !ok {
+			rule.Lists[name] = values
+		}
+	}
+
+	if len(rl.constants) > 0 && rule.Constants == nil {
+		rule.Constants = make(map[string]string)
+	}
+	for name, value := range rl.constants {
+		if _, ok := rule.Constants[name]; !ok {
+			rule.Constants[name] = value
+		}
+	}
+}
+
+// Source 返回已加载规则的来源（文件名）及其内容，使用模板的规则为展开后的内容
+func (rl *RuleLoader) Source(name string) (string, []byte, bool) {
+	file, ok := rl.files[name]
+	return file, rl.sources[name], ok
+}
+
+// ContentHash 规则文件内容的 SHA-256（十六进制）
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (rl *RuleLoader) GetRule(name string) (*Rule, bool) {
 	rule, ok := rl.rules[name]
 	return rule, ok
@@ -83,6 +382,7 @@ func (rl *RuleLoader) GetAllRules() map[string]*Rule {
 	return rl.rules
 }
 
+// GetEnabledRules 返回启用的规则，按评估顺序排列（见 SortRules）
 func (rl *RuleLoader) GetEnabledRules() []*Rule {
 	enabled := make([]*Rule, 0)
 	for _, rule := range rl.rules {
@@ -90,5 +390,65 @@ func (rl *RuleLoader) GetEnabledRules() []*Rule {
 			enabled = append(enabled, rule)
 		}
 	}
+	SortRules(enabled)
 	return enabled
 }
+
+// SortRules 按评估顺序排列规则：优先级从高到低，优先级相同时按名称排序
+func SortRules(rules []*Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Config.Priority != rules[j].Config.Priority {
+			return rules[i].Config.Priority > rules[j].Config.Priority
+		}
+		return rules[i].Metadata.Name < rules[j].Metadata.Name
+	})
+}
+
+// checkReferences 检查规则之间的引用：被抑制的规则必须存在且优先级不高于抑制方，影子规则比较的线上规则必须存在
+func (rl *RuleLoader) checkReferences() {
+	for _, d := range referenceDiagnostics(rl.rules) {
+		rl.logger.Warn(d.Message, zap.String("rule", d.rule))
+	}
+}
+
+// referenceDiagnostics 返回 suppresses 与 shadow_of 引用中的问题（警告级别），按规则名排序
+func referenceDiagnostics(rules map[string]*Rule) []Diagnostic {
+	var diags []Diagnostic
+	for _, name := range sortedKeys(rules) {
+		rule := rules[name]
+		for i, suppressed := range rule.Config.Suppresses {
+			keys := []interface{}{"config", "suppresses", i}
+			target, ok := rules[suppressed]
+			switch {
+			case !ok:
+				diags = append(diags, Diagnostic{
+					Path: formatPath(keys), Level: LevelWarning, keys: keys, rule: name,
+					Message: fmt.Sprintf("rule suppresses unknown rule %q", suppressed),
+				})
+			case target.Config.Priority > rule.Config.Priority:
+				diags = append(diags, Diagnostic{
+					Path: formatPath(keys), Level: LevelWarning, keys: keys, rule: name,
+					Message: fmt.Sprintf("rule suppresses higher-priority rule %q, suppression has no effect", suppressed),
+				})
+			}
+		}
+
+		if shadowOf := rule.Config.ShadowOf; shadowOf != "" {
+			keys := []interface{}{"config", "shadow_of"}
+			target, ok := rules[shadowOf]
+			switch {
+			case !ok:
+				diags = append(diags, Diagnostic{
+					Path: formatPath(keys), Level: LevelWarning, keys: keys, rule: name,
+					Message: fmt.Sprintf("rule shadows unknown rule %q", shadowOf),
+				})
+			case target.IsShadow():
+				diags = append(diags, Diagnostic{
+					Path: formatPath(keys), Level: LevelWarning, keys: keys, rule: name,
+					Message: fmt.Sprintf("rule shadows shadow rule %q, expected a live rule", shadowOf),
+				})
+			}
+		}
+	}
+	return diags
+}
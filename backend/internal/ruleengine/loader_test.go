@@ -0,0 +1,61 @@
+package ruleengine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortRules(t *testing.T) {
+	rule := func(name string, priority int) *Rule {
+		r := testRule(name)
+		r.Config.Priority = priority
+		return r
+	}
+	rules := []*Rule{rule("c", 10), rule("low", -5), rule("b", 10), rule("top", 100), rule("default", 0), rule("a", 10)}
+	SortRules(rules)
+
+	var got []string
+	for _, r := range rules {
+		got = append(got, r.Metadata.Name)
+	}
+	if want := []string{"top", "a", "b", "c", "default", "low"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SortRules() = %v, want %v", got, want)
+	}
+}
+
+func TestReferenceDiagnostics(t *testing.T) {
+	rule := func(name string, priority int, configure func(*Rule)) *Rule {
+		r := testRule(name)
+		r.Config.Priority = priority
+		if configure != nil {
+			configure(r)
+		}
+		return r
+	}
+	rules := map[string]*Rule{
+		"critical": rule("critical", 100, func(r *Rule) { r.Config.Suppresses = []string{"medium", "missing"} }),
+		"medium":   rule("medium", 50, func(r *Rule) { r.Config.Suppresses = []string{"critical", "medium"} }),
+		"candidate": rule("candidate", 50, func(r *Rule) {
+			r.Config.Mode, r.Config.ShadowOf = RuleModeShadow, "medium"
+		}),
+		"orphan": rule("orphan", 0, func(r *Rule) { r.Config.Mode, r.Config.ShadowOf = RuleModeShadow, "deleted" }),
+		"nested": rule("nested", 0, func(r *Rule) { r.Config.Mode, r.Config.ShadowOf = RuleModeShadow, "candidate" }),
+	}
+
+	var got []string
+	for _, d := range referenceDiagnostics(rules) {
+		if d.Level != LevelWarning {
+			t.Errorf("diagnostic %s has level %s, want warning", d.Message, d.Level)
+		}
+		got = append(got, d.rule+" "+d.Path+": "+d.Message)
+	}
+	want := []string{
+		`critical config.suppresses[1]: rule suppresses unknown rule "missing"`,
+		`medium config.suppresses[0]: rule suppresses higher-priority rule "critical", suppression has no effect`,
+		`nested config.shadow_of: rule shadows shadow rule "candidate", expected a live rule`,
+		`orphan config.shadow_of: rule shadows unknown rule "deleted"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("referenceDiagnostics() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -2,9 +2,13 @@ package ruleengine
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/haswell/bcscan/internal/cache"
+	"github.com/haswell/bcscan/internal/models"
+	"github.com/haswell/bcscan/internal/repository"
 	"go.uber.org/zap"
 )
 
@@ -14,81 +18,294 @@ const (
 )
 
 // RuleManager 规则管理器（支持热加载）
+// 规则以数据库（detection_rules）为准，规则目录在启动时导入（SeedRules），Redis 缓存加载结果（RuleSet）
 type RuleManager struct {
-	loader *RuleLoader
-	redis  *cache.RedisClient
-	logger *zap.Logger
-	rules  []*Rule
+	loader    *RuleLoader
+	store     *repository.RuleRepository
+	revisions *repository.RuleRevisionRepository
+	contracts *repository.MonitoredContractRepository // 解析规则 scope 中引用的监控合约
+	redis     *cache.RedisClient
+	logger    *zap.Logger
+	instance  string // 实例 ID，见 Instances
+
+	mu      sync.Mutex              // 串行化加载与校验，RuleLoader 不是并发安全的
+	current atomic.Pointer[RuleSet] // 当前规则快照，读取无需加锁
+
+	programs *ProgramCache // 规则编译缓存，每次加载后重建
 }
 
-func NewRuleManager(rulesDir string, redis *cache.RedisClient, logger *zap.Logger) *RuleManager {
-	return &RuleManager{
-		loader: NewRuleLoader(rulesDir, logger),
-		redis:  redis,
-		logger: logger,
-		rules:  []*Rule{},
+func NewRuleManager(rulesDir string, store *repository.RuleRepository, revisions *repository.RuleRevisionRepository,
+	contracts *repository.MonitoredContractRepository, redis *cache.RedisClient, logger *zap.Logger) *RuleManager {
+	rm := &RuleManager{
+		loader:    NewRuleLoader(rulesDir, logger),
+		store:     store,
+		revisions: revisions,
+		contracts: contracts,
+		redis:     redis,
+		logger:    logger,
+		instance:  instanceID(),
+
+		programs: NewProgramCache(),
 	}
+	rm.current.Store(NewRuleSet([]*Rule{}))
+	return rm
 }
 
-// LoadRules 加载规则（优先从 Redis）
-func (rm *RuleManager) LoadRules(ctx context.Context) error {
-	// 尝试从 Redis 加载
-	var cachedRules []*Rule
-	err := rm.redis.Get(ctx, RulesCacheKey, &cachedRules)
-	if err == nil && len(cachedRules) > 0 {
-		rm.rules = cachedRules
-		rm.logger.Info("Loaded rules from Redis", zap.Int("count", len(cachedRules)))
-		return nil
-	}
+// SeedRules 将规则目录中的规则导入数据库，启动时调用
+// 只写入新规则与内容变化且未通过 API 修改过的规则，已删除的规则不会恢复（见 RuleRepository.Seed）
+func (rm *RuleManager) SeedRules(ctx context.Context) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-	// Redis 没有，从文件加载
 	if err := rm.loader.LoadAll(); err != nil {
 		return err
 	}
 
-	rm.rules = rm.loader.GetEnabledRules()
-
-	// 缓存到 Redis
-	if err := rm.redis.Set(ctx, RulesCacheKey, rm.rules, 0); err != nil {
-		rm.logger.Warn("Failed to cache rules to Redis", zap.Error(err))
+	rules := rm.loader.GetAllRules()
+	seeded := 0
+	for _, name := range sortedKeys(rules) {
+		rule := rules[name]
+		row := detectionRule(rule, rule.Metadata.Author)
+		ok, err := rm.store.Seed(ctx, row)
+		if err != nil {
+			return fmt.Errorf("failed to seed rule %q: %w", name, err)
+		}
+		if ok {
+			seeded++
+			rm.logger.Info("Rule seeded",
+				zap.String("rule", name),
+				zap.Int("revision", row.Revision))
+		}
 	}
 
-	rm.logger.Info("Loaded rules from files", zap.Int("count", len(rm.rules)))
+	rm.logger.Info("Rules seeded from files", zap.Int("count", len(rules)), zap.Int("updated", seeded))
 	return nil
 }
 
-// GetRules 获取当前规则
-func (rm *RuleManager) GetRules() []*Rule {
-	return rm.rules
+// LoadFromStore 从数据库加载规则，替换当前快照并更新 Redis 中的规则缓存
+func (rm *RuleManager) LoadFromStore(ctx context.Context) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	set, err := rm.loadFromStore(ctx)
+	if err != nil {
+		rm.confirm(ctx, rm.Snapshot(), err)
+		return err
+	}
+	rm.confirm(ctx, set, nil)
+	return nil
 }
 
-// SubscribeUpdates 订阅规则更新
-func (rm *RuleManager) SubscribeUpdates(ctx context.Context) {
-	pubsub := rm.redis.Subscribe(ctx, RulesUpdateChannel)
-	defer pubsub.Close()
+func (rm *RuleManager) loadFromStore(ctx context.Context) (*RuleSet, error) {
+	rows, err := rm.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
+	}
+
+	sources := make([]RuleSource, len(rows))
+	for i, row := range rows {
+		sources[i] = RuleSource{Name: storeSource(row.Name), Content: []byte(row.Content)}
+	}
+	if err := rm.loader.LoadSources(sources); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if rule, ok := rm.loader.GetRule(row.Name); ok {
+			rule.Revision = row.Revision
+		}
+	}
 
-	rm.logger.Info("Subscribed to rule updates")
+	rules := rm.loader.GetEnabledRules()
+	if err := rm.resolveScopes(ctx, rules); err != nil {
+		return nil, err
+	}
+	set := rm.install(rules)
 
-	ch := pubsub.Channel()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case msg := <-ch:
-			rm.logger.Info("Received rule update notification", zap.String("message", msg.Payload))
-			if err := rm.LoadRules(ctx); err != nil {
-				rm.logger.Error("Failed to reload rules", zap.Error(err))
-			} else {
-				rm.logger.Info("Rules reloaded successfully", zap.Int("count", len(rm.rules)))
+	// 缓存到 Redis，其他实例收到同一版本的通知时直接使用
+	if err := rm.redis.Set(ctx, RulesCacheKey, newCachedRuleSet(set), 0); err != nil {
+		rm.logger.Warn("Failed to cache rules to Redis", zap.Error(err))
+	}
+
+	rm.logger.Info("Loaded rules from database",
+		zap.String("version", set.Version),
+		zap.Int("count", len(set.Rules)))
+	return set, nil
+}
+
+// resolveScopes 将规则 scope 中引用的监控合约解析为地址，同名的合约（如部署在多条链上）全部包含
+// 引用不存在或未启用的合约时记录警告，规则不会因此扩大作用域
+func (rm *RuleManager) resolveScopes(ctx context.Context, rules []*Rule) error {
+	var scoped []*Rule
+	for _, rule := range rules {
+		if len(rule.Scope.MonitoredContracts) > 0 {
+			scoped = append(scoped, rule)
+		}
+	}
+	if len(scoped) == 0 {
+		return nil
+	}
+
+	contracts, err := rm.contracts.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list monitored contracts: %w", err)
+	}
+	byName := make(map[string][]string)
+	for _, contract := range contracts {
+		address, err := NormalizeAddress(contract.Address)
+		if err != nil {
+			rm.logger.Warn("Invalid monitored contract address", zap.Int64("id", contract.ID), zap.Error(err))
+			continue
+		}
+		byName[contract.Name] = append(byName[contract.Name], address)
+	}
+
+	for _, rule := range scoped {
+		rule.Scope.Resolved = nil
+		for _, name := range rule.Scope.MonitoredContracts {
+			addresses, ok := byName[name]
+			if !ok {
+				rm.logger.Warn("Unknown monitored contract in rule scope",
+					zap.String("rule", rule.Metadata.Name),
+					zap.String("contract", name))
+				continue
 			}
+			rule.Scope.Resolved = append(rule.Scope.Resolved, addresses...)
 		}
 	}
+	return nil
+}
+
+// storeSource 数据库中规则的来源名，用于诊断信息与日志
+func storeSource(name string) string {
+	return "db:" + name
+}
+
+// detectionRule 由校验通过的规则构造数据库中的规则，使用模板的规则保存展开后的内容
+func detectionRule(rule *Rule, author string) *models.DetectionRule {
+	return &models.DetectionRule{
+		Name:        rule.Metadata.Name,
+		Severity:    rule.Config.Severity,
+		Enabled:     rule.Metadata.Enabled,
+		Version:     rule.Metadata.Version,
+		Content:     string(rule.content),
+		ContentHash: rule.Hash,
+		UpdatedBy:   author,
+	}
+}
+
+// ValidateRule 按加载时的方式校验规则内容，name 须与 metadata.name 一致；有错误时返回 *ValidationError
+func (rm *RuleManager) ValidateRule(name string, content []byte) (*Rule, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	source := storeSource(name)
+	rule, doc, diags := rm.loader.parseSource(source, content)
+	if rule != nil && rule.Metadata.Name != name {
+		diags = append(diags, located(source, doc, Diagnostic{
+			Level:   LevelError,
+			Message: fmt.Sprintf("rule name %q does not match %q", rule.Metadata.Name, name),
+			keys:    []interface{}{"metadata", "name"},
+		}))
+	}
+	if hasErrors(diags) {
+		return nil, &ValidationError{Rule: name, Diagnostics: diags}
+	}
+	return rule, nil
+}
+
+// ReloadError 规则已写入数据库，但本实例重新加载失败；修改仍然有效，当前快照保持不变
+type ReloadError struct {
+	Err error
+}
+
+func (e *ReloadError) Error() string {
+	return fmt.Sprintf("rule saved but reload failed: %v", e.Err)
+}
+
+func (e *ReloadError) Unwrap() error {
+	return e.Err
+}
+
+// 以下修改规则的方法校验后写入数据库（同时记录修订）并重新加载规则，调用方负责通知其他实例（PublishUpdate）；
+// 写入成功但重新加载失败时返回写入的规则与 *ReloadError
+
+// reload 规则写入后重新加载，失败时记录日志并包装为 *ReloadError
+func (rm *RuleManager) reload(ctx context.Context, name string) error {
+	if err := rm.LoadFromStore(ctx); err != nil {
+		rm.logger.Error("Rule saved but reload failed", zap.String("rule", name), zap.Error(err))
+		return &ReloadError{Err: err}
+	}
+	return nil
+}
+
+// CreateRule 创建规则，规则已存在时返回 repository.ErrRuleExists
+func (rm *RuleManager) CreateRule(ctx context.Context, name string, content []byte, author string) (*models.DetectionRule, error) {
+	rule, err := rm.ValidateRule(name, content)
+	if err != nil {
+		return nil, err
+	}
+	row := detectionRule(rule, author)
+	if err := rm.store.Create(ctx, row); err != nil {
+		return nil, err
+	}
+	rm.logger.Info("Rule created", zap.String("rule", name), zap.Int("revision", row.Revision), zap.String("author", author))
+	return row, rm.reload(ctx, name)
+}
+
+// UpdateRule 替换规则内容，规则不存在时返回 sql.ErrNoRows
+func (rm *RuleManager) UpdateRule(ctx context.Context, name string, content []byte, author string) (*models.DetectionRule, error) {
+	return rm.updateRule(ctx, name, content, author, repository.RuleSourceAPI)
+}
+
+// PatchRule 将 JSON Merge Patch 应用到规则内容（见 MergePatch），规则不存在时返回 sql.ErrNoRows
+func (rm *RuleManager) PatchRule(ctx context.Context, name string, patch map[string]interface{}, author string) (*models.DetectionRule, error) {
+	current, err := rm.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := MergePatch([]byte(current.Content), patch)
+	if err != nil {
+		return nil, err
+	}
+	return rm.updateRule(ctx, name, content, author, repository.RuleSourceAPI)
+}
+
+// DeleteRule 删除规则，规则不存在时返回 sql.ErrNoRows
+func (rm *RuleManager) DeleteRule(ctx context.Context, name string, author string) error {
+	if err := rm.store.Delete(ctx, name, author); err != nil {
+		return err
+	}
+	rm.logger.Info("Rule deleted", zap.String("rule", name), zap.String("author", author))
+	return rm.reload(ctx, name)
+}
+
+// Rollback 将规则回滚到指定修订：按当前的共享列表重新校验修订内容，写入为新的修订；修订不存在时返回 sql.ErrNoRows
+func (rm *RuleManager) Rollback(ctx context.Context, name string, revision int, author string) (*models.DetectionRule, error) {
+	target, err := rm.revisions.Get(ctx, name, revision)
+	if err != nil {
+		return nil, err
+	}
+	return rm.updateRule(ctx, name, []byte(target.Content), author, repository.RevisionSourceRollback)
+}
+
+func (rm *RuleManager) updateRule(ctx context.Context, name string, content []byte, author, revisionSource string) (*models.DetectionRule, error) {
+	rule, err := rm.ValidateRule(name, content)
+	if err != nil {
+		return nil, err
+	}
+	row := detectionRule(rule, author)
+	if err := rm.store.Update(ctx, row, revisionSource); err != nil {
+		return nil, err
+	}
+	rm.logger.Info("Rule updated",
+		zap.String("rule", name),
+		zap.Int("revision", row.Revision),
+		zap.String("source", revisionSource),
+		zap.String("author", author))
+	return row, rm.reload(ctx, name)
 }
 
-// PublishUpdate 发布规则更新通知
-func (rm *RuleManager) PublishUpdate(ctx context.Context) error {
-	return rm.redis.Publish(ctx, RulesUpdateChannel, map[string]interface{}{
-		"timestamp": time.Now().Unix(),
-		"action":    "reload",
-	})
+// Programs 返回规则编译缓存，供钩子和评分器复用
+func (rm *RuleManager) Programs() *ProgramCache {
+	return rm.programs
 }
@@ -0,0 +1,89 @@
+package ruleengine
+
+import (
+	"time"
+
+	"github.com/haswell/bcscan/internal/models"
+)
+
+// TransactionData 交易数据（从 Kafka 接收，由 RMS 生成）
+type TransactionData struct {
+	TxHash               string        `json:"tx_hash"`
+	ChainID              uint64        `json:"chain_id,omitempty"` // 旧格式消息没有链 ID
+	BlockNumber          uint64        `json:"block_number"`
+	FromAddress          string        `json:"from_address"`
+	ToAddress            string        `json:"to_address"`
+	Value                string        `json:"value"`
+	Nonce                uint64        `json:"nonce"`
+	TxType               uint64        `json:"tx_type"` // 0 legacy, 1 access list, 2 EIP-1559, 3 blob
+	GasPrice             uint64        `json:"gas_price"`
+	MaxFeePerGas         uint64        `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas uint64        `json:"max_priority_fee_per_gas"`
+	GasUsed              uint64        `json:"gas_used"`
+	GasLimit             uint64        `json:"gas_limit"`
+	Status               uint64        `json:"status"`
+	Timestamp            uint64        `json:"timestamp"`
+	FunctionSelector     string        `json:"function_selector"`
+	InputData            string        `json:"input_data"`
+	Block                *BlockData    `json:"block,omitempty"`
+	CallStack            []CallFrame   `json:"call_stack"`
+	Events               []EventLog    `json:"events"`
+	StateDiff            []AccountDiff `json:"state_diff,omitempty"` // prestateTracer diffMode，只包含被修改的账户
+}
+
+// BlockData 交易所在区块的信息
+type BlockData struct {
+	Number           uint64 `json:"number"`
+	Hash             string `json:"hash"`
+	ParentHash       string `json:"parent_hash"`
+	Miner            string `json:"miner"`
+	Timestamp        uint64 `json:"timestamp"`
+	GasUsed          uint64 `json:"gas_used"`
+	GasLimit         uint64 `json:"gas_limit"`
+	BaseFee          uint64 `json:"base_fee"`
+	TransactionCount int    `json:"transaction_count"`
+}
+
+// ToTransaction 转换为 models.Transaction
+func (d *TransactionData) ToTransaction() *models.Transaction {
+	return &models.Transaction{
+		TxHash:      d.TxHash,
+		BlockNumber: int64(d.BlockNumber),
+		FromAddress: d.FromAddress,
+		ToAddress:   d.ToAddress,
+		Value:       d.Value,
+		GasPrice:    int64(d.GasPrice),
+		GasUsed:     int64(d.GasUsed),
+		InputData:   d.InputData,
+		Status:      int16(d.Status),
+		Timestamp:   time.Unix(int64(d.Timestamp), 0).UTC(),
+	}
+}
+
+// ToBlock 转换为 models.Block；旧格式消息没有 block 字段时用交易中的区块号和时间补齐
+func (d *TransactionData) ToBlock() *models.Block {
+	if d.Block == nil {
+		return &models.Block{
+			BlockNumber: int64(d.BlockNumber),
+			Timestamp:   time.Unix(int64(d.Timestamp), 0).UTC(),
+		}
+	}
+	return &models.Block{
+		BlockNumber:      int64(d.Block.Number),
+		BlockHash:        d.Block.Hash,
+		ParentHash:       d.Block.ParentHash,
+		Timestamp:        time.Unix(int64(d.Block.Timestamp), 0).UTC(),
+		Miner:            d.Block.Miner,
+		GasUsed:          int64(d.Block.GasUsed),
+		GasLimit:         int64(d.Block.GasLimit),
+		TransactionCount: d.Block.TransactionCount,
+	}
+}
+
+// PriorityFee 实际支付给出块者的每单位 gas 小费（gas_price - base_fee）
+func (d *TransactionData) PriorityFee() uint64 {
+	if d.Block == nil || d.GasPrice < d.Block.BaseFee {
+		return 0
+	}
+	return d.GasPrice - d.Block.BaseFee
+}
@@ -0,0 +1,140 @@
+package ruleengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergePatch 将 JSON Merge Patch（RFC 7386）应用到规则文件内容：
+// patch 中的映射逐层合并，null 删除对应字段，其他值（含列表）整体替换；数字应以 json.Number 解码
+// 在 YAML 节点树上修改，未改动字段的顺序、引号与注释保留（空行不保留），输出以 2 空格缩进
+func MergePatch(content []byte, patch map[string]interface{}) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse rule: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("rule must be a mapping")
+	}
+	if err := mergeNode(doc.Content[0], patch); err != nil {
+		return nil, err
+	}
+	return encodeYAML(&doc)
+}
+
+// encodeYAML 以 2 空格缩进输出规则内容
+func encodeYAML(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode rule: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode rule: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeNode 将 patch 合并到映射节点，新增的字段按名称排序追加在末尾
+func mergeNode(node *yaml.Node, patch map[string]interface{}) error {
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := patch[key]
+		index := -1
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				index = i
+				break
+			}
+		}
+
+		if value == nil {
+			if index >= 0 {
+				node.Content = append(node.Content[:index], node.Content[index+2:]...)
+			}
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && index >= 0 && node.Content[index+1].Kind == yaml.MappingNode {
+			if err := mergeNode(node.Content[index+1], nested); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var valueNode yaml.Node
+		if err := valueNode.Encode(patchValue(value)); err != nil {
+			return fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+		if index >= 0 {
+			valueNode.HeadComment = node.Content[index+1].HeadComment
+			valueNode.LineComment = node.Content[index+1].LineComment
+			node.Content[index+1] = &valueNode
+			continue
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		node.Content = append(node.Content, keyNode, &valueNode)
+	}
+	return nil
+}
+
+// mergeYAML 将映射节点 src 合并到 dst，规则同 mergeNode；src 中的节点原样使用，数值、引号与注释不变
+func mergeYAML(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		index := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				index = j
+				break
+			}
+		}
+
+		switch {
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+			if index >= 0 {
+				dst.Content = append(dst.Content[:index], dst.Content[index+2:]...)
+			}
+		case value.Kind == yaml.MappingNode && index >= 0 && dst.Content[index+1].Kind == yaml.MappingNode:
+			mergeYAML(dst.Content[index+1], value)
+		case index >= 0:
+			dst.Content[index+1] = value
+		default:
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
+
+// patchValue 转换 patch 中新增的值：去掉映射中值为 null 的字段（RFC 7386 中 null 只表示删除），
+// json.Number 按原样输出为 YAML 数字，避免大整数（如 wei 金额）变成科学计数法
+func patchValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		pruned := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if item != nil {
+				pruned[k] = patchValue(item)
+			}
+		}
+		return pruned
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = patchValue(item)
+		}
+		return items
+	case json.Number:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: string(v)}
+	}
+	return value
+}
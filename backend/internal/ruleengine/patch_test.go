@@ -0,0 +1,149 @@
+package ruleengine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const patchTestRule = `# 规则说明
+metadata:
+  name: demo # 名称
+  enabled: true
+config:
+  severity: "high"
+  priority: 10
+  hooks: [contract_function_call]
+`
+
+// decodePatch 按 API 的方式解码 patch，数字保留为 json.Number
+func decodePatch(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+	var patch map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	if err := dec.Decode(&patch); err != nil {
+		t.Fatalf("invalid patch %s: %v", text, err)
+	}
+	return patch
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "replace scalar keeps comments and order",
+			patch: `{"metadata": {"enabled": false}}`,
+			want: `# 规则说明
+metadata:
+  name: demo # 名称
+  enabled: false
+config:
+  severity: "high"
+  priority: 10
+  hooks: [contract_function_call]
+`,
+		},
+		{
+			name:  "null deletes field",
+			patch: `{"config": {"priority": null}}`,
+			want: `# 规则说明
+metadata:
+  name: demo # 名称
+  enabled: true
+config:
+  severity: "high"
+  hooks: [contract_function_call]
+`,
+		},
+		{
+			name:  "list replaced as a whole",
+			patch: `{"config": {"hooks": ["a", "b"]}}`,
+			want: `# 规则说明
+metadata:
+  name: demo # 名称
+  enabled: true
+config:
+  severity: "high"
+  priority: 10
+  hooks:
+    - a
+    - b
+`,
+		},
+		{
+			name:  "big integer kept verbatim",
+			patch: `{"config": {"priority": 115792089237316195423570985008687907853269984665640564039457584007913129639935}}`,
+			want: `# 规则说明
+metadata:
+  name: demo # 名称
+  enabled: true
+config:
+  severity: "high"
+  priority: 115792089237316195423570985008687907853269984665640564039457584007913129639935
+  hooks: [contract_function_call]
+`,
+		},
+		{
+			name:  "new mapping appended with nulls dropped",
+			patch: `{"zeta": {"b": 1, "a": null, "c": {"x": null}}, "alpha": true}`,
+			want: `# 规则说明
+metadata:
+  name: demo # 名称
+  enabled: true
+config:
+  severity: "high"
+  priority: 10
+  hooks: [contract_function_call]
+alpha: true
+zeta:
+  b: 1
+  c: {}
+`,
+		},
+		{
+			name:  "mapping replaced by scalar",
+			patch: `{"metadata": "flat"}`,
+			want: `# 规则说明
+metadata: flat
+config:
+  severity: "high"
+  priority: 10
+  hooks: [contract_function_call]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(patchTestRule), decodePatch(t, tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch() error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("MergePatch() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"not a mapping", "- a\n- b\n", "rule must be a mapping"},
+		{"invalid yaml", "a: [\n", "failed to parse rule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MergePatch([]byte(tt.content), map[string]interface{}{"a": 1})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MergePatch() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
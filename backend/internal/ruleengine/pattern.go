@@ -0,0 +1,377 @@
+package ruleengine
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ConditionCallPattern 调用模式条件的类型，条件中设置了 pattern 时可以省略
+const ConditionCallPattern = "call_pattern"
+
+// CallPattern 调用模式，匹配调用树中的一条调用路径
+//
+// 语法：step (edge step)*
+//   - edge：-> 直接子调用，->> 任意深度的后代调用
+//   - step：目标 [限定, ...]，目标与限定列表至少写一个
+//   - 目标：变量名（如 A，绑定被调用地址）、* 任意地址、0x 开头的固定地址
+//   - 限定：调用类型（CALL、DELEGATECALL、STATICCALL 等）、函数选择器（0xa9059cbb）
+//     或函数签名（transfer(address,uint256)）、深度约束（depth>=3）
+//
+// 同名变量必须绑定同一地址，不同变量必须绑定不同地址，如 A->B->A 表示 A 调用 B 后 B 回调 A；
+// Within 大于 0 时，匹配路径首尾的深度差不能超过 Within
+type CallPattern struct {
+	Source string
+	Within int
+	steps  []patternStep
+	vars   []string // 按首次出现顺序排列的变量名
+}
+
+// patternStep 调用模式中的一步，对应调用树中的一个调用帧
+type patternStep struct {
+	descendant bool // 与上一步的关系：true 为任意后代，false 为直接子调用
+	variable   string
+	address    string
+	callType   string
+	selector   string
+	depth      []depthBound
+}
+
+// depthBound 深度约束，如 depth>=3
+type depthBound struct {
+	op    string
+	value int
+}
+
+var (
+	patternVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	patternSelectorPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{8}$`)
+	patternDepthPattern    = regexp.MustCompile(`^depth\s*(==|!=|<=|>=|=|<|>)\s*(\d+)$`)
+)
+
+// patternCallTypes 可用于限定的调用类型
+var patternCallTypes = map[string]bool{
+	"CALL":         true,
+	"DELEGATECALL": true,
+	"STATICCALL":   true,
+	"CALLCODE":     true,
+	"CREATE":       true,
+	"CREATE2":      true,
+	"SELFDESTRUCT": true,
+}
+
+// ParseCallPattern 解析调用模式，within 为空表示不限制深度差
+// 语法错误返回 ExprError，列号相对于 pattern
+func ParseCallPattern(pattern, within string) (*CallPattern, error) {
+	p := &CallPattern{Source: pattern}
+	if strings.TrimSpace(within) != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(within))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("within must be a positive call depth, got %q", within)
+		}
+		p.Within = n
+	}
+
+	seen := map[string]bool{}
+	start, descendant := 0, false
+	for {
+		end := strings.Index(pattern[start:], "->")
+		if end < 0 {
+			end = len(pattern)
+		} else {
+			end += start
+		}
+
+		step, err := parsePatternStep(pattern[start:end], start+1)
+		if err != nil {
+			return nil, err
+		}
+		step.descendant = descendant
+		if step.variable != "" && !seen[step.variable] {
+			seen[step.variable] = true
+			p.vars = append(p.vars, step.variable)
+		}
+		p.steps = append(p.steps, step)
+
+		if end == len(pattern) {
+			break
+		}
+		start, descendant = end+2, false
+		if strings.HasPrefix(pattern[start:], ">") {
+			start, descendant = start+1, true
+		}
+	}
+	return p, nil
+}
+
+// parsePatternStep 解析单步，column 为该步在模式中的起始列
+func parsePatternStep(src string, column int) (patternStep, error) {
+	var step patternStep
+	errorf := func(offset int, format string, args ...interface{}) error {
+		return &ExprError{Column: column + offset, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	target, qualifiers := src, ""
+	if open := strings.IndexByte(src, '['); open >= 0 {
+		if !strings.HasSuffix(strings.TrimSpace(src), "]") {
+			return step, errorf(open, "unterminated qualifier list")
+		}
+		target = src[:open]
+		qualifiers = strings.TrimSuffix(strings.TrimSpace(src[open+1:]), "]")
+	}
+
+	offset := len(src) - len(strings.TrimLeft(src, " \t"))
+	switch target = strings.TrimSpace(target); {
+	case target == "" && qualifiers == "":
+		return step, errorf(offset, "empty pattern step")
+	case target == "" || target == "*":
+	case strings.HasPrefix(target, "0x") || strings.HasPrefix(target, "0X"):
+		address, err := NormalizeAddress(target)
+		if err != nil {
+			return step, errorf(offset, "%v", err)
+		}
+		step.address = address
+	case patternVariablePattern.MatchString(target):
+		step.variable = target
+	default:
+		return step, errorf(offset, "invalid pattern step %q, expected a variable, * or an address", target)
+	}
+
+	if qualifiers == "" {
+		return step, nil
+	}
+	qualifierColumn := strings.IndexByte(src, '[') + 1
+	for _, part := range splitQualifiers(qualifiers) {
+		q := strings.TrimSpace(part)
+		switch upper := strings.ToUpper(q); {
+		case q == "":
+			return step, errorf(qualifierColumn, "empty qualifier")
+		case patternCallTypes[upper]:
+			step.callType = upper
+		case patternSelectorPattern.MatchString(q):
+			step.selector = strings.ToLower(q)
+		case strings.Contains(q, "("):
+			step.selector = functionSelector(q)
+		case strings.HasPrefix(q, "depth"):
+			m := patternDepthPattern.FindStringSubmatch(q)
+			if m == nil {
+				return step, errorf(qualifierColumn, "invalid depth constraint %q", q)
+			}
+			value, _ := strconv.Atoi(m[2])
+			step.depth = append(step.depth, depthBound{op: m[1], value: value})
+		default:
+			return step, errorf(qualifierColumn, "unknown qualifier %q, expected a call type, selector, function signature or depth constraint", q)
+		}
+	}
+	return step, nil
+}
+
+// splitQualifiers 按逗号拆分限定列表，函数签名参数中的逗号不拆分
+func splitQualifiers(s string) []string {
+	var parts []string
+	level, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			level++
+		case ')':
+			level--
+		case ',':
+			if level == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// functionSelector 计算函数签名的选择器，签名中的空白会被忽略
+func functionSelector(signature string) string {
+	signature = strings.Join(strings.Fields(signature), "")
+	return "0x" + hex.EncodeToString(crypto.Keccak256([]byte(signature))[:4])
+}
+
+// Variables 返回模式中的变量名，按首次出现顺序排列
+func (p *CallPattern) Variables() []string {
+	return p.vars
+}
+
+func (p *CallPattern) String() string {
+	return p.Source
+}
+
+// patternVariables 返回规则中所有调用模式声明的变量，语法错误的模式由 Validate 单独报告
+func (r *Rule) patternVariables() []string {
+	var vars []string
+	for _, cond := range r.Triggers.Conditions {
+		if cond.Expression != "" || cond.Pattern == "" {
+			continue
+		}
+		if pattern, err := ParseCallPattern(cond.Pattern, cond.Within); err == nil {
+			vars = append(vars, pattern.vars...)
+		}
+	}
+	return vars
+}
+
+// patternMatch 调用模式在某个交易上的匹配结果
+type patternMatch struct {
+	matched  bool
+	bindings map[string]string
+}
+
+// Match 在上下文的调用栈上匹配模式，返回第一个匹配（按调用顺序）的变量绑定
+// 同一交易上的结果缓存在上下文中，触发条件与数据提取共享一次匹配
+func (p *CallPattern) Match(ctx *EvaluationContext) (map[string]string, bool) {
+	if result, ok := ctx.patterns[p]; ok {
+		return result.bindings, result.matched
+	}
+
+	tree := newCallTree(ctx.CallStack)
+	bindings := make(map[string]string, len(p.vars))
+	result := patternMatch{}
+	for i := range tree.frames {
+		if p.matchStep(tree, 0, i, i, bindings) {
+			result = patternMatch{matched: true, bindings: bindings}
+			break
+		}
+	}
+
+	if ctx.patterns == nil {
+		ctx.patterns = make(map[*CallPattern]patternMatch)
+	}
+	ctx.patterns[p] = result
+	return result.bindings, result.matched
+}
+
+// matchStep 尝试让第 k 步匹配调用帧 i，并递归匹配剩余步骤，失败时回滚本步的变量绑定
+func (p *CallPattern) matchStep(tree *callTree, k, i, first int, bindings map[string]string) bool {
+	step := p.steps[k]
+	frame := &tree.frames[i]
+	if p.Within > 0 && frame.Depth-tree.frames[first].Depth > p.Within {
+		return false
+	}
+	if !step.matchFrame(frame) {
+		return false
+	}
+
+	bound := false
+	if step.variable != "" {
+		address := strings.ToLower(frame.To)
+		if current, ok := bindings[step.variable]; ok {
+			if current != address {
+				return false
+			}
+		} else {
+			for _, other := range bindings {
+				if other == address {
+					return false
+				}
+			}
+			bindings[step.variable] = address
+			bound = true
+		}
+	}
+
+	if k == len(p.steps)-1 {
+		return true
+	}
+	next := p.steps[k+1]
+	for j := i + 1; j < tree.end[i]; j++ {
+		if !next.descendant && tree.parent[j] != i {
+			continue
+		}
+		if p.matchStep(tree, k+1, j, first, bindings) {
+			return true
+		}
+	}
+
+	if bound {
+		delete(bindings, step.variable)
+	}
+	return false
+}
+
+// matchFrame 检查调用帧是否满足本步的地址、调用类型、选择器与深度约束
+func (s *patternStep) matchFrame(frame *CallFrame) bool {
+	if s.address != "" && !strings.EqualFold(frame.To, s.address) {
+		return false
+	}
+	if s.callType != "" && !strings.EqualFold(frame.Type, s.callType) {
+		return false
+	}
+	if s.selector != "" && !strings.EqualFold(frameSelector(frame), s.selector) {
+		return false
+	}
+	for _, bound := range s.depth {
+		if !bound.match(frame.Depth) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b depthBound) match(depth int) bool {
+	switch b.op {
+	case "=", "==":
+		return depth == b.value
+	case "!=":
+		return depth != b.value
+	case "<":
+		return depth < b.value
+	case "<=":
+		return depth <= b.value
+	case ">":
+		return depth > b.value
+	default:
+		return depth >= b.value
+	}
+}
+
+// frameSelector 返回调用帧的函数选择器，优先使用 Function，否则取 input 前 4 字节
+func frameSelector(frame *CallFrame) string {
+	if patternSelectorPattern.MatchString(frame.Function) {
+		return frame.Function
+	}
+	if len(frame.Input) >= 10 {
+		return frame.Input[:10]
+	}
+	return ""
+}
+
+// callTree 调用栈的树形索引
+// 调用栈按先序展开，某帧的父调用是它之前最近的深度小 1 的帧，其后代是紧随其后的一段连续区间
+type callTree struct {
+	frames []CallFrame
+	parent []int // 父调用下标，根调用为 -1
+	end    []int // 子树区间的结束下标（不含）
+}
+
+func newCallTree(frames []CallFrame) *callTree {
+	t := &callTree{
+		frames: frames,
+		parent: make([]int, len(frames)),
+		end:    make([]int, len(frames)),
+	}
+	var stack []int
+	for i, frame := range frames {
+		for len(stack) > 0 && frames[stack[len(stack)-1]].Depth >= frame.Depth {
+			t.end[stack[len(stack)-1]] = i
+			stack = stack[:len(stack)-1]
+		}
+		t.parent[i] = -1
+		if len(stack) > 0 {
+			t.parent[i] = stack[len(stack)-1]
+		}
+		stack = append(stack, i)
+	}
+	for _, i := range stack {
+		t.end[i] = len(frames)
+	}
+	return t
+}
@@ -0,0 +1,109 @@
+package ruleengine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const (
+	addrA = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	addrB = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	addrC = "0xcccccccccccccccccccccccccccccccccccccccc"
+	addrD = "0xdddddddddddddddddddddddddddddddddddddddd"
+)
+
+// reentrancyCallStack 按先序展开的调用栈：A.withdraw 调用 B，B 回调 A.withdraw；随后 A 静态调用 C，C delegatecall D
+func reentrancyCallStack() []CallFrame {
+	return []CallFrame{
+		{Type: "CALL", To: addrA, Input: "0x2e1a7d4d", Depth: 0},
+		{Type: "CALL", From: addrA, To: addrB, Depth: 1},
+		{Type: "CALL", From: addrB, To: addrA, Input: "0x2e1a7d4d0000", Depth: 2},
+		{Type: "STATICCALL", From: addrA, To: addrC, Depth: 1},
+		{Type: "DELEGATECALL", From: addrC, To: addrD, Depth: 2},
+	}
+}
+
+func TestCallPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		within   string
+		matched  bool
+		bindings map[string]string
+	}{
+		{pattern: "A->B->A", matched: true, bindings: map[string]string{"A": addrA, "B": addrB}},
+		{pattern: "A[withdraw(uint256)]->>B->A", matched: true, bindings: map[string]string{"A": addrA, "B": addrB}},
+		{pattern: "A[0x2e1a7d4d]->>A", matched: true, bindings: map[string]string{"A": addrA}},
+		// 不同变量绑定不同地址：A->B->A 的路径不满足 C，匹配 A->C->D
+		{pattern: "A->B->C", matched: true, bindings: map[string]string{"A": addrA, "B": addrC, "C": addrD}},
+		{pattern: "A->A", matched: false},
+		{pattern: "*->[DELEGATECALL, depth>=2]", matched: true, bindings: map[string]string{}},
+		{pattern: "*->[DELEGATECALL, depth>=3]", matched: false},
+		{pattern: "[STATICCALL]->[CALL]", matched: false},
+		{pattern: addrC + "->D[DELEGATECALL]", matched: true, bindings: map[string]string{"D": addrD}},
+		{pattern: "A->>B->A", within: "2", matched: true, bindings: map[string]string{"A": addrA, "B": addrB}},
+		{pattern: "A->>B->A", within: "1", matched: false},
+		{pattern: "A[transfer(address,uint256)]", matched: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.within, func(t *testing.T) {
+			pattern, err := ParseCallPattern(tt.pattern, tt.within)
+			if err != nil {
+				t.Fatalf("ParseCallPattern(%q) error: %v", tt.pattern, err)
+			}
+			ctx := NewEvaluationContext(nil, nil)
+			ctx.CallStack = reentrancyCallStack()
+
+			bindings, matched := pattern.Match(ctx)
+			if matched != tt.matched {
+				t.Fatalf("Match() = %v, want %v", matched, tt.matched)
+			}
+			if matched && !reflect.DeepEqual(bindings, tt.bindings) {
+				t.Errorf("Match() bindings = %v, want %v", bindings, tt.bindings)
+			}
+		})
+	}
+}
+
+func TestParseCallPatternErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		column  int
+		msg     string
+	}{
+		{"", 1, "empty pattern step"},
+		{"A->", 4, "empty pattern step"},
+		{"A[CALL", 2, "unterminated qualifier list"},
+		{"A[FOO]", 3, `unknown qualifier "FOO", expected a call type, selector, function signature or depth constraint`},
+		{"A->B[depth>>2]", 6, `invalid depth constraint "depth>>2"`},
+		{"0x123->B", 1, `invalid address "0x123"`},
+		{"1A", 1, `invalid pattern step "1A", expected a variable, * or an address`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			_, err := ParseCallPattern(tt.pattern, "")
+			var exprErr *ExprError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("ParseCallPattern(%q) error = %v, want *ExprError", tt.pattern, err)
+			}
+			if exprErr.Column != tt.column || exprErr.Msg != tt.msg {
+				t.Errorf("ParseCallPattern(%q) error = column %d: %s, want column %d: %s",
+					tt.pattern, exprErr.Column, exprErr.Msg, tt.column, tt.msg)
+			}
+		})
+	}
+
+	if _, err := ParseCallPattern("A", "0"); err == nil {
+		t.Error(`ParseCallPattern with within "0" succeeded, want error`)
+	}
+}
+
+func TestCallPatternVariables(t *testing.T) {
+	pattern, err := ParseCallPattern("A->B[CALL]->>A->*->C", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pattern.Variables(), []string{"A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
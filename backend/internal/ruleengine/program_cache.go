@@ -0,0 +1,275 @@
+package ruleengine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// CompiledRule 编译后的规则：触发条件与评分因子均已编译为 Program
+type CompiledRule struct {
+	Rule      *Rule
+	Operator  string             // 触发条件组合方式：AND / OR
+	OnMissing string             // 缺失变量处理策略：no_match / error
+	Extractor *Extractor         // 数据提取阶段，在触发条件之前执行
+	Patterns  []*CallPattern     // 触发条件中的调用模式，变量绑定在数据提取阶段写入上下文
+	Windows   []*WindowCondition // 触发条件中的窗口条件，由 ObserveWindows 在规则评估之前计数
+	filters   []evalFunc         // 与 Windows 一一对应，velocity target 的编译结果
+	Filter    *ruleFilter        // whitelist/blacklist，在触发条件之前检查
+	Triggers  []*Program         // 与 Rule.Triggers.Conditions 一一对应
+	Factors   []*Program         // 与 Rule.Scoring.Factors 一一对应
+}
+
+// CompileRule 编译规则中的所有表达式
+func CompileRule(rule *Rule) (*CompiledRule, error) {
+	evaluator := NewEvaluator()
+
+	operator := rule.Triggers.Operator
+	if operator == "" {
+		operator = "AND"
+	}
+	if operator != "AND" && operator != "OR" {
+		return nil, fmt.Errorf("unsupported operator: %s", operator)
+	}
+
+	onMissing := rule.Config.OnMissing
+	if onMissing == "" {
+		onMissing = OnMissingNoMatch
+	}
+
+	extractor, issues := rule.compileExtract(rule.variableSchema())
+	if len(issues) > 0 {
+		return nil, issues[0]
+	}
+	filter, issues := compileFilters(rule.Filters)
+	if len(issues) > 0 {
+		return nil, issues[0]
+	}
+	// 作用域由钩子在评估前检查（见 RuleSet.Select），这里只报告错误
+	if _, issues := compileScope(rule.Scope); len(issues) > 0 {
+		return nil, issues[0]
+	}
+
+	compiled := &CompiledRule{
+		Rule:      rule,
+		Operator:  operator,
+		OnMissing: onMissing,
+		Extractor: extractor,
+		Filter:    filter,
+		Triggers:  make([]*Program, 0, len(rule.Triggers.Conditions)),
+		Factors:   make([]*Program, 0, len(rule.Scoring.Factors)),
+	}
+
+	for i, cond := range rule.Triggers.Conditions {
+		node, err := rule.ParseCondition(cond)
+		if err != nil {
+			return nil, fmt.Errorf("triggers.conditions[%d]: %w", i, err)
+		}
+		program, err := evaluator.Compile(node)
+		if err != nil {
+			return nil, fmt.Errorf("triggers.conditions[%d]: %w", i, err)
+		}
+		compiled.Triggers = append(compiled.Triggers, program)
+		if pattern, ok := node.(*PatternExpr); ok {
+			compiled.Patterns = append(compiled.Patterns, pattern.Pattern)
+		}
+		if window, ok := windowOf(node); ok {
+			filter, err := window.compileFilter(evaluator)
+			if err != nil {
+				return nil, fmt.Errorf("triggers.conditions[%d]: %w", i, err)
+			}
+			compiled.Windows = append(compiled.Windows, window)
+			compiled.filters = append(compiled.filters, filter)
+		}
+	}
+
+	for i, factor := range rule.Scoring.Factors {
+		node, err := rule.ParseExpression(factor.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("scoring.factors[%d]: %w", i, err)
+		}
+		program, err := evaluator.Compile(node)
+		if err != nil {
+			return nil, fmt.Errorf("scoring.factors[%d]: %w", i, err)
+		}
+		compiled.Factors = append(compiled.Factors, program)
+	}
+
+	return compiled, nil
+}
+
+// Extract 执行数据提取，返回规则作用域的上下文，触发条件、评分和动作都应在该上下文上执行
+// 调用模式匹配成功时，绑定的地址按变量名写入 ExtractedData，多个模式绑定同名变量时以先声明的为准
+func (c *CompiledRule) Extract(ctx *EvaluationContext) (*EvaluationContext, error) {
+	if len(c.Patterns) > 0 {
+		data := make(map[string]interface{})
+		for _, pattern := range c.Patterns {
+			bindings, matched := pattern.Match(ctx)
+			if !matched {
+				continue
+			}
+			for name, address := range bindings {
+				if _, exists := data[name]; !exists {
+					data[name] = address
+				}
+			}
+		}
+		if len(data) > 0 {
+			ctx = ctx.WithExtractedData(data)
+		}
+	}
+	return c.Extractor.Run(ctx)
+}
+
+// ObserveWindows 将交易记入规则的所有窗口条件，velocity 的 target 在交易本身的上下文上求值
+func (c *CompiledRule) ObserveWindows(ctx *EvaluationContext) error {
+	var errs []error
+	for i, window := range c.Windows {
+		if _, err := window.Record(ctx, c.filters[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", window, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Match 按 Operator 组合触发条件，没有条件时视为命中
+// 先检查 filters：命中白名单直接不命中，命中黑名单时不受白名单影响，仍需满足触发条件；
+// 条件结果未知（变量缺失）时按三值逻辑组合，最终仍未知则按 OnMissing 处理
+func (c *CompiledRule) Match(ctx *EvaluationContext) (bool, error) {
+	if decision, _ := c.Filter.Check(ctx); decision == FilterAllow {
+		return false, nil
+	}
+
+	var unknown *MissingValueError
+	for _, trigger := range c.Triggers {
+		matched, err := trigger.Eval(ctx)
+		var missing *MissingValueError
+		if errors.As(err, &missing) {
+			if unknown == nil {
+				unknown = missing
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		// 短路求值
+		if c.Operator == "OR" && matched {
+			return true, nil
+		}
+		if c.Operator == "AND" && !matched {
+			return false, nil
+		}
+	}
+	if unknown != nil {
+		return false, c.missing(unknown)
+	}
+	return c.Operator == "AND" || len(c.Triggers) == 0, nil
+}
+
+// Test 执行单个表达式（如评分因子），结果未知时按 OnMissing 处理
+func (c *CompiledRule) Test(program *Program, ctx *EvaluationContext) (bool, error) {
+	matched, err := program.Eval(ctx)
+	var missing *MissingValueError
+	if errors.As(err, &missing) {
+		return false, c.missing(missing)
+	}
+	return matched, err
+}
+
+// missing 按策略处理未知结果：no_match 视为不匹配，error 返回错误
+func (c *CompiledRule) missing(err *MissingValueError) error {
+	if c.OnMissing == OnMissingError {
+		return err
+	}
+	return nil
+}
+
+// ProgramCache 规则编译缓存
+// 每个规则版本只编译一次，之后在所有交易间复用；规则以对象区分版本，
+// 热加载产生的新规则对象会自动触发重新编译，Reset 则整体失效
+// 缓存表只读共享，读取不加锁：写入时复制整张表后原子替换，写入之间由 mu 串行化
+type ProgramCache struct {
+	mu      sync.Mutex
+	entries atomic.Pointer[map[string]*CompiledRule] // key: 规则名
+}
+
+// NewProgramCache 创建编译缓存
+func NewProgramCache() *ProgramCache {
+	pc := &ProgramCache{}
+	pc.entries.Store(&map[string]*CompiledRule{})
+	return pc
+}
+
+// Get 获取规则的编译结果，未编译或规则已更新时重新编译
+// 规则通常已由 Reset 预编译，重新编译并复制缓存表只发生在规则版本变化后的第一次读取
+func (pc *ProgramCache) Get(rule *Rule) (*CompiledRule, error) {
+	compiled, ok := (*pc.entries.Load())[rule.Metadata.Name]
+	if ok && compiled.Rule == rule {
+		return compiled, nil
+	}
+
+	compiled, err := CompileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.mu.Lock()
+	current := *pc.entries.Load()
+	entries := make(map[string]*CompiledRule, len(current)+1)
+	for name, entry := range current {
+		entries[name] = entry
+	}
+	entries[rule.Metadata.Name] = compiled
+	pc.entries.Store(&entries)
+	pc.mu.Unlock()
+	return compiled, nil
+}
+
+// Reset 清空缓存并预编译给定规则，返回编译失败的规则及原因
+func (pc *ProgramCache) Reset(rules []*Rule) map[string]error {
+	entries := make(map[string]*CompiledRule, len(rules))
+	failed := make(map[string]error)
+	for _, rule := range rules {
+		compiled, err := CompileRule(rule)
+		if err != nil {
+			failed[rule.Metadata.Name] = err
+			continue
+		}
+		entries[rule.Metadata.Name] = compiled
+	}
+
+	pc.mu.Lock()
+	pc.entries.Store(&entries)
+	pc.mu.Unlock()
+	return failed
+}
+
+// Len 返回已缓存的规则数
+func (pc *ProgramCache) Len() int {
+	return len(*pc.entries.Load())
+}
+
+// ObserveWindows 在规则评估之前将交易记入所有已启用规则的窗口条件，每笔交易只计一次：
+// 计数不受全局名单、规则作用域、stop_on_match 与隔离的影响，规则评估时只读取结果；
+// 编译失败的规则跳过（由钩子报告），计数出错的规则汇总为 *RuleError 返回
+func (pc *ProgramCache) ObserveWindows(ctx *EvaluationContext, rules []*Rule) error {
+	if ctx.Windows == nil {
+		return nil
+	}
+	var errs []error
+	for _, rule := range rules {
+		if !rule.Metadata.Enabled {
+			continue
+		}
+		compiled, err := pc.Get(rule)
+		if err != nil || len(compiled.Windows) == 0 {
+			continue
+		}
+		if err := compiled.ObserveWindows(ctx); err != nil {
+			errs = append(errs, &RuleError{Rule: rule.Metadata.Name, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package ruleengine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestProgramCacheConcurrent 读取与热加载并发进行时，Get 总是返回给定规则对象的编译结果
+func TestProgramCacheConcurrent(t *testing.T) {
+	pc := NewProgramCache()
+	versions := make([][]*Rule, 3)
+	for v := range versions {
+		for i := 0; i < 4; i++ {
+			rule := testRule(fmt.Sprintf("rule-%d", i))
+			rule.Triggers.Conditions = []RuleCondition{{Expression: fmt.Sprintf("value > %d", v)}}
+			versions[v] = append(versions[v], rule)
+		}
+	}
+	if failed := pc.Reset(versions[0]); len(failed) != 0 {
+		t.Fatalf("Reset() failed = %v", failed)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				rule := versions[(g+i)%len(versions)][i%4]
+				compiled, err := pc.Get(rule)
+				if err != nil || compiled.Rule != rule {
+					t.Errorf("Get(%s) = %v, %v", rule.Metadata.Name, compiled, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		pc.Reset(versions[i%len(versions)])
+	}
+	wg.Wait()
+
+	if got := pc.Len(); got != 4 {
+		t.Errorf("Len() = %d, want 4", got)
+	}
+	broken := testRule("broken")
+	broken.Triggers.Conditions = []RuleCondition{{Expression: "value >"}}
+	if _, err := pc.Get(broken); err == nil {
+		t.Error("Get() of an invalid rule succeeded")
+	}
+	if got := pc.Len(); got != 4 {
+		t.Errorf("Len() after failed compile = %d, want 4", got)
+	}
+}
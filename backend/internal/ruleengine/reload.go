@@ -0,0 +1,307 @@
+package ruleengine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RulesInstancesKey 各实例采用的规则版本（Redis hash，键为实例 ID，值为 InstanceStatus）
+const RulesInstancesKey = "rules:instances"
+
+// RuleSet 一次加载得到的规则快照：加载后不再修改，处理交易时整体读取，热加载时整体替换
+type RuleSet struct {
+	Version  string    `json:"version"` // 见 RulesVersion
+	Rules    []*Rule   `json:"rules"`   // 启用的规则，按评估顺序排列
+	LoadedAt time.Time `json:"loaded_at"`
+
+	index *scopeIndex // 按作用域选择规则，见 Select
+}
+
+// NewRuleSet 由按评估顺序排列的规则创建快照并建立作用域索引
+func NewRuleSet(rules []*Rule) *RuleSet {
+	return &RuleSet{
+		Version:  RulesVersion(rules),
+		Rules:    rules,
+		LoadedAt: time.Now(),
+		index:    newScopeIndex(rules),
+	}
+}
+
+// Select 返回作用域（scope）包含该交易的规则，保持评估顺序
+func (s *RuleSet) Select(ctx *EvaluationContext) []*Rule {
+	if s.index == nil {
+		return s.Rules
+	}
+	return s.index.Select(ctx, s.Rules)
+}
+
+// RulesVersion 规则集的版本：按规则名排序后对各规则的内容哈希（Rule.Hash）计算 SHA-256，
+// 内容相同的规则集在各实例上得到相同的版本；scope 引用的监控合约解析结果也计入版本，合约变化后重新加载即产生新版本
+func RulesVersion(rules []*Rule) string {
+	sorted := make([]*Rule, len(rules))
+	copy(sorted, rules)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Metadata.Name < sorted[j].Metadata.Name
+	})
+
+	h := sha256.New()
+	for _, rule := range sorted {
+		fmt.Fprintf(h, "%s\x00%s", rule.Metadata.Name, rule.Hash)
+		if resolved := rule.Scope.Resolved; len(resolved) > 0 {
+			addresses := append([]string{}, resolved...)
+			sort.Strings(addresses)
+			fmt.Fprintf(h, "\x00%s", strings.Join(addresses, ","))
+		}
+		fmt.Fprint(h, "\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedRuleSet Redis 中缓存的规则集（rules:all）
+// 只保存规则内容与加载时确定的字段，采用时重新解析内容：规则中的 interface{} 值（如模板参数）经 JSON 往返后
+// 会变为 float64，超过 2^53 的整数将丢失精度
+type cachedRuleSet struct {
+	Version string       `json:"version"`
+	Rules   []cachedRule `json:"rules"`
+}
+
+// cachedRule 缓存的单条规则，Content 为展开后的规则内容，Resolved 为 scope 引用的监控合约解析结果
+type cachedRule struct {
+	Name     string   `json:"name"`
+	Revision int      `json:"revision"`
+	Content  string   `json:"content"`
+	Resolved []string `json:"resolved,omitempty"`
+}
+
+// newCachedRuleSet 由规则快照构造缓存内容
+func newCachedRuleSet(set *RuleSet) *cachedRuleSet {
+	cached := &cachedRuleSet{Version: set.Version, Rules: make([]cachedRule, len(set.Rules))}
+	for i, rule := range set.Rules {
+		cached.Rules[i] = cachedRule{
+			Name:     rule.Metadata.Name,
+			Revision: rule.Revision,
+			Content:  string(rule.content),
+			Resolved: rule.Scope.Resolved,
+		}
+	}
+	return cached
+}
+
+// loadCached 重新解析缓存的规则内容，任何一条规则无法加载时返回错误（调用方持有 mu）
+func (rm *RuleManager) loadCached(cached *cachedRuleSet) ([]*Rule, error) {
+	sources := make([]RuleSource, len(cached.Rules))
+	for i, entry := range cached.Rules {
+		if entry.Name == "" || entry.Content == "" {
+			return nil, fmt.Errorf("invalid cached rule at index %d", i)
+		}
+		sources[i] = RuleSource{Name: storeSource(entry.Name), Content: []byte(entry.Content)}
+	}
+	if err := rm.loader.LoadSources(sources); err != nil {
+		return nil, err
+	}
+
+	rules := make([]*Rule, 0, len(cached.Rules))
+	for _, entry := range cached.Rules {
+		rule, ok := rm.loader.GetRule(entry.Name)
+		if !ok {
+			return nil, fmt.Errorf("cached rule %q failed to load", entry.Name)
+		}
+		rule.Revision = entry.Revision
+		rule.Scope.Resolved = entry.Resolved
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// RuleUpdate 规则更新通知（rules:update），Version 为发布方加载后的规则版本
+type RuleUpdate struct {
+	Action    string `json:"action"`
+	Version   string `json:"version"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// InstanceStatus 实例当前采用的规则版本，每次加载或收到通知后更新
+type InstanceStatus struct {
+	Instance  string    `json:"instance"`
+	Version   string    `json:"version"`
+	Rules     int       `json:"rules"`
+	AdoptedAt time.Time `json:"adopted_at"`
+	Error     string    `json:"error,omitempty"` // 最近一次加载失败的原因，此时 Version 为仍在使用的旧版本
+}
+
+// instanceID 实例 ID：主机名（容器 ID）与进程号
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// install 编译规则并原子地替换当前快照，正在处理的交易继续使用旧快照
+func (rm *RuleManager) install(rules []*Rule) *RuleSet {
+	set := NewRuleSet(rules)
+	for name, err := range rm.programs.Reset(rules) {
+		rm.logger.Error("Failed to compile rule", zap.String("rule", name), zap.Error(err))
+	}
+	rm.current.Store(set)
+	return set
+}
+
+// Snapshot 返回当前的规则快照
+func (rm *RuleManager) Snapshot() *RuleSet {
+	return rm.current.Load()
+}
+
+// Version 返回当前规则的版本
+func (rm *RuleManager) Version() string {
+	return rm.Snapshot().Version
+}
+
+// GetRules 获取当前规则，按评估顺序排列；同一交易应只调用一次，以免前后使用不同版本的规则
+func (rm *RuleManager) GetRules() []*Rule {
+	return rm.Snapshot().Rules
+}
+
+// SubscribeUpdates 订阅规则更新，收到通知后采用通知中的规则版本
+func (rm *RuleManager) SubscribeUpdates(ctx context.Context) {
+	pubsub := rm.redis.Subscribe(ctx, RulesUpdateChannel)
+	defer pubsub.Close()
+
+	rm.logger.Info("Subscribed to rule updates")
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-ch:
+			rm.logger.Info("Received rule update notification", zap.String("message", msg.Payload))
+			var update RuleUpdate
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				rm.logger.Warn("Invalid rule update notification", zap.Error(err))
+			}
+			rm.adopt(ctx, update.Version)
+		}
+	}
+}
+
+// adopt 采用指定版本的规则：已是该版本时不重新加载；Redis 缓存为该版本时重新解析缓存的规则内容（省去查询数据库），否则从数据库加载
+// 版本为空（旧格式的通知）时总是从数据库加载，不使用可能过期的缓存
+func (rm *RuleManager) adopt(ctx context.Context, version string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.upToDate(version) {
+		rm.confirm(ctx, rm.Snapshot(), nil)
+		return
+	}
+
+	var cached cachedRuleSet
+	if version != "" && rm.redis.Get(ctx, RulesCacheKey, &cached) == nil && cached.Version == version {
+		rules, err := rm.loadCached(&cached)
+		if err == nil && RulesVersion(rules) == version {
+			SortRules(rules)
+			set := rm.install(rules)
+			rm.logger.Info("Rules reloaded from Redis",
+				zap.String("version", set.Version),
+				zap.Int("count", len(set.Rules)))
+			rm.confirm(ctx, set, nil)
+			return
+		}
+		if err != nil {
+			rm.logger.Warn("Failed to load cached rules, loading from database", zap.Error(err))
+		}
+	}
+
+	set, err := rm.loadFromStore(ctx)
+	if err != nil {
+		rm.logger.Error("Failed to reload rules", zap.Error(err))
+		rm.confirm(ctx, rm.Snapshot(), err)
+		return
+	}
+	if version != "" && set.Version != version {
+		// 通知发布后规则又有修改，随后的通知会带上新的版本
+		rm.logger.Warn("Loaded rules version differs from notification",
+			zap.String("expected", version),
+			zap.String("version", set.Version))
+	}
+	rm.confirm(ctx, set, nil)
+}
+
+// upToDate 判断本实例是否已是通知中的版本，版本为空时总是需要加载
+func (rm *RuleManager) upToDate(version string) bool {
+	return version != "" && version == rm.Version()
+}
+
+// confirm 在 Redis 中记录本实例采用的规则版本，供 API 确认各实例是否已更新
+func (rm *RuleManager) confirm(ctx context.Context, set *RuleSet, loadErr error) {
+	status := InstanceStatus{
+		Instance:  rm.instance,
+		Version:   set.Version,
+		Rules:     len(set.Rules),
+		AdoptedAt: time.Now(),
+	}
+	if loadErr != nil {
+		status.Error = loadErr.Error()
+	}
+	if err := rm.redis.HSet(ctx, RulesInstancesKey, rm.instance, status); err != nil {
+		rm.logger.Warn("Failed to record rules version", zap.Error(err))
+	}
+}
+
+// Instances 返回各实例采用的规则版本，按实例 ID 排序
+func (rm *RuleManager) Instances(ctx context.Context) ([]InstanceStatus, error) {
+	entries, err := rm.redis.HGetAll(ctx, RulesInstancesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]InstanceStatus, 0, len(entries))
+	for id, value := range entries {
+		var status InstanceStatus
+		if err := json.Unmarshal([]byte(value), &status); err != nil {
+			rm.logger.Warn("Invalid rules version entry", zap.String("instance", id), zap.Error(err))
+			continue
+		}
+		instances = append(instances, status)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Instance < instances[j].Instance
+	})
+	return instances, nil
+}
+
+// Deregister 实例退出时删除其版本记录
+func (rm *RuleManager) Deregister(ctx context.Context) error {
+	return rm.redis.HDel(ctx, RulesInstancesKey, rm.instance)
+}
+
+// PublishUpdate 发布规则更新通知，带上当前规则的版本
+func (rm *RuleManager) PublishUpdate(ctx context.Context) error {
+	return rm.redis.Publish(ctx, RulesUpdateChannel, rm.update(true))
+}
+
+// PublishReload 发布不带版本的规则更新通知，其他实例收到后从数据库加载
+// 用于规则已写入数据库但本实例未能加载的情况：本实例的版本仍是旧版本，带上它的通知会被其他实例视为已是最新
+func (rm *RuleManager) PublishReload(ctx context.Context) error {
+	return rm.redis.Publish(ctx, RulesUpdateChannel, rm.update(false))
+}
+
+// update 构造规则更新通知，loaded 为 false 时不带版本
+func (rm *RuleManager) update(loaded bool) RuleUpdate {
+	update := RuleUpdate{Action: "reload", Timestamp: time.Now().Unix()}
+	if loaded {
+		update.Version = rm.Version()
+	}
+	return update
+}
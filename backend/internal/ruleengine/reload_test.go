@@ -0,0 +1,171 @@
+package ruleengine
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRulesVersion(t *testing.T) {
+	rule := func(name, hash string, resolved ...string) *Rule {
+		r := testRule(name)
+		r.Hash = hash
+		r.Scope.Resolved = resolved
+		return r
+	}
+	base := RulesVersion([]*Rule{rule("a", "h1"), rule("b", "h2", addrA, addrB)})
+	tests := []struct {
+		name  string
+		rules []*Rule
+		same  bool
+	}{
+		{"rule order", []*Rule{rule("b", "h2", addrA, addrB), rule("a", "h1")}, true},
+		{"resolved order", []*Rule{rule("a", "h1"), rule("b", "h2", addrB, addrA)}, true},
+		{"content changed", []*Rule{rule("a", "h3"), rule("b", "h2", addrA, addrB)}, false},
+		{"contract resolved", []*Rule{rule("a", "h1"), rule("b", "h2", addrA)}, false},
+		{"rule removed", []*Rule{rule("a", "h1")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RulesVersion(tt.rules) == base; got != tt.same {
+				t.Errorf("RulesVersion() same = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+// TestInstallSnapshot 热加载期间读取的快照始终完整：版本与规则一致，规则都已编译
+func TestInstallSnapshot(t *testing.T) {
+	rm := NewRuleManager("", nil, nil, nil, nil, zap.NewNop())
+	sets := [][]*Rule{{testRule("a")}, {testRule("a"), testRule("b")}, {testRule("c")}}
+	rm.install(sets[0])
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				set := rm.Snapshot()
+				if set.Version != RulesVersion(set.Rules) {
+					t.Errorf("snapshot version %s does not match its rules", set.Version)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		rm.install(sets[i%len(sets)])
+	}
+	close(done)
+	wg.Wait()
+
+	set := rm.Snapshot()
+	for _, rule := range set.Rules {
+		if _, err := rm.Programs().Get(rule); err != nil {
+			t.Errorf("rule %s not compiled: %v", rule.Metadata.Name, err)
+		}
+	}
+}
+
+// TestCachedRuleSet 缓存的规则集经 JSON 往返并重新解析后与原规则一致，超过 2^53 的整数默认值不丢失精度
+func TestCachedRuleSet(t *testing.T) {
+	const content = `metadata:
+  name: "threshold"
+  enabled: true
+config:
+  hooks: [contract_function_call]
+variables:
+  limit:
+    type: uint256
+    default: 9007199254740993
+triggers:
+  conditions:
+    - expression: "value > limit"
+`
+	writer := NewRuleManager("", nil, nil, nil, nil, zap.NewNop())
+	if err := writer.loader.LoadSources([]RuleSource{{Name: storeSource("threshold"), Content: []byte(content)}}); err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := writer.loader.GetRule("threshold")
+	if !ok {
+		t.Fatal("rule failed to load")
+	}
+	rule.Revision = 3
+	rule.Scope.Resolved = []string{addrA}
+	set := writer.install([]*Rule{rule})
+
+	data, err := json.Marshal(newCachedRuleSet(set))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached cachedRuleSet
+	if err := json.Unmarshal(data, &cached); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewRuleManager("", nil, nil, nil, nil, zap.NewNop())
+	rules, err := reader.loadCached(&cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reader.install(rules).Version; got != set.Version || got != cached.Version {
+		t.Errorf("version = %s, want %s", got, set.Version)
+	}
+	got := rules[0]
+	if got.Revision != 3 || !reflect.DeepEqual(got.Scope.Resolved, []string{addrA}) {
+		t.Errorf("revision = %d, resolved = %v", got.Revision, got.Scope.Resolved)
+	}
+	if def := got.Variables["limit"].Default; def != 9007199254740993 {
+		t.Errorf("limit default = %#v, want 9007199254740993", def)
+	}
+
+	cached.Rules[0].Content = ""
+	if _, err := reader.loadCached(&cached); err == nil {
+		t.Error("loadCached() with empty content succeeded, want error")
+	}
+	cached.Rules[0] = cachedRule{Name: "threshold", Content: "metadata: ["}
+	if _, err := reader.loadCached(&cached); err == nil || err.Error() != `cached rule "threshold" failed to load` {
+		t.Errorf("loadCached() error = %v", err)
+	}
+}
+
+// TestRuleUpdateVersion 发布方未能加载修改时，通知不带版本，与发布方同为旧版本的实例仍会重新加载
+func TestRuleUpdateVersion(t *testing.T) {
+	api := NewRuleManager("", nil, nil, nil, nil, zap.NewNop())
+	rds := NewRuleManager("", nil, nil, nil, nil, zap.NewNop())
+	old := []*Rule{testRule("a")}
+	api.install(old)
+	rds.install(old)
+
+	tests := []struct {
+		name     string
+		loaded   bool
+		upToDate bool
+	}{
+		{"loaded", true, true},
+		{"reload failed", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := api.update(tt.loaded)
+			if got := rds.upToDate(update.Version); got != tt.upToDate {
+				t.Errorf("upToDate(%q) = %v, want %v", update.Version, got, tt.upToDate)
+			}
+		})
+	}
+
+	rds.install([]*Rule{testRule("a"), testRule("b")})
+	if rds.upToDate(api.Version()) {
+		t.Error("upToDate() = true for a different version")
+	}
+}
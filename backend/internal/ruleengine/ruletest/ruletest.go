@@ -0,0 +1,296 @@
+// Package ruletest 执行规则测试用例（规则的 tests 与 *_test.yaml）
+// 用例的交易按 RDS 的流程处理：构造评估上下文、触发 contract_function_call 钩子、评分并渲染告警，
+// 不连接 Redis 与数据库：窗口条件使用内存计数，不执行限流与全局名单
+package ruletest
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/haswell/bcscan/internal/ruleengine"
+	"github.com/haswell/bcscan/internal/ruleengine/hooks"
+	"go.uber.org/zap"
+)
+
+// Result 单个用例的结果
+type Result struct {
+	Rule     string   `json:"rule"`
+	File     string   `json:"file"`
+	Case     string   `json:"case"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"` // 期望与实际不一致的项
+	Error    string   `json:"error,omitempty"`    // 用例无法执行（交易格式错误、规则求值出错等）
+}
+
+// Name 用例的完整名称：<规则>/<用例>
+func (r Result) Name() string {
+	return r.Rule + "/" + r.Case
+}
+
+// Report 规则测试结果
+type Report struct {
+	Passed      int                     `json:"passed"`
+	Failed      int                     `json:"failed"`
+	Diagnostics []ruleengine.Diagnostic `json:"diagnostics"` // 规则或测试文件中的错误
+	Results     []Result                `json:"results"`
+}
+
+// OK 所有用例通过且规则与测试文件没有错误
+func (r *Report) OK() bool {
+	return r.Failed == 0 && len(r.Diagnostics) == 0
+}
+
+// RunDir 加载规则目录并执行其中的所有测试用例，run 不为空时只执行名称（<规则>/<用例>）匹配的用例
+// 规则或测试文件有错误时记入 Diagnostics，其余规则的用例照常执行；只有文件无法读取时返回错误
+func RunDir(rulesDir string, run *regexp.Regexp) (*Report, error) {
+	report := &Report{Diagnostics: []ruleengine.Diagnostic{}, Results: []Result{}}
+
+	// 无法加载的规则只会记录日志，先用 lint 找出来，避免其用例被静默跳过；测试文件的问题由 TestSuites 报告
+	lint, err := ruleengine.NewRuleLoader(rulesDir, zap.NewNop()).Lint()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range lint.Diagnostics {
+		if d.Level != ruleengine.LevelWarning && !strings.HasSuffix(d.File, ruleengine.TestFileSuffix) {
+			report.Diagnostics = append(report.Diagnostics, d)
+		}
+	}
+
+	loader := ruleengine.NewRuleLoader(rulesDir, zap.NewNop())
+	if err := loader.LoadAll(); err != nil {
+		return nil, err
+	}
+	suites, diags, err := loader.TestSuites()
+	if err != nil {
+		return nil, err
+	}
+	report.Diagnostics = append(report.Diagnostics, diags...)
+
+	for _, suite := range suites {
+		for _, result := range RunSuite(suite, run) {
+			if result.Passed {
+				report.Passed++
+			} else {
+				report.Failed++
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+// RunSuite 执行一条规则的测试用例，规则未启用时也会执行
+func RunSuite(suite *ruleengine.TestSuite, run *regexp.Regexp) []Result {
+	rule := *suite.Rule
+	rule.Metadata.Enabled = true
+	programs := ruleengine.NewProgramCache()
+	dir := filepath.Dir(suite.File)
+
+	var results []Result
+	for i, tc := range suite.Tests {
+		result := Result{Rule: rule.Metadata.Name, File: suite.File, Case: tc.Name}
+		if result.Case == "" {
+			result.Case = fmt.Sprintf("case %d", i+1)
+		}
+		if run != nil && !run.MatchString(result.Name()) {
+			continue
+		}
+
+		failures, err := runCase(&rule, programs, dir, tc)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Failures = failures
+		result.Passed = err == nil && len(failures) == 0
+		results = append(results, result)
+	}
+	return results
+}
+
+// runCase 按 RDS 的流程处理用例的交易并与期望比较，返回不一致的项
+func runCase(rule *ruleengine.Rule, programs *ruleengine.ProgramCache, dir string, tc ruleengine.RuleTest) ([]string, error) {
+	manager := hooks.NewManager()
+	manager.Register(hooks.NewContractFunctionHook(programs, nil))
+	windows := ruleengine.NewWindowStore(nil, zap.NewNop())
+	rules := ruleengine.NewRuleSet([]*ruleengine.Rule{rule})
+
+	// 历史交易只用于累积窗口计数
+	for i, history := range tc.History {
+		txData, err := ruleengine.DecodeTransaction(history)
+		if err != nil {
+			return nil, fmt.Errorf("history[%d]: %w", i, err)
+		}
+		ctx := hooks.NewEvaluationContext(txData)
+		ctx.Windows = windows
+		if err := programs.ObserveWindows(ctx, rules.Rules); err != nil {
+			return nil, fmt.Errorf("history[%d]: %w", i, err)
+		}
+		if _, err := manager.Trigger(ruleengine.HookContractFunctionCall, ctx, rules); err != nil {
+			return nil, fmt.Errorf("history[%d]: %w", i, err)
+		}
+	}
+
+	txData, err := tc.Input(dir)
+	if err != nil {
+		return nil, err
+	}
+	ctx := hooks.NewEvaluationContext(txData)
+	ctx.Windows = windows
+	if err := programs.ObserveWindows(ctx, rules.Rules); err != nil {
+		return nil, err
+	}
+	events, err := manager.Trigger(ruleengine.HookContractFunctionCall, ctx, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var actual outcome
+	if len(events) > 0 {
+		actual.matched = true
+		actual.ctx = events[0].Context
+		actual.score, err = ruleengine.NewScorer(programs).CalculateScore(rule, actual.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate score: %w", err)
+		}
+		for _, action := range rule.Actions {
+			if action.Type == "alert" {
+				title, message := ruleengine.NewExecutor(nil).RenderAlert(action, actual.ctx)
+				actual.alert = &alert{title: title, message: message}
+				break
+			}
+		}
+	}
+	return compare(tc.Expect, actual), nil
+}
+
+// outcome 用例的实际结果
+type outcome struct {
+	matched bool
+	score   int
+	ctx     *ruleengine.EvaluationContext
+	alert   *alert
+}
+
+type alert struct {
+	title   string
+	message string
+}
+
+// compare 比较期望与实际结果，返回差异描述
+func compare(expect ruleengine.RuleExpect, actual outcome) []string {
+	var failures []string
+	if expect.Match != nil && *expect.Match != actual.matched {
+		failures = append(failures, fmt.Sprintf("match: expected %t, got %t", *expect.Match, actual.matched))
+	}
+
+	// 其余各项只在规则命中时才有结果
+	if !actual.matched {
+		if expect.Score != nil || len(expect.Extracted) > 0 || expect.Alert != nil {
+			if expect.Match == nil {
+				failures = append(failures, "match: rule did not match, cannot check score, extracted or alert")
+			}
+		}
+		return failures
+	}
+
+	if expect.Score != nil && *expect.Score != actual.score {
+		failures = append(failures, fmt.Sprintf("score: expected %d, got %d", *expect.Score, actual.score))
+	}
+
+	for _, name := range sortedKeys(expect.Extracted) {
+		want := expect.Extracted[name]
+		got, ok := actual.ctx.GetExtractedValue(name)
+		switch {
+		case want == nil && ok:
+			failures = append(failures, fmt.Sprintf("extracted.%s: expected <missing>, got %s", name, format(got)))
+		case want == nil:
+		case !ok:
+			failures = append(failures, fmt.Sprintf("extracted.%s: expected %s, got <missing>", name, format(want)))
+		case fmt.Sprint(want) != fmt.Sprint(got):
+			failures = append(failures, fmt.Sprintf("extracted.%s: expected %s, got %s", name, format(want), format(got)))
+		}
+	}
+
+	if expect.Alert != nil {
+		if actual.alert == nil {
+			failures = append(failures, "alert: expected an alert, rule has no alert action")
+			return failures
+		}
+		if expect.Alert.Title != nil && *expect.Alert.Title != actual.alert.title {
+			failures = append(failures, "alert.title:\n"+diff(*expect.Alert.Title, actual.alert.title))
+		}
+		if expect.Alert.Message != nil && *expect.Alert.Message != actual.alert.message {
+			failures = append(failures, "alert.message:\n"+diff(*expect.Alert.Message, actual.alert.message))
+		}
+	}
+	return failures
+}
+
+// format 格式化变量值，字符串加引号以区分空白与类型
+func format(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// diff 逐行比较期望与实际文本：相同的行原样输出，不同的行以 - 期望 / + 实际 标出
+func diff(want, got string) string {
+	wantLines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	gotLines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	var sb strings.Builder
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		switch {
+		case i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i]:
+			fmt.Fprintf(&sb, "    %s\n", wantLines[i])
+		default:
+			if i < len(wantLines) {
+				fmt.Fprintf(&sb, "  - %s\n", wantLines[i])
+			}
+			if i < len(gotLines) {
+				fmt.Fprintf(&sb, "  + %s\n", gotLines[i])
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Run 在 Go 测试中执行规则目录下的所有用例，每个用例是一个子测试（<规则>/<用例>），失败时输出差异
+//
+//	func TestRules(t *testing.T) {
+//		ruletest.Run(t, "../../rules/builtin")
+//	}
+func Run(t *testing.T, rulesDir string) {
+	t.Helper()
+	report, err := RunDir(rulesDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range report.Diagnostics {
+		t.Error(d.String())
+	}
+	for _, result := range report.Results {
+		t.Run(result.Name(), func(t *testing.T) {
+			if result.Error != "" {
+				t.Fatalf("%s: %s", result.File, result.Error)
+			}
+			for _, failure := range result.Failures {
+				t.Errorf("%s: %s", result.File, failure)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package ruletest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRules(t *testing.T) {
+	Run(t, "../../../rules/builtin")
+}
+
+const transferRule = `metadata:
+  name: "transfer"
+config:
+  hooks: [contract_function_call]
+triggers:
+  conditions:
+    - expression: "value > 1 ether"
+extract:
+  transaction:
+    - field: "from"
+      as: "sender"
+scoring:
+  base_score: 60
+actions:
+  - type: "alert"
+    title: "大额转账"
+    message: "发送方 {{sender}}"
+tests:
+  - name: "match"
+    transaction: {tx_hash: "0x1", from_address: "0x1111111111111111111111111111111111111111", value: "2000000000000000000"}
+    expect:
+      match: true
+      score: 60
+      extracted: {sender: "0x1111111111111111111111111111111111111111"}
+      alert: {title: "大额转账", message: "发送方 0x1111111111111111111111111111111111111111"}
+  - name: "wrong expectations"
+    transaction: {tx_hash: "0x2", from_address: "0x1111111111111111111111111111111111111111", value: "2000000000000000000"}
+    expect:
+      score: 50
+      extracted: {sender: "0x2222222222222222222222222222222222222222", missing: 1}
+      alert: {message: "发送方 0x2"}
+  - name: "small"
+    transaction: {tx_hash: "0x3", value: "1"}
+    expect: {match: false}
+  - transaction: "{\"tx_hash\": \"0x4\", \"valeu\": \"1\"}"
+    expect: {match: false}
+`
+
+func TestRunDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"transfer.yaml": transferRule,
+		"transfer_test.yaml": `tests:
+  - name: "not matched"
+    transaction: {tx_hash: "0x5", value: "0"}
+    expect: {score: 60}
+`,
+		"orphan_test.yaml": `rule: "missing"
+tests: []
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := RunDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []string
+	for _, d := range report.Diagnostics {
+		diags = append(diags, strings.TrimPrefix(d.String(), dir+string(filepath.Separator)))
+	}
+	if want := []string{`orphan_test.yaml:1:7: rule "missing" is not loaded`}; !reflect.DeepEqual(diags, want) {
+		t.Errorf("Diagnostics = %v, want %v", diags, want)
+	}
+
+	type result struct {
+		name     string
+		passed   bool
+		failures []string
+		err      string
+	}
+	var got []result
+	for _, r := range report.Results {
+		got = append(got, result{r.Name(), r.Passed, r.Failures, r.Error})
+	}
+	want := []result{
+		{name: "transfer/match", passed: true},
+		{name: "transfer/wrong expectations", failures: []string{
+			"score: expected 50, got 60",
+			"extracted.missing: expected 1, got <missing>",
+			`extracted.sender: expected "0x2222222222222222222222222222222222222222", got "0x1111111111111111111111111111111111111111"`,
+			"alert.message:\n  - 发送方 0x2\n  + 发送方 0x1111111111111111111111111111111111111111",
+		}},
+		{name: "transfer/small", passed: true},
+		{name: "transfer/case 4", err: `invalid transaction: json: unknown field "valeu"`},
+		{name: "transfer/not matched", failures: []string{"match: rule did not match, cannot check score, extracted or alert"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Results =\n%+v\nwant\n%+v", got, want)
+	}
+	if report.Passed != 2 || report.Failed != 3 || report.OK() {
+		t.Errorf("Report = %d passed, %d failed, OK %v", report.Passed, report.Failed, report.OK())
+	}
+
+	filtered, err := RunDir(dir, regexp.MustCompile(`^transfer/(match|small)$`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filtered.Passed != 2 || filtered.Failed != 0 {
+		t.Errorf("filtered Report = %d passed, %d failed, want 2 passed", filtered.Passed, filtered.Failed)
+	}
+}
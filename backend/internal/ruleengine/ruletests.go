@@ -0,0 +1,141 @@
+package ruleengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestFileSuffix 独立规则测试文件的后缀，加载规则时跳过
+const TestFileSuffix = "_test.yaml"
+
+// TestSuite 一条规则的测试用例及其所在文件
+type TestSuite struct {
+	Rule  *Rule
+	File  string
+	Tests []RuleTest
+}
+
+// TestSuites 返回已加载规则的测试用例：规则内的 tests 与同目录下的 *_test.yaml，按文件名排序
+// 测试文件中的未知字段、引用了未加载的规则等问题作为诊断信息返回，只有文件无法读取时返回错误
+func (rl *RuleLoader) TestSuites() ([]*TestSuite, []Diagnostic, error) {
+	var suites []*TestSuite
+	for _, name := range sortedKeys(rl.rules) {
+		if rule := rl.rules[name]; len(rule.Tests) > 0 {
+			suites = append(suites, &TestSuite{Rule: rule, File: rl.files[name], Tests: rule.Tests})
+		}
+	}
+
+	files, err := rl.yamlFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	byFile := make(map[string]*Rule, len(rl.files))
+	for name, file := range rl.files {
+		byFile[file] = rl.rules[name]
+	}
+
+	var diags []Diagnostic
+	for _, file := range files {
+		if !strings.HasSuffix(file, TestFileSuffix) {
+			continue
+		}
+		testFile, doc, fileDiags, err := parseTestFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		diags = append(diags, fileDiags...)
+		if testFile == nil {
+			continue
+		}
+
+		rule, ok := byFile[strings.TrimSuffix(file, TestFileSuffix)+".yaml"]
+		if testFile.Rule != "" {
+			rule, ok = rl.rules[testFile.Rule]
+		}
+		if !ok {
+			name := testFile.Rule
+			if name == "" {
+				name = filepath.Base(strings.TrimSuffix(file, TestFileSuffix))
+			}
+			diags = append(diags, located(file, doc, Diagnostic{
+				Level:   LevelError,
+				Message: fmt.Sprintf("rule %q is not loaded", name),
+				keys:    []interface{}{"rule"},
+			}))
+			continue
+		}
+		suites = append(suites, &TestSuite{Rule: rule, File: file, Tests: testFile.Tests})
+	}
+	return suites, diags, nil
+}
+
+// parseTestFile 严格解析测试文件，无法解码时 testFile 为 nil
+func parseTestFile(file string) (*RuleTestFile, *yaml.Node, []Diagnostic, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read test file %s: %w", file, err)
+	}
+
+	var doc yaml.Node
+	var testFile RuleTestFile
+	if err := yaml.Unmarshal(data, &doc); err == nil {
+		err = doc.Decode(&testFile)
+	}
+	if err != nil {
+		diags := decodeDiagnostics(err)
+		locateDiagnostics(file, data, &doc, diags)
+		return nil, &doc, diags, nil
+	}
+
+	diags := checkKnownFields(&doc, reflect.TypeOf(testFile), nil)
+	locateDiagnostics(file, data, &doc, diags)
+	return &testFile, &doc, diags, nil
+}
+
+// Input 返回用例的交易消息，dir 为用例所在文件的目录
+func (t RuleTest) Input(dir string) (*TransactionData, error) {
+	switch {
+	case t.TransactionFile != "" && t.Transaction != nil:
+		return nil, fmt.Errorf("transaction and transaction_file are mutually exclusive")
+	case t.TransactionFile != "":
+		file := t.TransactionFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transaction file: %w", err)
+		}
+		return DecodeTransaction(string(data))
+	case t.Transaction == nil:
+		return nil, fmt.Errorf("transaction or transaction_file is required")
+	}
+	return DecodeTransaction(t.Transaction)
+}
+
+// DecodeTransaction 将 JSON 字符串或 YAML 解码得到的映射转换为交易消息，不允许未知字段
+func DecodeTransaction(v interface{}) (*TransactionData, error) {
+	data, ok := v.(string)
+	if !ok {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction: %w", err)
+		}
+		data = string(encoded)
+	}
+
+	var tx TransactionData
+	dec := json.NewDecoder(bytes.NewReader([]byte(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&tx); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	return &tx, nil
+}
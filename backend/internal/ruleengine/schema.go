@@ -0,0 +1,118 @@
+package ruleengine
+
+// callFrameType 调用帧元素类型（call_stack 的元素）
+var callFrameType = &ExprType{
+	Kind: TypeObject,
+	Name: "call_frame",
+	Fields: map[string]*ExprType{
+		"type":     stringType,
+		"from":     addressType,
+		"to":       addressType,
+		"value":    uint256Type,
+		"gas":      uint256Type,
+		"gas_used": uint256Type,
+		"input":    stringType,
+		"output":   stringType,
+		"error":    stringType,
+		"failed":   boolType,
+		"depth":    uint256Type,
+		"function": stringType,
+		"selector": stringType,
+	},
+}
+
+// eventLogType 事件日志元素类型（events 的元素）
+var eventLogType = &ExprType{
+	Kind: TypeObject,
+	Name: "event_log",
+	Fields: map[string]*ExprType{
+		"address": addressType,
+		"topics":  listOf(stringType),
+		"data":    stringType,
+	},
+}
+
+// accountDiffType 账户状态差异元素类型（state_diff 的元素）
+var accountDiffType = &ExprType{
+	Kind: TypeObject,
+	Name: "account_diff",
+	Fields: map[string]*ExprType{
+		"address":            addressType,
+		"balance_before":     uint256Type,
+		"balance_after":      uint256Type,
+		"balance_change":     numberType,
+		"balance_change_pct": numberType,
+		"nonce_before":       uint256Type,
+		"nonce_after":        uint256Type,
+		"code_changed":       boolType,
+		"created":            boolType,
+		"destroyed":          boolType,
+		"storage_changes":    uint256Type,
+	},
+}
+
+// storageChangeType 存储槽变化元素类型（storage_changes 的元素）
+var storageChangeType = &ExprType{
+	Kind: TypeObject,
+	Name: "storage_change",
+	Fields: map[string]*ExprType{
+		"address": addressType,
+		"slot":    stringType,
+		"name":    stringType,
+		"before":  stringType,
+		"after":   stringType,
+	},
+}
+
+// builtinSchema 内置变量表，由字段注册表生成
+var builtinSchema = func() VariableSchema {
+	schema := make(VariableSchema, len(fieldRegistry))
+	for _, f := range fieldRegistry {
+		schema[f.Name] = f.Type
+	}
+	return schema
+}()
+
+// DefaultSchema 返回内置变量表的副本
+func DefaultSchema() VariableSchema {
+	return builtinSchema.Clone()
+}
+
+// Schema 返回规则可引用的变量：内置变量加上 variables 与 extract 中声明的变量
+// 提取的变量在运行时优先于同名内置变量，variables 中声明的类型优先于提取字段的类型
+func (r *Rule) Schema() VariableSchema {
+	schema := r.variableSchema()
+	extractor, _ := r.compileExtract(schema.Clone())
+	for name, t := range extractor.types {
+		if _, declared := r.Variables[name]; !declared {
+			schema[name] = t
+		}
+	}
+	for _, name := range r.patternVariables() {
+		if _, exists := schema[name]; !exists {
+			schema[name] = addressType
+		}
+	}
+	return schema
+}
+
+// variableSchema 返回内置变量加上 variables 中声明的变量
+func (r *Rule) variableSchema() VariableSchema {
+	schema := DefaultSchema()
+	for name, variable := range r.Variables {
+		t, err := variable.ExprType()
+		if err != nil {
+			t = anyType // 类型错误由 Validate 单独报告
+		}
+		schema[name] = t
+	}
+	return schema
+}
+
+// ExprType 返回声明的变量类型，未声明时为 any
+func (v RuleVariable) ExprType() (*ExprType, error) {
+	if v.Type == "" {
+		return anyType, nil
+	}
+	return ParseTypeName(v.Type)
+}
@@ -0,0 +1,223 @@
+package ruleengine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RuleScope 规则作用域：只评估涉及这些合约、链与函数的交易，未设置的项不限制
+// 合约与函数匹配交易本身的调用及调用栈中的任意调用，同时设置时须是同一次调用（如对目标合约的 withdraw 调用）
+type RuleScope struct {
+	Contracts []string `yaml:"contracts"` // 合约地址
+	// MonitoredContracts 引用 monitored_contracts 中的合约（按名称），从数据库加载规则时解析为地址；
+	// 无法解析时规则不会命中任何合约，规则测试等不连接数据库的场景应使用 contracts
+	MonitoredContracts []string `yaml:"monitored_contracts"`
+	Chains             []uint64 `yaml:"chains"`    // 链 ID，消息中没有链 ID 时不限制
+	Functions          []string `yaml:"functions"` // 函数签名（如 withdraw(uint256)，参数名可省略）或 4 字节选择器
+
+	// Resolved MonitoredContracts 解析得到的地址，加载时填充
+	Resolved []string `yaml:"-"`
+}
+
+// selectorPattern 4 字节函数选择器
+var selectorPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{8}$`)
+
+// FunctionSelector 返回函数签名的选择器（0x 开头的小写十六进制），签名本身是选择器时原样返回
+// 参数名被忽略；不支持 tuple 参数
+func FunctionSelector(signature string) (string, error) {
+	signature = strings.TrimSpace(signature)
+	if selectorPattern.MatchString(signature) {
+		return strings.ToLower(signature), nil
+	}
+
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return "", fmt.Errorf("function must be a signature such as %q or a selector such as %q", "withdraw(uint256)", "0x2e1a7d4d")
+	}
+	name := strings.TrimSpace(signature[:open])
+	params := strings.TrimSpace(signature[open+1 : len(signature)-1])
+	if strings.ContainsAny(params, "()") {
+		return "", fmt.Errorf("function %s: tuple parameters are not supported", name)
+	}
+
+	var types []string
+	if params != "" {
+		for i, param := range strings.Split(params, ",") {
+			parts := strings.Fields(param)
+			if len(parts) == 0 || len(parts) > 2 {
+				return "", fmt.Errorf("function %s: invalid parameter %d", name, i)
+			}
+			typ, err := abi.NewType(parts[0], "", nil)
+			if err != nil {
+				return "", fmt.Errorf("function %s: %w", name, err)
+			}
+			types = append(types, typ.String())
+		}
+	}
+	id := crypto.Keccak256([]byte(name + "(" + strings.Join(types, ",") + ")"))
+	return fmt.Sprintf("0x%x", id[:4]), nil
+}
+
+// ruleScope 编译后的作用域
+type ruleScope struct {
+	contracts  addressSet
+	restricted bool // 设置了 contracts 或 monitored_contracts；全部无法解析时 contracts 为空，不匹配任何调用
+	chains     map[uint64]struct{}
+	selectors  map[string]struct{}
+}
+
+// compileScope 编译规则的 scope，地址或函数签名非法时返回其在规则 YAML 中的路径
+func compileScope(scope RuleScope) (*ruleScope, []ruleIssue) {
+	s := &ruleScope{
+		restricted: len(scope.Contracts) > 0 || len(scope.MonitoredContracts) > 0,
+		chains:     make(map[uint64]struct{}, len(scope.Chains)),
+		selectors:  make(map[string]struct{}, len(scope.Functions)),
+	}
+	var issues []ruleIssue
+
+	contracts, i, err := newAddressSet(scope.Contracts)
+	if err != nil {
+		issues = append(issues, ruleIssue{keys: []interface{}{"scope", "contracts", i}, err: err})
+		contracts = addressSet{}
+	}
+	// 解析结果来自数据库，已在解析时规范化
+	for _, address := range scope.Resolved {
+		contracts[strings.ToLower(address)] = struct{}{}
+	}
+	s.contracts = contracts
+
+	for _, chain := range scope.Chains {
+		s.chains[chain] = struct{}{}
+	}
+	for i, function := range scope.Functions {
+		selector, err := FunctionSelector(function)
+		if err != nil {
+			issues = append(issues, ruleIssue{keys: []interface{}{"scope", "functions", i}, err: err})
+			continue
+		}
+		s.selectors[selector] = struct{}{}
+	}
+	return s, issues
+}
+
+// scopeCall 交易中的一次调用：被调用的合约与函数选择器
+type scopeCall struct {
+	to       string
+	selector string
+}
+
+// scopeCalls 返回交易本身的调用与调用栈中的调用，地址与选择器均为小写
+func scopeCalls(ctx *EvaluationContext) []scopeCall {
+	calls := make([]scopeCall, 0, 1+len(ctx.CallStack))
+	if ctx.Message != nil {
+		calls = append(calls, scopeCall{
+			to:       strings.ToLower(ctx.Message.ToAddress),
+			selector: strings.ToLower(ctx.Message.FunctionSelector),
+		})
+	} else if ctx.Transaction != nil {
+		calls = append(calls, scopeCall{
+			to:       strings.ToLower(ctx.Transaction.ToAddress),
+			selector: inputSelector(ctx.Transaction.InputData),
+		})
+	}
+	for _, frame := range ctx.CallStack {
+		calls = append(calls, scopeCall{to: strings.ToLower(frame.To), selector: inputSelector(frame.Input)})
+	}
+	return calls
+}
+
+// inputSelector 调用数据的前 4 字节
+func inputSelector(input string) string {
+	if len(input) < 10 {
+		return ""
+	}
+	return strings.ToLower(input[:10])
+}
+
+// Match 交易是否在作用域内
+func (s *ruleScope) Match(ctx *EvaluationContext, calls []scopeCall) bool {
+	if len(s.chains) > 0 && ctx.Message != nil && ctx.Message.ChainID != 0 {
+		if _, ok := s.chains[ctx.Message.ChainID]; !ok {
+			return false
+		}
+	}
+	if !s.restricted && len(s.selectors) == 0 {
+		return true
+	}
+	for _, call := range calls {
+		if s.restricted {
+			if _, ok := s.contracts[call.to]; !ok {
+				continue
+			}
+		}
+		if len(s.selectors) > 0 {
+			if _, ok := s.selectors[call.selector]; !ok {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// scopeIndex 按作用域选择规则：限定合约的规则按合约地址索引，只在交易涉及这些合约时检查
+type scopeIndex struct {
+	scopes     []*ruleScope     // 与规则一一对应，作用域无法编译时为 nil
+	byContract map[string][]int // 合约地址 -> 限定该合约的规则下标
+	open       []int            // 不限定合约的规则下标
+}
+
+func newScopeIndex(rules []*Rule) *scopeIndex {
+	index := &scopeIndex{
+		scopes:     make([]*ruleScope, len(rules)),
+		byContract: make(map[string][]int),
+	}
+	for i, rule := range rules {
+		scope, issues := compileScope(rule.Scope)
+		if len(issues) > 0 || !scope.restricted {
+			// 作用域有误的规则照常交给钩子，由编译错误报告问题
+			index.open = append(index.open, i)
+			if len(issues) == 0 {
+				index.scopes[i] = scope
+			}
+			continue
+		}
+		index.scopes[i] = scope
+		for address := range scope.contracts {
+			index.byContract[address] = append(index.byContract[address], i)
+		}
+	}
+	return index
+}
+
+// Select 返回作用域包含该交易的规则，保持原有顺序
+func (x *scopeIndex) Select(ctx *EvaluationContext, rules []*Rule) []*Rule {
+	calls := scopeCalls(ctx)
+
+	candidates := append([]int{}, x.open...)
+	seen := make(map[string]bool, len(calls))
+	for _, call := range calls {
+		if seen[call.to] {
+			continue
+		}
+		seen[call.to] = true
+		candidates = append(candidates, x.byContract[call.to]...)
+	}
+	sort.Ints(candidates)
+
+	selected := make([]*Rule, 0, len(candidates))
+	for i, candidate := range candidates {
+		if i > 0 && candidates[i-1] == candidate {
+			continue
+		}
+		if scope := x.scopes[candidate]; scope == nil || scope.Match(ctx, calls) {
+			selected = append(selected, rules[candidate])
+		}
+	}
+	return selected
+}
@@ -0,0 +1,114 @@
+package ruleengine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunctionSelector(t *testing.T) {
+	tests := []struct {
+		signature string
+		want      string
+		wantErr   string
+	}{
+		{signature: "withdraw(uint256)", want: "0x2e1a7d4d"},
+		{signature: "transfer(address,uint256)", want: "0xa9059cbb"},
+		{signature: " transfer(address to, uint256 amount) ", want: "0xa9059cbb"}, // 参数名与空白被忽略
+		{signature: "0xA9059CBB", want: "0xa9059cbb"},
+		{signature: "totalSupply()", want: "0x18160ddd"},
+		{signature: "withdraw", wantErr: `function must be a signature such as "withdraw(uint256)" or a selector such as "0x2e1a7d4d"`},
+		{signature: "0xa9059c", wantErr: `function must be a signature such as "withdraw(uint256)" or a selector such as "0x2e1a7d4d"`},
+		{signature: "swap((uint256,address))", wantErr: "function swap: tuple parameters are not supported"},
+		{signature: "f(address a b)", wantErr: "function f: invalid parameter 0"},
+		{signature: "f(uint256,)", wantErr: "function f: invalid parameter 1"},
+		{signature: "transfer(address,uint)", wantErr: "function transfer: unsupported arg type: uint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			got, err := FunctionSelector(tt.signature)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("FunctionSelector(%q) error = %v, want %s", tt.signature, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("FunctionSelector(%q) = %s, %v, want %s", tt.signature, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetSelect(t *testing.T) {
+	scoped := func(name string, scope RuleScope) *Rule {
+		rule := testRule(name)
+		rule.Scope = scope
+		return rule
+	}
+	rules := []*Rule{
+		scoped("open", RuleScope{}),
+		scoped("vault", RuleScope{Contracts: []string{"0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}}),
+		scoped("vault-withdraw", RuleScope{Contracts: []string{addrA}, Functions: []string{"withdraw(uint256)"}}),
+		scoped("any-transfer", RuleScope{Functions: []string{"transfer(address,uint256)"}}),
+		scoped("mainnet", RuleScope{Chains: []uint64{1}}),
+		scoped("monitored", RuleScope{MonitoredContracts: []string{"Vault"}, Resolved: []string{addrB}}),
+		scoped("unresolved", RuleScope{MonitoredContracts: []string{"Missing"}}),
+	}
+	set := NewRuleSet(rules)
+
+	message := func(chainID uint64, to, selector string, frames ...CallFrame) *EvaluationContext {
+		ctx := NewEvaluationContext(nil, nil)
+		ctx.Message = &TransactionData{ChainID: chainID, ToAddress: to, FunctionSelector: selector}
+		ctx.CallStack = frames
+		return ctx
+	}
+	tests := []struct {
+		name string
+		ctx  *EvaluationContext
+		want []string
+	}{
+		{
+			name: "withdraw on vault",
+			ctx:  message(1, addrA, "0x2e1a7d4d"),
+			want: []string{"open", "vault", "vault-withdraw", "mainnet"},
+		},
+		{
+			name: "other function on vault",
+			ctx:  message(1, addrA, "0xa9059cbb"),
+			want: []string{"open", "vault", "any-transfer", "mainnet"},
+		},
+		{
+			name: "vault reached through call stack",
+			ctx:  message(1, addrC, "", CallFrame{To: addrC, Depth: 0}, CallFrame{To: addrA, Input: "0x2e1a7d4d00", Depth: 1}),
+			want: []string{"open", "vault", "vault-withdraw", "mainnet"},
+		},
+		{
+			// 合约与函数须是同一次调用
+			name: "withdraw on another contract",
+			ctx:  message(1, addrC, "0x2e1a7d4d", CallFrame{To: addrC, Input: "0x2e1a7d4d", Depth: 0}, CallFrame{To: addrA, Depth: 1}),
+			want: []string{"open", "vault", "mainnet"},
+		},
+		{
+			name: "other chain",
+			ctx:  message(56, addrB, ""),
+			want: []string{"open", "monitored"},
+		},
+		{
+			// 消息中没有链 ID 时不限制链
+			name: "legacy message without chain",
+			ctx:  message(0, addrD, ""),
+			want: []string{"open", "mainnet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, rule := range set.Select(tt.ctx) {
+				got = append(got, rule.Metadata.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
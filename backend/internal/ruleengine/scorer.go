@@ -2,13 +2,16 @@ package ruleengine
 
 // Scorer 风险评分器
 type Scorer struct {
-	evaluator *Evaluator
+	programs *ProgramCache
 }
 
-// NewScorer 创建新的评分器
-func NewScorer() *Scorer {
+// NewScorer 创建新的评分器，programs 为空时使用独立的编译缓存
+func NewScorer(programs *ProgramCache) *Scorer {
+	if programs == nil {
+		programs = NewProgramCache()
+	}
 	return &Scorer{
-		evaluator: NewEvaluator(),
+		programs: programs,
 	}
 }
 
@@ -18,9 +21,14 @@ func (s *Scorer) CalculateScore(rule *Rule, ctx *EvaluationContext) (int, error)
 	// 从基础分数开始
 	totalScore := rule.Scoring.BaseScore
 
+	compiled, err := s.programs.Get(rule)
+	if err != nil {
+		return 0, err
+	}
+
 	// 评估每个评分因子
-	for _, factor := range rule.Scoring.Factors {
-		matched, err := s.evaluator.Evaluate(factor.Condition, ctx)
+	for i, factor := range rule.Scoring.Factors {
+		matched, err := compiled.Test(compiled.Factors[i], ctx)
 		if err != nil {
 			return 0, err
 		}
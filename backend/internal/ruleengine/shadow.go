@@ -0,0 +1,43 @@
+package ruleengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/haswell/bcscan/internal/models"
+	"github.com/haswell/bcscan/internal/repository"
+)
+
+// ShadowRecorder 记录影子规则与被比较的线上规则的命中（shadow_results）
+type ShadowRecorder struct {
+	repo *repository.ShadowResultRepository
+}
+
+// NewShadowRecorder 创建影子结果记录器
+func NewShadowRecorder(repo *repository.ShadowResultRepository) *ShadowRecorder {
+	return &ShadowRecorder{repo: repo}
+}
+
+// Record 记录规则的一次命中，影子规则记为 shadow，其他规则记为 live
+// silenced 非空表示命中被抑制或在 stop_on_match 之后才评估，未执行动作（见 hooks.RiskEvent.Silenced）
+func (r *ShadowRecorder) Record(ctx context.Context, rule *Rule, evalCtx *EvaluationContext, score int, silenced string) error {
+	mode := RuleModeLive
+	if rule.IsShadow() {
+		mode = RuleModeShadow
+	}
+
+	result := &models.ShadowResult{
+		RuleName:     rule.Metadata.Name,
+		Mode:         mode,
+		RuleRevision: rule.Revision,
+		RuleHash:     rule.Hash,
+		Score:        score,
+		Silenced:     silenced,
+		DetectedAt:   time.Now(),
+	}
+	if evalCtx.Transaction != nil {
+		result.TxHash = evalCtx.Transaction.TxHash
+		result.ContractAddress = evalCtx.Transaction.ToAddress
+	}
+	return r.repo.Create(ctx, result)
+}
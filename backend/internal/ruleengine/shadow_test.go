@@ -0,0 +1,155 @@
+package ruleengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/haswell/bcscan/internal/models"
+	"github.com/haswell/bcscan/internal/repository"
+	"go.uber.org/zap"
+)
+
+// testPostgres 返回 BCSCAN_TEST_POSTGRES 指定的数据库（连接串），未设置时跳过测试
+// 测试前执行影子结果表的迁移，迁移均可重复执行
+func testPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("BCSCAN_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("BCSCAN_TEST_POSTGRES is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, file := range []string{"012_create_shadow_results.sql", "013_add_shadow_result_silenced.sql"} {
+		migration, err := os.ReadFile("../../migrations/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+	return db
+}
+
+func TestShadowConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		shadowOf string
+		want     []string
+	}{
+		{name: "live"},
+		{name: "shadow", mode: RuleModeShadow, shadowOf: "large-transfer"},
+		{name: "shadow without target", mode: RuleModeShadow},
+		{name: "unknown mode", mode: "dry-run", want: []string{`config.mode: unknown mode "dry-run", expected one of live, shadow`}},
+		{name: "live with target", shadowOf: "large-transfer", want: []string{`config.shadow_of: shadow_of requires mode "shadow"`}},
+		{name: "self", mode: RuleModeShadow, shadowOf: "candidate", want: []string{"config.shadow_of: rule cannot shadow itself"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := testRule("candidate")
+			rule.Config.Mode, rule.Config.ShadowOf = tt.mode, tt.shadowOf
+			var got []string
+			for _, d := range rule.Validate() {
+				if d.Path == "config.mode" || d.Path == "config.shadow_of" {
+					got = append(got, d.String())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShadowTargets(t *testing.T) {
+	shadow := func(name, shadowOf string) *Rule {
+		rule := testRule(name)
+		rule.Config.Mode, rule.Config.ShadowOf = RuleModeShadow, shadowOf
+		return rule
+	}
+	rules := map[string]*Rule{
+		"large":      testRule("large"),
+		"large-v2":   shadow("large-v2", "large"),
+		"large-v3":   shadow("large-v3", "large"),
+		"standalone": shadow("standalone", ""),
+		"orphan":     shadow("orphan", "missing"),
+		"nested":     shadow("nested", "large-v2"),
+	}
+	var list []*Rule
+	for _, name := range sortedKeys(rules) {
+		list = append(list, rules[name])
+	}
+	want := map[string]bool{"large": true, "missing": true, "large-v2": true}
+	if got := ShadowTargets(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("ShadowTargets() = %v, want %v", got, want)
+	}
+
+	var got []string
+	for _, d := range referenceDiagnostics(rules) {
+		got = append(got, d.rule+": "+d.String())
+	}
+	wantDiags := []string{
+		`nested: config.shadow_of: warning: rule shadows shadow rule "large-v2", expected a live rule`,
+		`orphan: config.shadow_of: warning: rule shadows unknown rule "missing"`,
+	}
+	if !reflect.DeepEqual(got, wantDiags) {
+		t.Errorf("referenceDiagnostics() = %v, want %v", got, wantDiags)
+	}
+}
+
+// TestShadowCompare 记录影子规则与线上规则的命中后比较：同一交易按最高评分计一次，被抑制的线上命中另计
+func TestShadowCompare(t *testing.T) {
+	db := testPostgres(t)
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	live := testRule(fmt.Sprintf("test-live-%d", suffix))
+	shadow := testRule(fmt.Sprintf("test-shadow-%d", suffix))
+	shadow.Config.Mode, shadow.Config.ShadowOf = RuleModeShadow, live.Metadata.Name
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM shadow_results WHERE rule_name IN ($1, $2)`, live.Metadata.Name, shadow.Metadata.Name)
+	})
+
+	repo := repository.NewShadowResultRepository(db, zap.NewNop())
+	recorder := NewShadowRecorder(repo)
+	from := time.Now().Add(-time.Minute)
+	hits := []struct {
+		rule     *Rule
+		tx       string
+		score    int
+		silenced string
+	}{
+		{shadow, "0x1", 80, ""},
+		{shadow, "0x1", 90, ""},
+		{live, "0x1", 60, ""},
+		{shadow, "0x2", 70, ""},
+		{live, "0x3", 50, "suppressed"},
+	}
+	for _, hit := range hits {
+		if err := recorder.Record(ctx, hit.rule, txContext(hit.tx), hit.score, hit.silenced); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.Compare(ctx, shadow.Metadata.Name, live.Metadata.Name, from, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.ShadowComparison{
+		Shadow: shadow.Metadata.Name, Live: live.Metadata.Name, From: got.From, To: got.To,
+		ShadowHits: 2, LiveHits: 2, Overlap: 1, ShadowOnly: 1, LiveOnly: 1, LiveSilenced: 1,
+		OverlapRate: 1.0 / 3, ShadowAvgScore: 80, LiveAvgScore: 55, AvgScoreDelta: 30,
+		ShadowOnlyTxs: []string{"0x2"}, LiveOnlyTxs: []string{"0x3"},
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Compare() =\n%+v\nwant\n%+v", *got, want)
+	}
+}
@@ -0,0 +1,264 @@
+package ruleengine
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// 基于状态差异的条件类型
+const (
+	ConditionBalanceChange = "balance_change" // 账户余额变化，value 为 wei 数额或百分比（如 "-50%"）
+	ConditionStateChange   = "state_change"   // 存储槽被修改，slot 为槽位、内置槽位名称或 $常量
+	ConditionCodeChange    = "code_change"    // 合约代码被部署、替换或销毁
+)
+
+// AccountDiff 账户在交易前后的状态差异
+type AccountDiff struct {
+	Address       string        `json:"address"`
+	BalanceBefore string        `json:"balance_before"` // 十进制 wei
+	BalanceAfter  string        `json:"balance_after"`
+	NonceBefore   uint64        `json:"nonce_before"`
+	NonceAfter    uint64        `json:"nonce_after"`
+	CodeChanged   bool          `json:"code_changed"`
+	Created       bool          `json:"created"`
+	Destroyed     bool          `json:"destroyed"`
+	Storage       []StorageDiff `json:"storage,omitempty"`
+}
+
+// StorageDiff 存储槽的变化，槽位与值均为 32 字节十六进制
+type StorageDiff struct {
+	Slot   string `json:"slot"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// fields 将账户差异转换为表达式元素
+// balance_change 为有符号的 wei 变化量，balance_change_pct 为相对交易前余额的百分比，
+// 交易前余额为 0 时余额增加记为 100%
+func (d AccountDiff) fields() map[string]interface{} {
+	before, err := parseBigInt(d.BalanceBefore)
+	if err != nil {
+		before = new(big.Int)
+	}
+	after, err := parseBigInt(d.BalanceAfter)
+	if err != nil {
+		after = new(big.Int)
+	}
+	change := new(big.Int).Sub(after, before)
+
+	pct := 0.0
+	switch {
+	case before.Sign() > 0:
+		ratio := new(big.Float).Quo(new(big.Float).SetInt(change), new(big.Float).SetInt(before))
+		pct, _ = ratio.Mul(ratio, big.NewFloat(100)).Float64()
+	case change.Sign() > 0:
+		pct = 100
+	}
+
+	return map[string]interface{}{
+		"address":            d.Address,
+		"balance_before":     before,
+		"balance_after":      after,
+		"balance_change":     change,
+		"balance_change_pct": pct,
+		"nonce_before":       d.NonceBefore,
+		"nonce_after":        d.NonceAfter,
+		"code_changed":       d.CodeChanged,
+		"created":            d.Created,
+		"destroyed":          d.Destroyed,
+		"storage_changes":    len(d.Storage),
+	}
+}
+
+// fields 将存储槽变化转换为表达式元素，name 为内置槽位名称（不是内置槽位时为空）
+func (s StorageDiff) fields(address string) map[string]interface{} {
+	slot := s.Slot
+	if normalized, err := NormalizeSlot(s.Slot); err == nil {
+		slot = normalized
+	}
+	return map[string]interface{}{
+		"address": address,
+		"slot":    slot,
+		"name":    slotNames[slot],
+		"before":  s.Before,
+		"after":   s.After,
+	}
+}
+
+// StateChanges 将状态差异展开为 <地址>:<槽位> -> 修改后的值
+func StateChanges(diffs []AccountDiff) map[string]string {
+	changes := make(map[string]string)
+	for _, diff := range diffs {
+		for _, change := range diff.Storage {
+			changes[strings.ToLower(diff.Address)+":"+strings.ToLower(change.Slot)] = change.After
+		}
+	}
+	return changes
+}
+
+// namedSlots 内置槽位名称：代理合约的标准存储槽
+var namedSlots = map[string]string{
+	"eip1967.implementation": eip1967Slot("eip1967.proxy.implementation"),
+	"eip1967.admin":          eip1967Slot("eip1967.proxy.admin"),
+	"eip1967.beacon":         eip1967Slot("eip1967.proxy.beacon"),
+	"eip1822.proxiable":      common.BytesToHash(crypto.Keccak256([]byte("PROXIABLE"))).Hex(),
+}
+
+// slotNames 槽位到内置名称的反向索引
+var slotNames = func() map[string]string {
+	names := make(map[string]string, len(namedSlots))
+	for name, slot := range namedSlots {
+		names[slot] = name
+	}
+	return names
+}()
+
+// eip1967Slot 按 EIP-1967 计算槽位：keccak256(label) - 1
+func eip1967Slot(label string) string {
+	hash := new(big.Int).SetBytes(crypto.Keccak256([]byte(label)))
+	return common.BigToHash(hash.Sub(hash, big.NewInt(1))).Hex()
+}
+
+// NormalizeSlot 将槽位规范化为 32 字节小写十六进制，支持内置名称、十六进制与十进制
+func NormalizeSlot(slot string) (string, error) {
+	slot = strings.TrimSpace(slot)
+	if named, ok := namedSlots[strings.ToLower(slot)]; ok {
+		return named, nil
+	}
+
+	n := new(big.Int)
+	ok := false
+	if strings.HasPrefix(slot, "0x") || strings.HasPrefix(slot, "0X") {
+		_, ok = n.SetString(slot[2:], 16)
+	} else {
+		_, ok = n.SetString(slot, 10)
+	}
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return "", fmt.Errorf("invalid storage slot %q", slot)
+	}
+	return common.BigToHash(n).Hex(), nil
+}
+
+// parseStateCondition 将状态条件转换为 state_diff / storage_changes 上的量词表达式：
+//
+//	balance_change: any(state_diff, .address == target && .balance_change[_pct] <op> value)
+//	state_change:   any(storage_changes, .address == target && .slot == slot)
+//	code_change:    any(state_diff, .address == target && .code_changed)
+//
+// state_change 与 code_change 省略 target 时匹配任意合约，state_change 省略 slot 时匹配任意槽位
+func (r *Rule) parseStateCondition(cond RuleCondition) (Node, error) {
+	var terms []Node
+	if strings.TrimSpace(cond.Target) != "" {
+		target, err := r.addressNode(cond.Target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition target: %w", err)
+		}
+		terms = append(terms, &BinaryExpr{Op: "==", Left: &FieldExpr{Name: "address", Column: 1}, Right: target, Column: 1})
+	} else if cond.Type == ConditionBalanceChange {
+		return nil, fmt.Errorf("balance_change requires target")
+	}
+
+	collection := "state_diff"
+	switch cond.Type {
+	case ConditionBalanceChange:
+		term, err := balanceChangeTerm(cond)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+
+	case ConditionStateChange:
+		collection = "storage_changes"
+		if cond.Operator != "" {
+			return nil, fmt.Errorf("state_change does not take an operator, use slot to select the storage slot")
+		}
+		if cond.Slot != "" {
+			slot, err := r.slotValue(cond.Slot)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, &BinaryExpr{Op: "==", Left: &FieldExpr{Name: "slot", Column: 1}, Right: &Literal{Value: slot, Column: 1}, Column: 1})
+		}
+
+	case ConditionCodeChange:
+		if cond.Operator != "" {
+			return nil, fmt.Errorf("code_change does not take an operator")
+		}
+		terms = append(terms, &FieldExpr{Name: "code_changed", Column: 1})
+	}
+
+	var body Node = &Literal{Value: true, Column: 1}
+	for i, term := range terms {
+		if i == 0 {
+			body = term
+			continue
+		}
+		body = &BinaryExpr{Op: "AND", Left: body, Right: term, Column: 1}
+	}
+	return &QuantifierExpr{Func: "any", Collection: &Ident{Name: collection, Column: 1}, Body: body, Column: 1}, nil
+}
+
+// balanceChangeTerm 构造余额变化的比较：value 以 % 结尾时比较百分比，否则比较 wei 数额（支持 "-10 ether"）
+func balanceChangeTerm(cond RuleCondition) (Node, error) {
+	if cond.Operator == "" {
+		return nil, fmt.Errorf("balance_change requires operator and value")
+	}
+	operator, err := conditionOperator(cond.Operator)
+	if err != nil {
+		return nil, err
+	}
+	if !isCompareOperator(operator) {
+		return nil, fmt.Errorf("balance_change requires a comparison operator, got %s", cond.Operator)
+	}
+
+	field, value := "balance_change", cond.Value
+	if s, ok := cond.Value.(string); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasSuffix(s, "%") {
+			pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid percentage %q", cond.Value)
+			}
+			field, value = "balance_change_pct", pct
+		} else if strings.HasPrefix(s, "-") {
+			// 负数额按表达式解析，如 "-10 ether"
+			node, err := ParseExpression(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition value: %w", err)
+			}
+			return &BinaryExpr{Op: operator, Left: &FieldExpr{Name: field, Column: 1}, Right: node, Column: 1}, nil
+		}
+	}
+
+	right, err := valueNode(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition value: %w", err)
+	}
+	return &BinaryExpr{Op: operator, Left: &FieldExpr{Name: field, Column: 1}, Right: right, Column: 1}, nil
+}
+
+// addressNode 解析条件中的地址：合法地址作为字面量，否则按表达式解析（如 target_contract、$treasury）
+func (r *Rule) addressNode(target string) (Node, error) {
+	if address, err := NormalizeAddress(target); err == nil {
+		return &Literal{Value: address, Column: 1}, nil
+	}
+	return r.ParseExpression(target)
+}
+
+// slotValue 解析条件中的槽位，$name 引用 constants 中定义的槽位
+func (r *Rule) slotValue(slot string) (string, error) {
+	slot = strings.TrimSpace(slot)
+	if strings.HasPrefix(slot, "$") {
+		value, ok := r.Constants[slot[1:]]
+		if !ok {
+			return "", fmt.Errorf("unknown constant %s", slot)
+		}
+		slot = value
+	}
+	return NormalizeSlot(slot)
+}
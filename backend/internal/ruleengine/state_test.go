@@ -0,0 +1,130 @@
+package ruleengine
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	zeroSlotValue      = "0x0000000000000000000000000000000000000000000000000000000000000000"
+	implementationSlot = "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc"
+	adminSlot          = "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103"
+)
+
+func TestNormalizeSlot(t *testing.T) {
+	tests := []struct {
+		slot    string
+		want    string
+		wantErr bool
+	}{
+		{slot: "eip1967.implementation", want: implementationSlot},
+		{slot: " EIP1967.Admin ", want: adminSlot},
+		{slot: "0", want: zeroSlotValue},
+		{slot: "10", want: "0x000000000000000000000000000000000000000000000000000000000000000a"},
+		{slot: "0X0A", want: "0x000000000000000000000000000000000000000000000000000000000000000a"},
+		{slot: strings.ToUpper(implementationSlot[2:]), wantErr: true}, // 十六进制须带 0x 前缀
+		{slot: "0x" + strings.ToUpper(implementationSlot[2:]), want: implementationSlot},
+		{slot: "0x1" + strings.Repeat("0", 64), wantErr: true}, // 超过 256 位
+		{slot: "-1", wantErr: true},
+		{slot: "0xzz", wantErr: true},
+		{slot: "eip1967.unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slot, func(t *testing.T) {
+			got, err := NormalizeSlot(tt.slot)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NormalizeSlot(%q) = %s, want error", tt.slot, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeSlot(%q) = %s, %v, want %s", tt.slot, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateConditions(t *testing.T) {
+	// addrA 的余额从 10 ether 降到 4 ether，addrB 从 0 增加到 1 ether（交易前不存在），
+	// addrC 的实现槽被改写，addrD 部署了新代码
+	diffs := []AccountDiff{
+		{Address: addrA, BalanceBefore: "10000000000000000000", BalanceAfter: "4000000000000000000"},
+		{Address: addrB, BalanceAfter: "1000000000000000000", Created: true},
+		{Address: addrC, BalanceBefore: "1", BalanceAfter: "1", Storage: []StorageDiff{
+			{Slot: implementationSlot, Before: zeroSlotValue, After: "0x000000000000000000000000" + addrD[2:]},
+			{Slot: "0x0000000000000000000000000000000000000000000000000000000000000001", Before: zeroSlotValue, After: zeroSlotValue[:65] + "1"},
+		}},
+		{Address: addrD, CodeChanged: true, Created: true},
+	}
+	tests := []struct {
+		name    string
+		cond    RuleCondition
+		diffs   []AccountDiff
+		want    bool
+		wantErr string
+	}{
+		{name: "balance dropped by percent", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Operator: "<", Value: "-50%"}, want: true},
+		{name: "balance not dropped enough", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Operator: "<", Value: "-70%"}},
+		{name: "negative amount", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Operator: "<=", Value: "-6 ether"}, want: true},
+		{name: "negative amount not reached", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Operator: "<", Value: "-6 ether"}},
+		{name: "target case insensitive", cond: RuleCondition{Type: ConditionBalanceChange, Target: "0x" + strings.ToUpper(addrA[2:]), Operator: "<", Value: "-50%"}, want: true},
+		// 交易前不存在的账户余额增加记为 100%
+		{name: "missing prestate counts as full increase", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrB, Operator: ">=", Value: "100%"}, want: true},
+		{name: "missing prestate amount", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrB, Operator: "==", Value: "1 ether"}, want: true},
+		{name: "unchanged account", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrC, Operator: "!=", Value: 0}},
+		{name: "account not in diff", cond: RuleCondition{Type: ConditionBalanceChange, Target: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Operator: "<", Value: 0}},
+		{name: "no state diff", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Operator: "<", Value: 0}, diffs: []AccountDiff{}},
+		{name: "named slot write", cond: RuleCondition{Type: ConditionStateChange, Target: addrC, Slot: "eip1967.implementation"}, want: true},
+		{name: "named slot on other contract", cond: RuleCondition{Type: ConditionStateChange, Target: addrA, Slot: "eip1967.implementation"}},
+		{name: "unwritten named slot", cond: RuleCondition{Type: ConditionStateChange, Slot: "eip1967.admin"}},
+		{name: "decimal slot", cond: RuleCondition{Type: ConditionStateChange, Slot: "1"}, want: true},
+		{name: "any slot", cond: RuleCondition{Type: ConditionStateChange, Target: addrC}, want: true},
+		{name: "code change", cond: RuleCondition{Type: ConditionCodeChange, Target: addrD}, want: true},
+		{name: "code change anywhere", cond: RuleCondition{Type: ConditionCodeChange}, want: true},
+		{name: "no code change", cond: RuleCondition{Type: ConditionCodeChange, Target: addrC}},
+		{name: "balance_change without target", cond: RuleCondition{Type: ConditionBalanceChange, Operator: "<", Value: 0},
+			wantErr: "balance_change requires target"},
+		{name: "balance_change without operator", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Value: "-50%"},
+			wantErr: "balance_change requires operator and value"},
+		{name: "invalid percentage", cond: RuleCondition{Type: ConditionBalanceChange, Target: addrA, Operator: "<", Value: "-half%"},
+			wantErr: `invalid percentage "-half%"`},
+		{name: "state_change with operator", cond: RuleCondition{Type: ConditionStateChange, Operator: "==", Slot: "0"},
+			wantErr: "state_change does not take an operator, use slot to select the storage slot"},
+		{name: "invalid slot", cond: RuleCondition{Type: ConditionStateChange, Slot: "eip1967.unknown"},
+			wantErr: `invalid storage slot "eip1967.unknown"`},
+		{name: "unknown slot constant", cond: RuleCondition{Type: ConditionStateChange, Slot: "$owner_slot"},
+			wantErr: "unknown constant $owner_slot"},
+		{name: "code_change with operator", cond: RuleCondition{Type: ConditionCodeChange, Operator: "=="},
+			wantErr: "code_change does not take an operator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := testRule("state")
+			rule.Triggers.Conditions = []RuleCondition{tt.cond}
+			compiled, err := CompileRule(rule)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+					t.Fatalf("CompileRule() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := NewEvaluationContext(nil, nil)
+			ctx.StateDiff = diffs
+			if tt.diffs != nil {
+				ctx.StateDiff = tt.diffs
+			}
+			matched, err := compiled.Match(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tt.want {
+				t.Errorf("Match() = %v, want %v", matched, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ruleengine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkKnownFields 检查 YAML 映射中的键是否都对应结构体字段
+// yaml.Unmarshal 会静默忽略未知的键，拼写错误（如 severty）会让配置悄悄失效，这里逐个报告并给出最接近的字段名
+func checkKnownFields(node *yaml.Node, t reflect.Type, keys []interface{}) []Diagnostic {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var diags []Diagnostic
+	switch t.Kind() {
+	case reflect.Struct:
+		// yaml.Node 字段保存任意内容（如模板的规则内容与参数值）
+		if t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(yaml.Node{}) || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			path := append(append([]interface{}{}, keys...), key.Value)
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown field %q", key.Value)
+				if suggestion := closestName(key.Value, sortedKeys(fields)); suggestion != "" {
+					msg += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				diags = append(diags, Diagnostic{
+					Path: formatPath(path), Message: msg, Level: LevelError,
+					Line: key.Line, Column: key.Column, keys: path,
+				})
+				continue
+			}
+			diags = append(diags, checkKnownFields(value, field.Type, path)...)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			diags = append(diags, checkKnownFields(item, t.Elem(), append(append([]interface{}{}, keys...), i))...)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			path := append(append([]interface{}{}, keys...), node.Content[i].Value)
+			diags = append(diags, checkKnownFields(node.Content[i+1], t.Elem(), path)...)
+		}
+	}
+	return diags
+}
+
+// yamlFields 返回结构体按 yaml 标签索引的字段
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// yamlErrorLine 匹配带行号的 YAML 错误，如 "line 12: cannot unmarshal !!str `abc` into int"、
+// "yaml: line 3: did not find expected key"
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// decodeDiagnostics 将 YAML 解码错误转换为诊断信息，类型错误逐条报告并带上行号
+func decodeDiagnostics(err error) []Diagnostic {
+	var typeErr *yaml.TypeError
+	messages := []string{err.Error()}
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+	diags := make([]Diagnostic, 0, len(messages))
+	for _, msg := range messages {
+		d := Diagnostic{Message: msg, Level: LevelError}
+		if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Column = 1
+			d.Message = m[2]
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
@@ -0,0 +1,465 @@
+package ruleengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplatesDir 规则目录下存放规则模板的子目录
+const TemplatesDir = "templates"
+
+// TemplateFile 规则模板文件：template 声明模板名、参数与实例，rule 为规则内容
+type TemplateFile struct {
+	Template RuleTemplate `yaml:"template"`
+	Rule     yaml.Node    `yaml:"rule"`
+}
+
+// RuleTemplate 规则模板，加载时展开为具体的规则：instantiate 中的每个实例生成一条规则，
+// 规则文件也可以用 extends 引用模板（见 RuleLoader.expand）
+// 规则内容中的 ${参数} 在展开时替换：整个标量为 ${参数} 时替换为参数值（可以是列表），否则按文本插入；
+// 参数同时作为命名常量（列表参数作为命名列表）加入规则，表达式中以 $参数 引用
+type RuleTemplate struct {
+	Name        string                   `yaml:"name"`
+	Description string                   `yaml:"description"`
+	Params      map[string]TemplateParam `yaml:"params"`
+	Instantiate []TemplateInstance       `yaml:"instantiate"`
+}
+
+// TemplateParam 模板参数
+type TemplateParam struct {
+	Type        string    `yaml:"type"`    // 同 variables：address、uint256、number、bool、string、list<T>，默认 any
+	Default     yaml.Node `yaml:"default"` // 没有默认值的参数必须提供
+	Description string    `yaml:"description"`
+}
+
+// TemplateInstance 模板实例，展开为名为 <模板名>-<id> 的规则，id 不变则规则名不变
+type TemplateInstance struct {
+	ID      string               `yaml:"id"`
+	Enabled *bool                `yaml:"enabled"` // 覆盖规则内容中的 metadata.enabled
+	Params  map[string]yaml.Node `yaml:"params"`
+	// Overrides 合并到展开后的规则：映射逐层合并，null 删除对应字段，其他值整体替换（同 MergePatch）
+	Overrides yaml.Node `yaml:"overrides"`
+}
+
+var (
+	// paramRef 规则内容中的参数引用 ${name}
+	paramRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	// instanceIDPattern 实例 ID，作为规则名的一部分
+	instanceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+)
+
+// ruleTemplate 校验通过的模板
+type ruleTemplate struct {
+	RuleTemplate
+	file  string
+	doc   *yaml.Node
+	body  *yaml.Node
+	types map[string]*ExprType // 参数类型
+}
+
+// InstanceName 实例生成的规则名
+func (t *RuleTemplate) InstanceName(id string) string {
+	return t.Name + "-" + id
+}
+
+// templateFiles 返回 templates 目录下的模板文件
+func (rl *RuleLoader) templateFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(rl.rulesDir, TemplatesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob templates: %w", err)
+	}
+	return files, nil
+}
+
+// loadTemplates 加载 templates 目录下的规则模板，有错误的模板不会加载，问题作为诊断信息返回
+func (rl *RuleLoader) loadTemplates() ([]Diagnostic, error) {
+	files, err := rl.templateFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	rl.templates = make(map[string]*ruleTemplate)
+	var diags []Diagnostic
+	for _, file := range files {
+		t, fileDiags, err := parseTemplateFile(file)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, fileDiags...)
+		if t == nil {
+			continue
+		}
+		if existing, ok := rl.templates[t.Name]; ok {
+			diags = append(diags, located(file, t.doc, Diagnostic{
+				Level:   LevelError,
+				Message: fmt.Sprintf("duplicate template name %q, already defined in %s", t.Name, existing.file),
+				keys:    []interface{}{"template", "name"},
+			}))
+			continue
+		}
+		rl.templates[t.Name] = t
+	}
+	return diags, nil
+}
+
+// parseTemplateFile 严格解析并校验模板文件，有错误时 t 为 nil
+// 规则内容在展开后按规则校验，这里只检查参数声明、参数引用与实例 ID
+func parseTemplateFile(file string) (*ruleTemplate, []Diagnostic, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read template file %s: %w", file, err)
+	}
+
+	var doc yaml.Node
+	var templateFile TemplateFile
+	if err := yaml.Unmarshal(data, &doc); err == nil {
+		err = doc.Decode(&templateFile)
+	}
+	if err != nil {
+		diags := decodeDiagnostics(err)
+		locateDiagnostics(file, data, &doc, diags)
+		return nil, diags, nil
+	}
+
+	diags := checkKnownFields(&doc, reflect.TypeOf(templateFile), nil)
+	report := func(err error, keys ...interface{}) {
+		diags = append(diags, Diagnostic{Path: formatPath(keys), Level: LevelError, Message: err.Error(), keys: keys})
+	}
+
+	t := &ruleTemplate{
+		RuleTemplate: templateFile.Template,
+		file:         file,
+		doc:          &doc,
+		body:         &templateFile.Rule,
+		types:        make(map[string]*ExprType, len(templateFile.Template.Params)),
+	}
+	if t.Name == "" {
+		report(fmt.Errorf("template name is required"), "template", "name")
+	}
+
+	for _, name := range sortedKeys(t.Params) {
+		param := t.Params[name]
+		typ, err := RuleVariable{Type: param.Type}.ExprType()
+		if err != nil {
+			report(err, "template", "params", name, "type")
+			continue
+		}
+		t.types[name] = typ
+		if param.Default.Kind != 0 {
+			if err := checkParam(&param.Default, typ); err != nil {
+				report(err, "template", "params", name, "default")
+			}
+		}
+	}
+
+	if t.body.Kind != yaml.MappingNode {
+		report(fmt.Errorf("template rule must be a mapping"), "rule")
+	} else {
+		if lookupNode(t.body, []interface{}{"extends"}) != nil {
+			report(fmt.Errorf("templates cannot extend other templates"), "rule", "extends")
+		}
+		for _, name := range sortedKeys(t.Params) {
+			for _, section := range []string{"constants", "lists"} {
+				if lookupNode(t.body, []interface{}{section, name}) != nil {
+					report(fmt.Errorf("param %q conflicts with the %s entry of the same name", name, section), "rule", section, name)
+				}
+			}
+		}
+		walkScalars(t.body, []interface{}{"rule"}, func(node *yaml.Node, keys []interface{}) {
+			for _, match := range paramRef.FindAllStringSubmatch(node.Value, -1) {
+				if _, ok := t.Params[match[1]]; !ok {
+					report(fmt.Errorf("unknown param ${%s}", match[1]), keys...)
+				}
+			}
+		})
+	}
+
+	ids := make(map[string]bool, len(t.Instantiate))
+	for i, instance := range t.Instantiate {
+		switch {
+		case instance.ID == "":
+			report(fmt.Errorf("instance id is required"), "template", "instantiate", i, "id")
+		case !instanceIDPattern.MatchString(instance.ID):
+			report(fmt.Errorf("invalid instance id %q, expected lowercase letters, digits, '-' or '_'", instance.ID), "template", "instantiate", i, "id")
+		case ids[instance.ID]:
+			report(fmt.Errorf("duplicate instance id %q", instance.ID), "template", "instantiate", i, "id")
+		}
+		ids[instance.ID] = true
+	}
+
+	locateDiagnostics(file, data, &doc, diags)
+	if hasErrors(diags) {
+		return nil, diags, nil
+	}
+	return t, diags, nil
+}
+
+// walkScalars 依次访问映射的值与列表元素中的标量节点，keys 为节点的路径
+func walkScalars(node *yaml.Node, keys []interface{}, fn func(node *yaml.Node, keys []interface{})) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		fn(node, keys)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkScalars(node.Content[i+1], append(append([]interface{}{}, keys...), node.Content[i].Value), fn)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			walkScalars(item, append(append([]interface{}{}, keys...), i), fn)
+		}
+	}
+}
+
+// checkParam 检查参数值是否符合声明的类型；地址须是合法地址，列表只能包含标量
+func checkParam(node *yaml.Node, t *ExprType) error {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			switch item.(type) {
+			case []interface{}, map[string]interface{}:
+				return fmt.Errorf("list params must contain scalar values")
+			}
+		}
+	}
+
+	expr, err := valueNode(value)
+	if err != nil {
+		return err
+	}
+	valueType, err := CheckExpression(expr, VariableSchema{})
+	if err != nil {
+		return err
+	}
+	if !t.comparableWith(valueType) {
+		return fmt.Errorf("value of type %s does not match %s", valueType, t)
+	}
+
+	switch {
+	case t.Kind == TypeAddress:
+		_, err = NormalizeAddress(fmt.Sprint(value))
+	case t.Kind == TypeList && t.Elem.Kind == TypeAddress:
+		items, _ := value.([]interface{})
+		_, _, err = newAddressSet(stringValues(items))
+	}
+	return err
+}
+
+func stringValues(items []interface{}) []string {
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = fmt.Sprint(item)
+	}
+	return values
+}
+
+// render 用参数展开模板，返回规则内容与规则名；name 为空时保留规则内容中的名称
+// 参数与 overrides 的问题以 params.<参数>、overrides 为路径返回
+func (t *ruleTemplate) render(name string, params map[string]yaml.Node, enabled *bool, overrides *yaml.Node) ([]byte, string, []ruleIssue) {
+	var issues []ruleIssue
+	values := make(map[string]*yaml.Node, len(t.Params))
+	for _, param := range sortedKeys(params) {
+		if _, ok := t.Params[param]; !ok {
+			issues = append(issues, ruleIssue{keys: []interface{}{"params", param}, err: fmt.Errorf("unknown param %q", param)})
+		}
+	}
+	for _, param := range sortedKeys(t.Params) {
+		value, ok := params[param]
+		switch {
+		case ok:
+			if err := checkParam(&value, t.types[param]); err != nil {
+				issues = append(issues, ruleIssue{keys: []interface{}{"params", param}, err: err})
+			}
+			values[param] = &value
+		case t.Params[param].Default.Kind != 0:
+			def := t.Params[param].Default
+			values[param] = &def
+		default:
+			issues = append(issues, ruleIssue{keys: []interface{}{"params"}, err: fmt.Errorf("missing required param %q", param)})
+		}
+	}
+	if overrides != nil && overrides.Kind != 0 && overrides.Kind != yaml.MappingNode {
+		issues = append(issues, ruleIssue{keys: []interface{}{"overrides"}, err: fmt.Errorf("overrides must be a mapping")})
+	}
+	if len(issues) > 0 {
+		return nil, "", issues
+	}
+
+	body := copyNode(t.body)
+	substituteParams(body, values)
+
+	// 参数作为命名常量与命名列表，供表达式以 $参数 引用
+	constants := &yaml.Node{Kind: yaml.MappingNode}
+	lists := &yaml.Node{Kind: yaml.MappingNode}
+	for _, param := range sortedKeys(values) {
+		value := values[param]
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: param}
+		if value.Kind == yaml.SequenceNode {
+			list := &yaml.Node{Kind: yaml.SequenceNode}
+			for _, item := range value.Content {
+				list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item.Value})
+			}
+			lists.Content = append(lists.Content, key, list)
+			continue
+		}
+		constants.Content = append(constants.Content, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.Value})
+	}
+	generated := &yaml.Node{Kind: yaml.MappingNode}
+	for _, section := range []struct {
+		name string
+		node *yaml.Node
+	}{{"constants", constants}, {"lists", lists}} {
+		if len(section.node.Content) > 0 {
+			generated.Content = append(generated.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: section.name}, section.node)
+		}
+	}
+	mergeYAML(body, generated)
+
+	if overrides != nil && overrides.Kind == yaml.MappingNode {
+		mergeYAML(body, copyNode(overrides))
+	}
+
+	metadata := map[string]interface{}{"template": t.Name}
+	if name != "" {
+		metadata["name"] = name
+	}
+	if enabled != nil {
+		metadata["enabled"] = *enabled
+	}
+	if err := mergeNode(body, map[string]interface{}{"metadata": metadata}); err != nil {
+		return nil, "", []ruleIssue{{err: err}}
+	}
+	if nameNode := lookupNode(body, []interface{}{"metadata", "name"}); nameNode != nil {
+		name = nameNode.Value
+	}
+
+	content, err := encodeYAML(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{body}})
+	if err != nil {
+		return nil, "", []ruleIssue{{err: err}}
+	}
+	return content, name, nil
+}
+
+// substituteParams 替换标量中的 ${参数}：整个标量为引用时替换为参数值节点，否则按文本插入（列表以逗号分隔）
+func substituteParams(node *yaml.Node, values map[string]*yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if match := paramRef.FindStringSubmatch(node.Value); match != nil && match[0] == node.Value {
+			value := copyNode(values[match[1]])
+			value.HeadComment, value.LineComment, value.FootComment = node.HeadComment, node.LineComment, node.FootComment
+			*node = *value
+			return
+		}
+		node.Value = paramRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			value := values[paramRef.FindStringSubmatch(ref)[1]]
+			if value.Kind != yaml.SequenceNode {
+				return value.Value
+			}
+			items := make([]string, len(value.Content))
+			for i, item := range value.Content {
+				items[i] = item.Value
+			}
+			return strings.Join(items, ", ")
+		})
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			substituteParams(node.Content[i], values)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			substituteParams(item, values)
+		}
+	}
+}
+
+// copyNode 深拷贝 YAML 节点
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
+
+// instances 展开模板中声明的实例，返回各实例的规则内容（来源名为 <模板文件>#<规则名>），
+// 参数有误的实例不会展开，问题定位到模板文件
+func (rl *RuleLoader) instances() ([]RuleSource, []Diagnostic) {
+	var sources []RuleSource
+	var diags []Diagnostic
+	for _, templateName := range sortedKeys(rl.templates) {
+		t := rl.templates[templateName]
+		for i, instance := range t.Instantiate {
+			name := t.InstanceName(instance.ID)
+			content, _, issues := t.render(name, instance.Params, instance.Enabled, &instance.Overrides)
+			for _, issue := range issues {
+				keys := append([]interface{}{"template", "instantiate", i}, issue.keys...)
+				diags = append(diags, located(t.file, t.doc, Diagnostic{Level: LevelError, Message: issue.err.Error(), keys: keys}))
+			}
+			if len(issues) == 0 {
+				sources = append(sources, RuleSource{Name: t.file + "#" + name, Content: content})
+			}
+		}
+	}
+	return sources, diags
+}
+
+// expand 展开使用模板（extends）的规则内容：规则文件中 extends 与 params 以外的字段合并到展开后的规则（同 overrides）
+// 返回展开后的内容及诊断信息中使用的来源名（<文件>#<规则名>）；不使用模板时原样返回。模板与参数的问题定位到原文件
+func (rl *RuleLoader) expand(filename string, data []byte) ([]byte, string, []Diagnostic) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, filename, nil // 由 parseSource 报告
+	}
+	root := doc.Content[0]
+	extends := lookupNode(root, []interface{}{"extends"})
+	if extends == nil {
+		return data, filename, nil
+	}
+
+	var diags []Diagnostic
+	report := func(err error, keys ...interface{}) {
+		diags = append(diags, located(filename, &doc, Diagnostic{Level: LevelError, Message: err.Error(), keys: keys}))
+	}
+	t, ok := rl.templates[extends.Value]
+	if !ok {
+		report(fmt.Errorf("unknown template %q", extends.Value), "extends")
+		return data, filename, diags
+	}
+
+	var params map[string]yaml.Node
+	overrides := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "extends":
+		case "params":
+			if err := value.Decode(&params); err != nil {
+				report(fmt.Errorf("params must be a mapping"), "params")
+				return data, filename, diags
+			}
+		default:
+			overrides.Content = append(overrides.Content, key, value)
+		}
+	}
+
+	content, name, issues := t.render("", params, nil, overrides)
+	for _, issue := range issues {
+		report(issue.err, issue.keys...)
+	}
+	if len(diags) > 0 {
+		return data, filename, diags
+	}
+	if name == "" {
+		return content, filename, nil // 缺少规则名由 parseSource 报告
+	}
+	return content, filename + "#" + name, nil
+}
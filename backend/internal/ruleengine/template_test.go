@@ -0,0 +1,325 @@
+package ruleengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+const watchTemplate = `template:
+  name: "watch"
+  params:
+    contract:
+      type: "address"
+    threshold:
+      type: "uint256"
+      default: "100 ether"
+    targets:
+      type: "list<address>"
+      default: []
+    label:
+      type: "string"
+      default: "vault"
+  instantiate:
+    - id: "a"
+      params:
+        contract: "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+        threshold: "10 ether"
+    - id: "b"
+      enabled: false
+      params:
+        contract: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+        targets: ["0xcccccccccccccccccccccccccccccccccccccccc"]
+      overrides:
+        config:
+          priority: 90
+          throttle: null
+rule:
+  metadata:
+    description: "监控 ${label} 合约 ${contract}"
+    enabled: true
+  config:
+    severity: "high"
+    priority: 50
+    throttle:
+      enabled: true
+      max_alerts: 5
+      time_window: "10m"
+    hooks: ["contract_function_call"]
+  scope:
+    contracts: ["${contract}"]
+  extract:
+    call_stack:
+      - field: "sum.value"
+        as: "moved"
+  triggers:
+    conditions:
+      - expression: moved >= $threshold AND NOT any(call_stack, .to in $targets)
+  actions:
+    - type: "alert"
+      title: "${label} 大额转出"
+      message: "合约 ${contract} 在交易 {{tx_hash}} 中转出 {{moved}} wei"
+`
+
+// writeRulesDir 在临时目录中写入规则文件（路径相对于规则目录），返回规则目录
+func writeRulesDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTemplateExpand(t *testing.T) {
+	dir := writeRulesDir(t, map[string]string{
+		"templates/watch.yaml": watchTemplate,
+		"custom.yaml": `extends: "watch"
+params:
+  contract: "0xdddddddddddddddddddddddddddddddddddddddd"
+  label: "金库"
+metadata:
+  name: "watch-custom"
+`,
+	})
+	loader := NewRuleLoader(dir, zap.NewNop())
+	if err := loader.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		priority    int
+		throttled   bool
+		contract    string
+		threshold   string
+		targets     int
+		description string
+		title       string
+		message     string
+	}{
+		{
+			name: "watch-a", enabled: true, priority: 50, throttled: true,
+			contract: "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", threshold: "10 ether",
+			description: "监控 vault 合约 0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			title:       "vault 大额转出",
+			message:     "合约 0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA 在交易 {{tx_hash}} 中转出 {{moved}} wei",
+		},
+		{
+			// 实例的 enabled 与 overrides 覆盖模板中的规则内容，null 删除字段
+			name: "watch-b", enabled: false, priority: 90, throttled: false,
+			contract: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", threshold: "100 ether", targets: 1,
+			description: "监控 vault 合约 0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			title:       "vault 大额转出",
+			message:     "合约 0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb 在交易 {{tx_hash}} 中转出 {{moved}} wei",
+		},
+		{
+			name: "watch-custom", enabled: true, priority: 50, throttled: true,
+			contract: "0xdddddddddddddddddddddddddddddddddddddddd", threshold: "100 ether",
+			description: "监控 金库 合约 0xdddddddddddddddddddddddddddddddddddddddd",
+			title:       "金库 大额转出",
+			message:     "合约 0xdddddddddddddddddddddddddddddddddddddddd 在交易 {{tx_hash}} 中转出 {{moved}} wei",
+		},
+	}
+	if got := len(loader.GetAllRules()); got != len(tests) {
+		t.Errorf("loaded %d rules, want %d", got, len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := loader.GetRule(tt.name)
+			if !ok {
+				t.Fatalf("rule %s not loaded", tt.name)
+			}
+			if rule.Metadata.Enabled != tt.enabled || rule.Config.Priority != tt.priority || rule.Config.Throttle.Enabled != tt.throttled {
+				t.Errorf("enabled, priority, throttled = %v, %d, %v, want %v, %d, %v",
+					rule.Metadata.Enabled, rule.Config.Priority, rule.Config.Throttle.Enabled, tt.enabled, tt.priority, tt.throttled)
+			}
+			if len(rule.Scope.Contracts) != 1 || rule.Scope.Contracts[0] != tt.contract {
+				t.Errorf("scope contracts = %v, want [%s]", rule.Scope.Contracts, tt.contract)
+			}
+			// 参数作为命名常量与命名列表加入规则
+			if got := rule.Constants["threshold"]; got != tt.threshold {
+				t.Errorf("constant threshold = %q, want %q", got, tt.threshold)
+			}
+			if got := len(rule.Lists["targets"]); got != tt.targets {
+				t.Errorf("list targets has %d entries, want %d", got, tt.targets)
+			}
+			if rule.Metadata.Description != tt.description {
+				t.Errorf("description = %q, want %q", rule.Metadata.Description, tt.description)
+			}
+			// ${参数} 在展开时替换，{{变量}} 保留到告警时渲染
+			if action := rule.Actions[0]; action.Title != tt.title || action.Message != tt.message {
+				t.Errorf("alert = %q / %q, want %q / %q", action.Title, action.Message, tt.title, tt.message)
+			}
+		})
+	}
+}
+
+// TestTemplateAlertMessage 展开后的规则命中时，告警消息中的 {{变量}} 渲染为交易数据与提取结果
+func TestTemplateAlertMessage(t *testing.T) {
+	loader := NewRuleLoader(writeRulesDir(t, map[string]string{"templates/watch.yaml": watchTemplate}), zap.NewNop())
+	if err := loader.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := loader.GetRule("watch-a")
+	if !ok {
+		t.Fatal("rule watch-a not loaded")
+	}
+	compiled, err := NewProgramCache().Get(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value   string
+		matched bool
+	}{
+		{"20000000000000000000", true},
+		{"5000000000000000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ctx := NewEvaluationContext(&models.Transaction{TxHash: "0x1d3f"}, nil)
+			ctx.CallStack = []CallFrame{{Type: "CALL", From: addrA, To: addrB, Value: tt.value, Depth: 1}}
+
+			ruleCtx, err := compiled.Extract(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matched, err := compiled.Match(ruleCtx)
+			if err != nil || matched != tt.matched {
+				t.Fatalf("Match() = %v, %v, want %v", matched, err, tt.matched)
+			}
+
+			want := fmt.Sprintf("合约 0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA 在交易 0x1d3f 中转出 %s wei", tt.value)
+			if got := (&Executor{}).replaceVariables(rule.Actions[0].Message, ruleCtx); got != want {
+				t.Errorf("message = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	const template = `template:
+  name: "watch"
+  params:
+    contract:
+      type: "address"
+    threshold:
+      type: "uint256"
+      default: "100 ether"
+  instantiate:
+%s
+rule:
+  metadata:
+    enabled: true
+  config:
+    severity: "high"
+    hooks: ["contract_function_call"]
+  scope:
+    contracts: ["${contract}"]
+  triggers:
+    conditions:
+      - expression: value >= $threshold%s
+`
+	tests := []struct {
+		name        string
+		instantiate string
+		condition   string // 追加到触发条件的内容
+		rule        string // 使用模板的规则文件 rule.yaml
+		file        string
+		path        string
+		msg         string
+	}{
+		{
+			name:        "missing param",
+			instantiate: "    - id: \"a\"\n      params: {}",
+			file:        "templates/watch.yaml", path: "template.instantiate[0].params",
+			msg: `missing required param "contract"`,
+		},
+		{
+			name:        "invalid address",
+			instantiate: "    - id: \"a\"\n      params: {contract: \"0x123\"}",
+			file:        "templates/watch.yaml", path: "template.instantiate[0].params.contract",
+			msg: `invalid address "0x123"`,
+		},
+		{
+			name:        "type mismatch",
+			instantiate: "    - id: \"a\"\n      params: {contract: \"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\", threshold: \"lots\"}",
+			file:        "templates/watch.yaml", path: "template.instantiate[0].params.threshold",
+			msg: "value of type string does not match uint256",
+		},
+		{
+			name:        "unknown param",
+			instantiate: "    - id: \"a\"\n      params: {contract: \"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\", colour: \"red\"}",
+			file:        "templates/watch.yaml", path: "template.instantiate[0].params.colour",
+			msg: `unknown param "colour"`,
+		},
+		{
+			name:        "invalid instance id",
+			instantiate: "    - id: \"Bad ID\"\n      params: {contract: \"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"}",
+			file:        "templates/watch.yaml", path: "template.instantiate[0].id",
+			msg: `invalid instance id "Bad ID", expected lowercase letters, digits, '-' or '_'`,
+		},
+		{
+			name: "duplicate instance id",
+			instantiate: "    - id: \"a\"\n      params: {contract: \"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"}\n" +
+				"    - id: \"a\"\n      params: {contract: \"0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\"}",
+			file: "templates/watch.yaml", path: "template.instantiate[1].id",
+			msg: `duplicate instance id "a"`,
+		},
+		{
+			name:        "undeclared placeholder",
+			instantiate: "    []",
+			condition:   " AND ${missing} > 1",
+			file:        "templates/watch.yaml", path: "rule.triggers.conditions[0].expression",
+			msg: "unknown param ${missing}",
+		},
+		{
+			name:        "unknown template",
+			instantiate: "    []",
+			rule:        "extends: \"nope\"\nmetadata: {name: x}\n",
+			file:        "rule.yaml", path: "extends",
+			msg: `unknown template "nope"`,
+		},
+		{
+			name:        "extends param type mismatch",
+			instantiate: "    []",
+			rule:        "extends: \"watch\"\nparams: {contract: 1}\nmetadata: {name: x}\n",
+			file:        "rule.yaml", path: "params.contract",
+			msg: "value of type uint256 does not match address",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"templates/watch.yaml": fmt.Sprintf(template, tt.instantiate, tt.condition)}
+			if tt.rule != "" {
+				files["rule.yaml"] = tt.rule
+			}
+			dir := writeRulesDir(t, files)
+			report, err := NewRuleLoader(dir, zap.NewNop()).Lint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Diagnostics) != 1 {
+				t.Fatalf("diagnostics = %v, want 1", report.Diagnostics)
+			}
+			d := report.Diagnostics[0]
+			file, _ := filepath.Rel(dir, d.File)
+			if file != filepath.FromSlash(tt.file) || d.Path != tt.path || d.Message != tt.msg || d.Line == 0 {
+				t.Errorf("diagnostic = %s:%d: %s: %s, want %s: %s: %s", file, d.Line, d.Path, d.Message, tt.file, tt.path, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package ruleengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/haswell/bcscan/internal/cache"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	ThrottleKeyPrefix = "throttle:"
+
+	// SuppressedCountKey 随风险事件上报的被抑制命中次数，动作模板中可以用 {{suppressed_count}} 引用
+	SuppressedCountKey = "suppressed_count"
+
+	// throttleSuppressedTTL 被抑制计数的保留时间，超过后仍没有新的风险事件则丢弃
+	throttleSuppressedTTL = 24 * time.Hour
+)
+
+// 限流分组维度（ThrottleConfig.GroupBy）
+const (
+	ThrottleGroupContract = "contract"
+	ThrottleGroupSender   = "sender"
+)
+
+// throttleScript 滑动窗口限流，窗口与被抑制计数在同一个脚本中原子更新
+// KEYS[1] 窗口内已放行的事件（zset，score 为毫秒时间戳），KEYS[2] 被抑制的命中计数
+// ARGV[1] 窗口长度（毫秒），ARGV[2] 窗口内最多放行的事件数，ARGV[3] 事件成员，ARGV[4] 计数保留时间（毫秒）
+// 使用 Redis 服务器时间，避免各 RDS 实例时钟不一致
+// 返回 {是否放行, 被抑制次数}：放行时为此前累计并清零的次数，抑制时为累计后的次数
+var throttleScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	local suppressed = tonumber(redis.call('GET', KEYS[2]) or '0')
+	redis.call('DEL', KEYS[2])
+	return {1, suppressed}
+end
+local suppressed = redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+return {0, suppressed}
+`)
+
+// ThrottleResult 限流结果
+type ThrottleResult struct {
+	Allowed    bool  // 是否放行
+	Suppressed int64 // 放行时为此前被抑制的命中次数，抑制时为累计次数
+}
+
+// Throttler 分布式告警限流器
+// 按规则（及可选的分组维度）在 Redis 中维护滑动窗口，所有 RDS 实例共享同一个窗口；
+// 被抑制的命中只计数，随下一条放行的风险事件一起上报
+type Throttler struct {
+	redis  *cache.RedisClient
+	logger *zap.Logger
+}
+
+func NewThrottler(redis *cache.RedisClient, logger *zap.Logger) *Throttler {
+	return &Throttler{
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// Allow 判断规则本次命中是否放行，未启用限流的规则总是放行
+// Redis 不可用时放行并返回错误，宁可多报也不漏报
+func (t *Throttler) Allow(ctx context.Context, rule *Rule, evalCtx *EvaluationContext) (ThrottleResult, error) {
+	throttle := rule.Config.Throttle
+	if !throttle.Enabled {
+		return ThrottleResult{Allowed: true}, nil
+	}
+	window, err := throttle.Window()
+	if err != nil {
+		return ThrottleResult{Allowed: true}, err
+	}
+
+	key := ThrottleKey(rule.Metadata.Name, throttle.GroupBy, evalCtx)
+	member := fmt.Sprintf("%d", time.Now().UnixNano())
+	if evalCtx.Transaction != nil {
+		member = evalCtx.Transaction.TxHash + ":" + member
+	}
+
+	result, err := t.redis.RunScript(ctx, throttleScript,
+		[]string{key, key + ":suppressed"},
+		window.Milliseconds(), throttle.MaxAlerts, member, throttleSuppressedTTL.Milliseconds())
+	if err != nil {
+		return ThrottleResult{Allowed: true}, fmt.Errorf("throttle %s: %w", rule.Metadata.Name, err)
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return ThrottleResult{Allowed: true}, fmt.Errorf("throttle %s: unexpected result %v", rule.Metadata.Name, result)
+	}
+	allowed, _ := values[0].(int64)
+	suppressed, _ := values[1].(int64)
+	return ThrottleResult{Allowed: allowed == 1, Suppressed: suppressed}, nil
+}
+
+// ThrottleKey 返回限流窗口的 Redis 键：throttle:{规则名[:分组值...]}
+// 花括号为 Redis Cluster 的 hash tag，保证窗口与计数落在同一个节点上
+func ThrottleKey(rule string, groupBy []string, ctx *EvaluationContext) string {
+	parts := []string{rule}
+	from, to := txParties(ctx)
+	for _, group := range groupBy {
+		switch group {
+		case ThrottleGroupContract:
+			parts = append(parts, strings.ToLower(to))
+		case ThrottleGroupSender:
+			parts = append(parts, strings.ToLower(from))
+		}
+	}
+	return ThrottleKeyPrefix + "{" + strings.Join(parts, ":") + "}"
+}
+
+// Window 解析限流窗口，如 30s、5m、1h
+func (c ThrottleConfig) Window() (time.Duration, error) {
+	window, err := time.ParseDuration(c.TimeWindow)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time_window %q", c.TimeWindow)
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("time_window must be positive")
+	}
+	return window, nil
+}
@@ -0,0 +1,117 @@
+package ruleengine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestThrottleKey(t *testing.T) {
+	ctx := NewEvaluationContext(&models.Transaction{
+		TxHash:      "0x1",
+		FromAddress: "0xAbC0000000000000000000000000000000000001",
+		ToAddress:   "0xDeF0000000000000000000000000000000000002",
+	}, nil)
+	tests := []struct {
+		name    string
+		groupBy []string
+		ctx     *EvaluationContext
+		want    string
+	}{
+		{"rule only", nil, ctx, "throttle:{large-transfer}"},
+		{"contract", []string{ThrottleGroupContract}, ctx,
+			"throttle:{large-transfer:0xdef0000000000000000000000000000000000002}"},
+		{"sender", []string{ThrottleGroupSender}, ctx,
+			"throttle:{large-transfer:0xabc0000000000000000000000000000000000001}"},
+		{"contract and sender", []string{ThrottleGroupContract, ThrottleGroupSender}, ctx,
+			"throttle:{large-transfer:0xdef0000000000000000000000000000000000002:0xabc0000000000000000000000000000000000001}"},
+		{"no transaction", []string{ThrottleGroupContract}, NewEvaluationContext(nil, nil), "throttle:{large-transfer:}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ThrottleKey("large-transfer", tt.groupBy, tt.ctx); got != tt.want {
+				t.Errorf("ThrottleKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleWindow(t *testing.T) {
+	tests := []struct {
+		window  string
+		want    time.Duration
+		wantErr string
+	}{
+		{window: "30s", want: 30 * time.Second},
+		{window: "5m", want: 5 * time.Minute},
+		{window: "1h30m", want: 90 * time.Minute},
+		{window: "", wantErr: `invalid time_window ""`},
+		{window: "5 minutes", wantErr: `invalid time_window "5 minutes"`},
+		{window: "0s", wantErr: "time_window must be positive"},
+		{window: "-1m", wantErr: "time_window must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			got, err := ThrottleConfig{TimeWindow: tt.window}.Window()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Window() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Window() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottlerDisabled(t *testing.T) {
+	throttler := NewThrottler(nil, zap.NewNop())
+	result, err := throttler.Allow(context.Background(), testRule("unthrottled"), txContext("0x1"))
+	if err != nil || !result.Allowed {
+		t.Errorf("Allow() = %+v, %v, want allowed", result, err)
+	}
+}
+
+// TestThrottlerRedis 窗口内超过 max_alerts 的命中被抑制并计数，窗口滑过后放行并带上此前的抑制次数
+func TestThrottlerRedis(t *testing.T) {
+	redis := testRedis(t)
+	ctx := context.Background()
+
+	rule := testRule(fmt.Sprintf("test-throttle-%d", time.Now().UnixNano()))
+	rule.Config.Throttle = ThrottleConfig{Enabled: true, MaxAlerts: 2, TimeWindow: "300ms"}
+	key := ThrottleKey(rule.Metadata.Name, nil, txContext(""))
+	t.Cleanup(func() {
+		redis.Delete(ctx, key)
+		redis.Delete(ctx, key+":suppressed")
+	})
+
+	throttler := NewThrottler(redis, zap.NewNop())
+	steps := []struct {
+		wait time.Duration // 本次命中前等待的时间
+		want ThrottleResult
+	}{
+		{want: ThrottleResult{Allowed: true}},
+		{want: ThrottleResult{Allowed: true}},
+		{want: ThrottleResult{Allowed: false, Suppressed: 1}},
+		{want: ThrottleResult{Allowed: false, Suppressed: 2}},
+		{wait: 400 * time.Millisecond, want: ThrottleResult{Allowed: true, Suppressed: 2}},
+		{want: ThrottleResult{Allowed: true}},
+		{want: ThrottleResult{Allowed: false, Suppressed: 1}},
+	}
+	for i, step := range steps {
+		time.Sleep(step.wait)
+		got, err := throttler.Allow(ctx, rule, txContext(fmt.Sprintf("0x%d", i)))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got != step.want {
+			t.Errorf("step %d: Allow() = %+v, want %+v", i, got, step.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package ruleengine
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 // Rule 规则定义
 type Rule struct {
@@ -11,6 +14,34 @@ type Rule struct {
 	Scoring  RuleScoring  `yaml:"scoring"`
 	Actions  []RuleAction `yaml:"actions"`
 	Filters  RuleFilters  `yaml:"filters"`
+	Scope    RuleScope    `yaml:"scope"` // 作用域，钩子只评估作用域包含交易的规则
+
+	// Lists 命名列表，Constants 命名常量，表达式中以 $name 引用；
+	// 加载时会合并 lists 目录下的共享定义
+	Lists     map[string][]string `yaml:"lists"`
+	Constants map[string]string   `yaml:"constants"`
+
+	// Variables 声明由上游数据提供、可能缺失的变量及其类型和默认值
+	Variables map[string]RuleVariable `yaml:"variables"`
+
+	// Extends 规则基于的模板，Params 为模板参数；加载时展开（见 RuleTemplate），展开后的规则中二者为空
+	Extends string                 `yaml:"extends"`
+	Params  map[string]interface{} `yaml:"params"`
+
+	// Tests 规则测试用例，只由 bcscan rules test 与 ruletest 包执行，加载规则时忽略
+	Tests []RuleTest `yaml:"tests"`
+
+	// Hash 规则文件内容的 SHA-256，Revision 为修订历史中对应的修订号（未记录时为 0），加载时填充
+	Hash     string `yaml:"-"`
+	Revision int    `yaml:"-"`
+
+	content []byte // 规则内容，使用模板时为展开后的内容
+}
+
+// RuleVariable 规则声明的可选变量
+type RuleVariable struct {
+	Type    string      `yaml:"type"`    // address、uint256、number、bool、string、list<T>，默认 any
+	Default interface{} `yaml:"default"` // 变量缺失时使用的默认值，为空表示没有默认值
 }
 
 // RuleMetadata 规则元数据
@@ -21,21 +52,75 @@ type RuleMetadata struct {
 	Description string    `yaml:"description"`
 	Tags        []string  `yaml:"tags"`
 	Enabled     bool      `yaml:"enabled"`
+	Template    string    `yaml:"template"` // 由模板展开的规则记录模板名，展开时填充
 	CreatedAt   time.Time `yaml:"created_at"`
 	UpdatedAt   time.Time `yaml:"updated_at"`
 }
 
+// 规则配置的取值范围，Validate 与 JSON Schema 共用
+var (
+	Severities  = []string{"low", "medium", "high", "critical"}
+	KnownHooks  = []string{HookContractFunctionCall}
+	ActionTypes = []string{"alert", "log_risk_event"}
+	RuleModes   = []string{RuleModeLive, RuleModeShadow}
+)
+
+// HookContractFunctionCall 合约函数调用钩子，目前唯一会评估规则的钩子
+const HookContractFunctionCall = "contract_function_call"
+
+func isSeverity(s string) bool   { return slices.Contains(Severities, s) }
+func isKnownHook(s string) bool  { return slices.Contains(KnownHooks, s) }
+func isActionType(s string) bool { return slices.Contains(ActionTypes, s) }
+func isRuleMode(s string) bool   { return slices.Contains(RuleModes, s) }
+
 // RuleConfig 规则配置
 type RuleConfig struct {
 	Severity string         `yaml:"severity"`
-	Priority int            `yaml:"priority"`
+	Priority int            `yaml:"priority"` // 数值越大越先评估
 	Throttle ThrottleConfig `yaml:"throttle"`
 	Hooks    []string       `yaml:"hooks"`
+
+	// OnMissing 表达式因变量缺失而结果未知时的处理：no_match（默认，视为不匹配）或 error
+	OnMissing string `yaml:"on_missing"`
+
+	// StopOnMatch 命中后不再评估优先级更低的规则
+	StopOnMatch bool `yaml:"stop_on_match"`
+	// Suppresses 命中后抑制同一交易中这些低优先级规则的风险事件
+	Suppresses []string `yaml:"suppresses"`
+
+	// Mode 运行模式：live（默认）或 shadow。影子规则照常评估与评分，但不执行动作与限流，
+	// 命中记录到影子结果中；不会停止其他规则的评估，也不会抑制其他规则
+	Mode string `yaml:"mode"`
+	// ShadowOf 影子规则对应的线上规则，两者的命中可以比较（见 repository.ShadowResultRepository.Compare）
+	ShadowOf string `yaml:"shadow_of"`
+}
+
+// 规则运行模式（RuleConfig.Mode）
+const (
+	RuleModeLive   = "live"
+	RuleModeShadow = "shadow"
+)
+
+// IsShadow 是否为影子规则
+func (r *Rule) IsShadow() bool {
+	return r.Config.Mode == RuleModeShadow
+}
+
+// ShadowTargets 返回规则集中被影子规则比较的线上规则名
+func ShadowTargets(rules []*Rule) map[string]bool {
+	targets := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.IsShadow() && rule.Config.ShadowOf != "" {
+			targets[rule.Config.ShadowOf] = true
+		}
+	}
+	return targets
 }
 
-// ThrottleConfig 限流配置
+// ThrottleConfig 限流配置：滑动窗口 TimeWindow 内最多产生 MaxAlerts 条风险事件
 type ThrottleConfig struct {
-	Enabled    bool   `yaml:"enabled"`
-	MaxAlerts  int    `yaml:"max_alerts"`
-	TimeWindow string `yaml:"time_window"`
+	Enabled    bool     `yaml:"enabled"`
+	MaxAlerts  int      `yaml:"max_alerts"`
+	TimeWindow string   `yaml:"time_window"`
+	GroupBy    []string `yaml:"group_by"` // 分组限流：contract（交易调用的合约）、sender（交易发送方），为空时按规则整体限流
 }
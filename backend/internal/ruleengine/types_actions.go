@@ -26,7 +26,9 @@ type RuleAction struct {
 	Args       []string               `yaml:"args"`
 }
 
-// RuleFilters 过滤器
+// RuleFilters 规则级名单，在触发条件之前检查（见 CompiledRule.Match）
+// whitelist 命中时跳过规则；blacklist 只决定优先级：命中时忽略白名单，规则照常按触发条件评估，
+// 本身不会让规则命中或提高严重程度，需要对地址无条件告警时使用全局拒绝名单（见 AddressLists）
 type RuleFilters struct {
 	Whitelist FilterList `yaml:"whitelist"`
 	Blacklist FilterList `yaml:"blacklist"`
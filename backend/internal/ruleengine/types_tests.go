@@ -0,0 +1,34 @@
+package ruleengine
+
+// RuleTest 规则测试用例，写在规则的 tests 中或同目录的 <规则文件名>_test.yaml 中
+// Transaction 为 RMS 发送的交易消息（TransactionData），可以直接写成 YAML 映射或 JSON 字符串，
+// 也可以用 TransactionFile 引用 JSON 文件（相对于用例所在文件）；
+// History 中的交易在用例之前依次评估，用于为窗口条件（repeated_call、velocity）准备计数
+type RuleTest struct {
+	Name            string        `yaml:"name"`
+	Description     string        `yaml:"description"`
+	Transaction     interface{}   `yaml:"transaction"`
+	TransactionFile string        `yaml:"transaction_file"`
+	History         []interface{} `yaml:"history"`
+	Expect          RuleExpect    `yaml:"expect"`
+}
+
+// RuleExpect 用例的期望结果，未填写的项不检查
+type RuleExpect struct {
+	Match     *bool                  `yaml:"match"`
+	Score     *int                   `yaml:"score"`     // 评分（0-100）
+	Extracted map[string]interface{} `yaml:"extracted"` // 提取的变量（含调用模式绑定），按 %v 格式比较
+	Alert     *RuleExpectAlert       `yaml:"alert"`     // 第一个 alert 动作渲染后的文本
+}
+
+// RuleExpectAlert 告警动作渲染后的标题与正文
+type RuleExpectAlert struct {
+	Title   *string `yaml:"title"`
+	Message *string `yaml:"message"`
+}
+
+// RuleTestFile 独立的规则测试文件（*_test.yaml），Rule 为空时测试同名规则文件中的规则
+type RuleTestFile struct {
+	Rule  string     `yaml:"rule"`
+	Tests []RuleTest `yaml:"tests"`
+}
@@ -7,32 +7,41 @@ type RuleTriggers struct {
 }
 
 // RuleCondition 单个条件
+// 可以用 type/operator/value 描述单个比较，也可以用 expression 书写完整表达式，
+// type 为 call_pattern 时用 pattern 匹配调用树（语法见 CallPattern），
+// repeated_call / velocity 为跨交易的窗口条件，balance_change / state_change / code_change 基于交易的状态差异
 type RuleCondition struct {
 	Type        string      `yaml:"type"`
 	Operator    string      `yaml:"operator"`
 	Value       interface{} `yaml:"value"`
 	Target      string      `yaml:"target"`
-	Within      string      `yaml:"within"`
-	Pattern     string      `yaml:"pattern"`
+	Slot        string      `yaml:"slot"`    // state_change：存储槽位
+	Within      string      `yaml:"within"`  // call_pattern：模式首尾的最大深度差；repeated_call / velocity：窗口跨度，区块数（如 "10 blocks"）或时长（如 "1h"）
+	Pattern     string      `yaml:"pattern"` // 调用模式，如 A->B->A
+	Expression  string      `yaml:"expression"`
 	Description string      `yaml:"description"`
 }
 
-// RuleExtract 数据提取
+// RuleExtract 数据提取，在触发条件之前执行，结果写入 EvaluationContext.ExtractedData
 type RuleExtract struct {
-	Transaction  []ExtractField            `yaml:"transaction"`
-	CallStack    []ExtractField            `yaml:"call_stack"`
-	StateChanges []ExtractField            `yaml:"state_changes"`
-	Events       []ExtractEventField       `yaml:"events"`
-	Custom       map[string][]ExtractField `yaml:"custom"`
+	Transaction  []ExtractField            `yaml:"transaction"`   // 交易字段：hash、from、to、input、selector 或内置变量名
+	CallStack    []ExtractField            `yaml:"call_stack"`    // 调用栈：count、depth 或调用帧字段选择器
+	StateChanges []ExtractField            `yaml:"state_changes"` // 状态变化：count、keys
+	Events       []ExtractEventField       `yaml:"events"`        // 按签名解码的事件参数
+	Custom       map[string][]ExtractField `yaml:"custom"`        // 分组的计算字段，field 为表达式
 }
 
 // ExtractField 提取字段
+// 集合（调用栈、事件）的字段写作 <字段>（第一个元素）、<聚合>.<字段> 或 count，
+// 聚合方式见 extractAggregates
 type ExtractField struct {
 	Field string `yaml:"field"`
 	As    string `yaml:"as"`
 }
 
 // ExtractEventField 提取事件字段
+// Event 为完整签名，如 "Transfer(address indexed from, address indexed to, uint256 value)"，
+// As 为匹配的事件数量
 type ExtractEventField struct {
 	Event  string         `yaml:"event"`
 	Fields []ExtractField `yaml:"fields"`
@@ -0,0 +1,360 @@
+package ruleengine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic 规则校验诊断信息
+// Path 为出错字段在规则中的路径，如 triggers.conditions[1].expression；
+// 从文件加载时 File/Line/Column 定位到具体的出错位置
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Level   string `json:"level"` // error 或 warning
+	Message string `json:"message"`
+
+	keys       []interface{} // 结构化路径，用于在 YAML 文档中定位
+	rule       string        // 所属规则，用于跨规则检查
+	exprColumn int           // 表达式内的列号（从 1 开始），0 表示无
+}
+
+// 诊断级别：error 会阻止规则加载，warning 只提示
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+func (d Diagnostic) String() string {
+	msg := d.Message
+	if d.Level == LevelWarning {
+		msg = "warning: " + msg
+	}
+	switch {
+	case d.File != "" && d.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, msg)
+	case d.File != "" && d.Path != "":
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Path, msg)
+	case d.File != "":
+		return fmt.Sprintf("%s: %s", d.File, msg)
+	case d.Path != "":
+		return fmt.Sprintf("%s: %s", d.Path, msg)
+	default:
+		return msg
+	}
+}
+
+// ValidationError 规则校验失败，包含所有诊断信息
+type ValidationError struct {
+	Rule        string
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics)+1)
+	errs := 0
+	for _, d := range e.Diagnostics {
+		if d.Level != LevelWarning {
+			errs++
+		}
+	}
+	lines = append(lines, fmt.Sprintf("rule %q is invalid (%d error(s))", e.Rule, errs))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, "  "+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate 检查规则配置、extract 与 filters 声明，并编译、类型检查规则中的所有触发条件与评分因子表达式
+// 非法的严重级别、未知的钩子与动作类型、变量名拼写错误、未知的提取字段、非法的名单地址、
+// 运算符与类型不匹配、正则非法等问题在这里一次性报告
+func (r *Rule) Validate() []Diagnostic {
+	var diags []Diagnostic
+	schema := r.Schema()
+
+	report := func(err error, keys ...interface{}) {
+		d := Diagnostic{Path: formatPath(keys), Level: LevelError, Message: err.Error(), keys: keys}
+		var exprErr *ExprError
+		if errors.As(err, &exprErr) {
+			d.Message = exprErr.Msg
+			d.exprColumn = exprErr.Column
+		}
+		diags = append(diags, d)
+	}
+
+	if r.Metadata.Name == "" {
+		report(fmt.Errorf("rule name is required"), "metadata", "name")
+	}
+
+	if r.Config.Severity != "" && !isSeverity(r.Config.Severity) {
+		report(fmt.Errorf("unknown severity %q, expected one of %s", r.Config.Severity, strings.Join(Severities, ", ")), "config", "severity")
+	}
+	if r.Config.Mode != "" && !isRuleMode(r.Config.Mode) {
+		report(fmt.Errorf("unknown mode %q, expected one of %s", r.Config.Mode, strings.Join(RuleModes, ", ")), "config", "mode")
+	}
+	switch {
+	case r.Config.ShadowOf != "" && !r.IsShadow():
+		report(fmt.Errorf("shadow_of requires mode %q", RuleModeShadow), "config", "shadow_of")
+	case r.Config.ShadowOf != "" && r.Config.ShadowOf == r.Metadata.Name:
+		report(fmt.Errorf("rule cannot shadow itself"), "config", "shadow_of")
+	}
+	for i, hook := range r.Config.Hooks {
+		if !isKnownHook(hook) {
+			report(fmt.Errorf("unknown hook %q, expected one of %s", hook, strings.Join(KnownHooks, ", ")), "config", "hooks", i)
+		}
+	}
+	for i, action := range r.Actions {
+		if !isActionType(action.Type) {
+			report(fmt.Errorf("unknown action type %q, expected one of %s", action.Type, strings.Join(ActionTypes, ", ")), "actions", i, "type")
+		}
+		if action.Severity != "" && !isSeverity(action.Severity) {
+			report(fmt.Errorf("unknown severity %q, expected one of %s", action.Severity, strings.Join(Severities, ", ")), "actions", i, "severity")
+		}
+	}
+
+	switch r.Config.OnMissing {
+	case "", OnMissingNoMatch, OnMissingError:
+	default:
+		report(fmt.Errorf("on_missing must be %q or %q", OnMissingNoMatch, OnMissingError), "config", "on_missing")
+	}
+
+	if throttle := r.Config.Throttle; throttle.Enabled {
+		if throttle.MaxAlerts <= 0 {
+			report(fmt.Errorf("max_alerts must be positive"), "config", "throttle", "max_alerts")
+		}
+		if _, err := throttle.Window(); err != nil {
+			report(err, "config", "throttle", "time_window")
+		}
+		for i, group := range throttle.GroupBy {
+			if group != ThrottleGroupContract && group != ThrottleGroupSender {
+				report(fmt.Errorf("group_by must be %q or %q", ThrottleGroupContract, ThrottleGroupSender), "config", "throttle", "group_by", i)
+			}
+		}
+	}
+
+	for i, name := range r.Config.Suppresses {
+		switch name {
+		case "":
+			report(fmt.Errorf("rule name is required"), "config", "suppresses", i)
+		case r.Metadata.Name:
+			report(fmt.Errorf("rule cannot suppress itself"), "config", "suppresses", i)
+		}
+	}
+
+	for _, name := range sortedKeys(r.Variables) {
+		variable := r.Variables[name]
+		t, err := variable.ExprType()
+		if err != nil {
+			report(err, "variables", name, "type")
+			continue
+		}
+		if variable.Default == nil {
+			continue
+		}
+		def, err := valueNode(variable.Default)
+		if err == nil {
+			var defType *ExprType
+			if defType, err = CheckExpression(def, schema); err == nil && !t.comparableWith(defType) {
+				err = fmt.Errorf("default value of type %s does not match %s", defType, t)
+			}
+		}
+		if err != nil {
+			report(err, "variables", name, "default")
+		}
+	}
+
+	declared := r.variableSchema()
+	extractor, issues := r.compileExtract(declared.Clone())
+	_, filterIssues := compileFilters(r.Filters)
+	_, scopeIssues := compileScope(r.Scope)
+	for _, issue := range append(append(issues, filterIssues...), scopeIssues...) {
+		report(issue.err, issue.keys...)
+	}
+
+	for i, cond := range r.Triggers.Conditions {
+		field := "expression"
+		switch {
+		case cond.Expression != "":
+		case cond.Pattern != "":
+			field = "pattern"
+		default:
+			field = "type"
+		}
+
+		node, err := r.ParseCondition(cond)
+		if err == nil {
+			err = CheckCondition(node, schema)
+		}
+		if err != nil {
+			var exprErr *ExprError
+			switch cond.Type {
+			case ConditionVelocity, ConditionBalanceChange, ConditionStateChange, ConditionCodeChange:
+				if errors.As(err, &exprErr) {
+					field = "target" // target 中的表达式有误
+				}
+			}
+			report(err, "triggers", "conditions", i, field)
+			continue
+		}
+		// 窗口在规则评估之前计数（见 ProgramCache.ObserveWindows），velocity 的 target 不能引用提取的变量
+		if window, ok := windowOf(node); ok && window.filter != nil {
+			if err := CheckCondition(window.filter, declared); err != nil {
+				var exprErr *ExprError
+				if errors.As(err, &exprErr) {
+					err = &ExprError{Expr: exprErr.Expr, Column: exprErr.Column, Msg: exprErr.Msg + " (velocity target cannot use extracted variables)"}
+				}
+				report(err, "triggers", "conditions", i, "target")
+			}
+		}
+		if pattern, ok := node.(*PatternExpr); ok {
+			for _, name := range pattern.Pattern.Variables() {
+				_, builtin := declared[name]
+				_, extracted := extractor.types[name]
+				if builtin || extracted {
+					report(fmt.Errorf("pattern variable %q conflicts with an existing variable", name), "triggers", "conditions", i, field)
+				}
+			}
+		}
+	}
+
+	for i, factor := range r.Scoring.Factors {
+		node, err := r.ParseExpression(factor.Condition)
+		if err == nil {
+			err = CheckCondition(node, schema)
+		}
+		if err != nil {
+			report(err, "scoring", "factors", i, "condition")
+		}
+	}
+
+	return diags
+}
+
+// sortedKeys 按字母序返回 map 的键，保证诊断顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatPath 将结构化路径格式化为 triggers.conditions[1].expression 形式
+func formatPath(keys []interface{}) string {
+	var sb strings.Builder
+	for _, key := range keys {
+		switch k := key.(type) {
+		case int:
+			fmt.Fprintf(&sb, "[%d]", k)
+		default:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			fmt.Fprintf(&sb, "%v", k)
+		}
+	}
+	return sb.String()
+}
+
+// locateDiagnostics 根据 YAML 文档中的节点位置，为诊断信息补充文件行列号
+// 表达式内的列号会换算为文件中的绝对位置
+func locateDiagnostics(file string, src []byte, doc *yaml.Node, diags []Diagnostic) {
+	lines := strings.Split(string(src), "\n")
+	for i := range diags {
+		d := &diags[i]
+		d.File = file
+		if d.Line > 0 {
+			continue // 已经定位（如未知字段、解码错误）
+		}
+
+		node := lookupNode(doc, d.keys)
+		if node == nil {
+			// 缺少的键（如必填字段）定位到最近的上级节点
+			for n := len(d.keys) - 1; n > 0 && node == nil; n-- {
+				node = lookupNode(doc, d.keys[:n])
+			}
+			if node != nil {
+				d.Line, d.Column = node.Line, node.Column
+			}
+			continue
+		}
+		d.Line, d.Column = node.Line, node.Column
+
+		if d.exprColumn == 0 || node.Kind != yaml.ScalarNode {
+			continue
+		}
+		switch node.Style {
+		case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+			// 跳过开头的引号
+			d.Column += d.exprColumn
+		case yaml.LiteralStyle, yaml.FoldedStyle:
+			// 块标量的内容从下一行开始，逐行扣除长度定位到具体行
+			d.Line, d.Column = locateInBlock(lines, node.Line, d.exprColumn)
+		default:
+			d.Column += d.exprColumn - 1
+		}
+	}
+}
+
+// locateInBlock 计算块标量（| 或 >）中第 column 个字符所在的行列号
+// header 为块标量指示符所在行，缩进以第一行内容为准；折叠块的换行被替换为空格，长度不变
+func locateInBlock(lines []string, header, column int) (int, int) {
+	if header >= len(lines) {
+		return header, 1
+	}
+	first := lines[header]
+	indent := len(first) - len(strings.TrimLeft(first, " "))
+
+	line := header + 1
+	for ; line < len(lines); line++ {
+		text := lines[line-1]
+		n := 0
+		if len(text) > indent {
+			n = len([]rune(text[indent:]))
+		}
+		if column <= n+1 {
+			break
+		}
+		column -= n + 1
+	}
+	return line, indent + column
+}
+
+// lookupNode 按路径在 YAML 节点树中查找节点
+func lookupNode(node *yaml.Node, keys []interface{}) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		if node == nil {
+			return nil
+		}
+		switch k := key.(type) {
+		case int:
+			if node.Kind != yaml.SequenceNode || k >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[k]
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return nil
+			}
+			var next *yaml.Node
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == k {
+					next = node.Content[j+1]
+					break
+				}
+			}
+			node = next
+		}
+	}
+	return node
+}
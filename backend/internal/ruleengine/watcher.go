@@ -0,0 +1,105 @@
+package ruleengine
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// watchDebounce 规则文件变化后等待的时间，编辑器保存、批量复制产生的多次变化合并为一次重新加载
+const watchDebounce = 500 * time.Millisecond
+
+// WatchFiles 监视规则目录（含子目录与 lists、templates 目录），规则文件变化后重新导入数据库、加载规则并通知其他实例
+// 导入规则同 SeedRules：通过 API 修改过的规则不会被文件覆盖。ctx 结束时停止监视
+func (rm *RuleManager) WatchFiles(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watchDirs(watcher, rm.loader.rulesDir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	rm.logger.Info("Watching rule files", zap.String("dir", rm.loader.rulesDir))
+	go rm.watch(ctx, watcher)
+	return nil
+}
+
+func (rm *RuleManager) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// 新建的子目录也需要监视
+			if event.Has(fsnotify.Create) {
+				if err := watchDirs(watcher, event.Name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					rm.logger.Warn("Failed to watch rule directory", zap.String("dir", event.Name), zap.Error(err))
+				}
+			}
+			if !strings.HasSuffix(event.Name, ".yaml") || strings.HasSuffix(event.Name, TestFileSuffix) ||
+				event.Op == fsnotify.Chmod {
+				continue
+			}
+			timer.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			rm.logger.Warn("Rule file watcher error", zap.Error(err))
+		case <-timer.C:
+			rm.reloadFiles(ctx)
+		}
+	}
+}
+
+// reloadFiles 重新导入规则文件并加载，规则版本变化时通知其他实例
+func (rm *RuleManager) reloadFiles(ctx context.Context) {
+	rm.logger.Info("Rule files changed, reloading")
+	previous := rm.Version()
+	if err := rm.SeedRules(ctx); err != nil {
+		rm.logger.Error("Failed to seed rules", zap.Error(err))
+		return
+	}
+	if err := rm.LoadFromStore(ctx); err != nil {
+		rm.logger.Error("Failed to reload rules", zap.Error(err))
+		// 规则文件已导入数据库，通知其他实例从数据库加载
+		if err := rm.PublishReload(ctx); err != nil {
+			rm.logger.Error("Failed to publish rule update", zap.Error(err))
+		}
+		return
+	}
+	if rm.Version() == previous {
+		return
+	}
+	if err := rm.PublishUpdate(ctx); err != nil {
+		rm.logger.Error("Failed to publish rule update", zap.Error(err))
+	}
+}
+
+// watchDirs 监视目录及其子目录，path 不是目录时忽略
+func watchDirs(watcher *fsnotify.Watcher, path string) error {
+	return filepath.WalkDir(path, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(dir)
+		}
+		return nil
+	})
+}
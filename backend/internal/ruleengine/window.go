@@ -0,0 +1,327 @@
+package ruleengine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/haswell/bcscan/internal/cache"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// 跨交易的窗口条件类型
+const (
+	ConditionRepeatedCall = "repeated_call" // 同一发送方对同一合约（的同一函数）的调用次数
+	ConditionVelocity     = "velocity"      // 同一发送方满足 target 表达式的交易次数
+)
+
+const (
+	WindowKeyPrefix = "window:"
+
+	// windowStoreTimeout 单次窗口更新的超时时间
+	windowStoreTimeout = time.Second
+
+	// windowBlockInterval 按区块计的窗口用于估算键保留时间的出块间隔
+	windowBlockInterval = 15 * time.Second
+
+	// windowSweepInterval 内存窗口每写入多少次清理一次过期的键
+	windowSweepInterval = 1024
+
+	// windowFallbackLogInterval Redis 持续不可用时重复记录警告的最短间隔
+	windowFallbackLogInterval = time.Minute
+)
+
+// windowScript 记录一次命中并返回窗口内的命中次数
+// KEYS[1] 窗口（zset，成员为交易哈希，score 为区块号或毫秒时间戳）
+// ARGV[1] 本次命中的 score，ARGV[2] 窗口下界（不含），ARGV[3] 交易哈希，ARGV[4] 键保留时间（毫秒）
+// 以交易哈希为成员，消息重复投递或多次求值不会重复计数
+var windowScript = redis.NewScript(`
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return redis.call('ZCOUNT', KEYS[1], '(' .. ARGV[2], '+inf')
+`)
+
+// WindowStore 跨交易的滑动窗口计数
+// 计数保存在 Redis 中，所有 RDS 实例共享且重启后保留；
+// 未配置 Redis 或 Redis 不可用时退回进程内存，此时计数只在当前实例内有效
+type WindowStore struct {
+	redis  *cache.RedisClient
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	memory map[string]*memoryWindow
+	writes int
+
+	// fallback 是否正在使用内存窗口，切换与恢复时各记录一次日志，期间按 windowFallbackLogInterval 限频
+	fallback   atomic.Bool
+	loggedAt   time.Time
+	suppressed int // 上次记录警告后未记录的失败次数
+}
+
+// memoryWindow 内存中的窗口：交易哈希 -> score
+type memoryWindow struct {
+	members map[string]int64
+	expires time.Time
+}
+
+func NewWindowStore(redis *cache.RedisClient, logger *zap.Logger) *WindowStore {
+	return &WindowStore{
+		redis:  redis,
+		logger: logger,
+		memory: make(map[string]*memoryWindow),
+	}
+}
+
+// Add 将 member 以 score 记入窗口，返回 score 大于 min 的成员数
+func (s *WindowStore) Add(ctx context.Context, key, member string, score, min int64, ttl time.Duration) int64 {
+	if s.redis != nil {
+		result, err := s.redis.RunScript(ctx, windowScript, []string{key},
+			score, min, member, ttl.Milliseconds())
+		if err == nil {
+			if count, ok := result.(int64); ok {
+				s.recovered()
+				return count
+			}
+			err = fmt.Errorf("unexpected result %v", result)
+		}
+		s.failed(err)
+	}
+	return s.addMemory(key, member, score, min, ttl)
+}
+
+// failed 记录 Redis 不可用：切换到内存窗口时记录警告，之后按 windowFallbackLogInterval 限频并附带期间的失败次数
+func (s *WindowStore) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	switch {
+	case !s.fallback.Load():
+		s.logger.Warn("Window store unavailable, using in-memory windows", zap.Error(err))
+	case now.Sub(s.loggedAt) >= windowFallbackLogInterval:
+		s.logger.Warn("Window store still unavailable", zap.Int("failures", s.suppressed+1), zap.Error(err))
+	default:
+		s.suppressed++
+		return
+	}
+	s.fallback.Store(true)
+	s.loggedAt, s.suppressed = now, 0
+}
+
+// recovered Redis 恢复后切回共享窗口，内存中的计数不会合并
+func (s *WindowStore) recovered() {
+	if s.fallback.CompareAndSwap(true, false) {
+		s.logger.Info("Window store recovered, using Redis windows")
+	}
+}
+
+func (s *WindowStore) addMemory(key, member string, score, min int64, ttl time.Duration) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	w, ok := s.memory[key]
+	if !ok || now.After(w.expires) {
+		w = &memoryWindow{members: make(map[string]int64)}
+		s.memory[key] = w
+	}
+	w.members[member] = score
+	w.expires = now.Add(ttl)
+	for m, sc := range w.members {
+		if sc <= min {
+			delete(w.members, m)
+		}
+	}
+
+	s.writes++
+	if s.writes%windowSweepInterval == 0 {
+		for k, other := range s.memory {
+			if now.After(other.expires) {
+				delete(s.memory, k)
+			}
+		}
+	}
+	return int64(len(w.members))
+}
+
+// WindowCondition 跨交易的窗口条件（type: repeated_call / velocity）
+// 每笔满足条件的交易以交易哈希记入按规则、条件与发送方（repeated_call 还有合约）分组的窗口，
+// 条件在本交易满足且窗口内的次数满足 operator/value 时成立
+// 计数在规则评估之前对每笔交易统一进行（见 ProgramCache.ObserveWindows），不受名单、作用域与其他规则结果的影响
+type WindowCondition struct {
+	Type     string
+	Target   string
+	Within   string
+	key      string // 规则名、条件类型与条件摘要，条件修改后窗口重新计数
+	span     int64
+	blocks   bool // true 时 span 为区块数，否则为毫秒
+	ttl      time.Duration
+	selector string // repeated_call：限定的函数选择器
+	filter   Node   // velocity：计数的交易需满足的表达式，由编译结果持有其编译后的 evalFunc（见 compileFilter）
+}
+
+// windowCount 窗口条件在某个交易上的结果
+type windowCount struct {
+	applicable bool
+	count      int64
+}
+
+// parseWindowCondition 解析窗口条件，within 为区块数（如 "10 blocks"）或时长（如 "1h"）
+func (r *Rule) parseWindowCondition(cond RuleCondition) (*WindowCondition, error) {
+	w := &WindowCondition{Type: cond.Type, Target: cond.Target, Within: cond.Within}
+
+	within := strings.TrimSpace(cond.Within)
+	if within == "" {
+		return nil, fmt.Errorf("%s requires within, e.g. \"10 blocks\" or \"1h\"", cond.Type)
+	}
+	if fields := strings.Fields(within); len(fields) == 2 && (fields[1] == "block" || fields[1] == "blocks") {
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid within %q", cond.Within)
+		}
+		w.span, w.blocks = n, true
+		w.ttl = time.Duration(n)*windowBlockInterval + time.Hour
+	} else {
+		d, err := time.ParseDuration(within)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid within %q, expected a block count or a duration", cond.Within)
+		}
+		w.span, w.ttl = d.Milliseconds(), d
+	}
+
+	target := strings.TrimSpace(cond.Target)
+	switch cond.Type {
+	case ConditionRepeatedCall:
+		switch {
+		case target == "":
+		case patternSelectorPattern.MatchString(target):
+			w.selector = strings.ToLower(target)
+		case strings.Contains(target, "("):
+			w.selector = functionSelector(target)
+		default:
+			return nil, fmt.Errorf("repeated_call target must be a function selector or signature, got %q", cond.Target)
+		}
+	case ConditionVelocity:
+		if target != "" {
+			node, err := r.ParseExpression(target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid velocity target: %w", err)
+			}
+			w.filter = node
+		}
+	}
+
+	digest := sha256.Sum256([]byte(cond.Target + "\x00" + within))
+	w.key = WindowKeyPrefix + r.Metadata.Name + ":" + cond.Type + ":" + hex.EncodeToString(digest[:4])
+	return w, nil
+}
+
+// windowOf 返回窗口条件语法树（见 parseWindowExpr）对应的窗口条件
+func windowOf(node Node) (*WindowCondition, bool) {
+	if and, ok := node.(*BinaryExpr); ok && and.Op == "AND" {
+		if window, ok := and.Left.(*WindowExpr); ok {
+			return window.Window, true
+		}
+	}
+	return nil, false
+}
+
+func (w *WindowCondition) String() string {
+	if w.Target == "" {
+		return fmt.Sprintf("%s(within %q)", w.Type, w.Within)
+	}
+	return fmt.Sprintf("%s(%q, within %q)", w.Type, w.Target, w.Within)
+}
+
+// compileFilter 编译 velocity 的 target 表达式，没有 target 时返回 nil
+// 窗口条件属于共享的语法树，编译结果由调用方持有，不写回条件
+func (w *WindowCondition) compileFilter(e *Evaluator) (evalFunc, error) {
+	if w.filter == nil {
+		return nil, nil
+	}
+	return e.compile(w.filter)
+}
+
+// Record 将本交易记入窗口并返回窗口内的次数，结果缓存在上下文中；match 为 compileFilter 的结果
+// 本交易不满足条件（如调用的不是 target 函数）时不计数，applicable 为 false
+// 由 ProgramCache.ObserveWindows 在规则评估之前调用，规则评估只通过 Count 读取结果
+func (w *WindowCondition) Record(ctx *EvaluationContext, match evalFunc) (windowCount, error) {
+	if result, ok := ctx.windows[w]; ok {
+		return result, nil
+	}
+	result, err := w.record(ctx, match)
+	if err != nil {
+		return result, err
+	}
+	if ctx.windows == nil {
+		ctx.windows = make(map[*WindowCondition]windowCount)
+	}
+	ctx.windows[w] = result
+	return result, nil
+}
+
+// Count 返回本交易上已记录的窗口次数，本交易未经 ObserveWindows 计数时 ok 为 false
+func (w *WindowCondition) Count(ctx *EvaluationContext) (windowCount, bool) {
+	result, ok := ctx.windows[w]
+	return result, ok
+}
+
+func (w *WindowCondition) record(ctx *EvaluationContext, match evalFunc) (windowCount, error) {
+	tx := ctx.Transaction
+	if tx == nil || tx.FromAddress == "" {
+		return windowCount{}, nil
+	}
+
+	key := w.key + ":" + strings.ToLower(tx.FromAddress)
+	switch w.Type {
+	case ConditionRepeatedCall:
+		if tx.ToAddress == "" {
+			return windowCount{}, nil
+		}
+		if w.selector != "" && (len(tx.InputData) < 10 || !strings.EqualFold(tx.InputData[:10], w.selector)) {
+			return windowCount{}, nil
+		}
+		key += ":" + strings.ToLower(tx.ToAddress)
+	case ConditionVelocity:
+		if match != nil {
+			value, err := match(ctx, nil)
+			if err != nil {
+				return windowCount{}, err
+			}
+			if matched, ok := value.(bool); !ok || !matched {
+				return windowCount{}, nil
+			}
+		}
+	}
+
+	score := windowScore(ctx, w.blocks)
+	timeout, cancel := context.WithTimeout(context.Background(), windowStoreTimeout)
+	defer cancel()
+	count := ctx.Windows.Add(timeout, key, strings.ToLower(tx.TxHash), score, score-w.span, w.ttl)
+	return windowCount{applicable: true, count: count}, nil
+}
+
+// windowScore 返回交易在窗口中的位置：区块号，或区块时间（毫秒），缺少区块时间时使用当前时间
+func windowScore(ctx *EvaluationContext, blocks bool) int64 {
+	if blocks {
+		if ctx.Block != nil && ctx.Block.BlockNumber > 0 {
+			return ctx.Block.BlockNumber
+		}
+		return ctx.Transaction.BlockNumber
+	}
+	switch {
+	case ctx.Block != nil && !ctx.Block.Timestamp.IsZero():
+		return ctx.Block.Timestamp.UnixMilli()
+	case !ctx.Transaction.Timestamp.IsZero():
+		return ctx.Transaction.Timestamp.UnixMilli()
+	}
+	return time.Now().UnixMilli()
+}
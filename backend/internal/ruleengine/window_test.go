@@ -0,0 +1,211 @@
+package ruleengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haswell/bcscan/internal/cache"
+	"github.com/haswell/bcscan/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestParseWindowCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		cond     RuleCondition
+		span     int64
+		blocks   bool
+		ttl      time.Duration
+		selector string
+		wantErr  string
+	}{
+		{name: "blocks", cond: RuleCondition{Type: ConditionRepeatedCall, Within: "10 blocks"},
+			span: 10, blocks: true, ttl: 10*windowBlockInterval + time.Hour},
+		{name: "single block", cond: RuleCondition{Type: ConditionRepeatedCall, Within: " 1 block "},
+			span: 1, blocks: true, ttl: windowBlockInterval + time.Hour},
+		{name: "duration", cond: RuleCondition{Type: ConditionVelocity, Within: "1h"},
+			span: time.Hour.Milliseconds(), ttl: time.Hour},
+		{name: "compound duration", cond: RuleCondition{Type: ConditionVelocity, Within: "1m30s"},
+			span: 90000, ttl: 90 * time.Second},
+		{name: "signature target", cond: RuleCondition{Type: ConditionRepeatedCall, Target: "withdraw(uint256)", Within: "5 blocks"},
+			span: 5, blocks: true, ttl: 5*windowBlockInterval + time.Hour, selector: "0x2e1a7d4d"},
+		{name: "selector target", cond: RuleCondition{Type: ConditionRepeatedCall, Target: "0x2E1A7D4D", Within: "5 blocks"},
+			span: 5, blocks: true, ttl: 5*windowBlockInterval + time.Hour, selector: "0x2e1a7d4d"},
+		{name: "missing within", cond: RuleCondition{Type: ConditionVelocity},
+			wantErr: `velocity requires within, e.g. "10 blocks" or "1h"`},
+		{name: "zero blocks", cond: RuleCondition{Type: ConditionRepeatedCall, Within: "0 blocks"},
+			wantErr: `invalid within "0 blocks"`},
+		{name: "non-numeric blocks", cond: RuleCondition{Type: ConditionRepeatedCall, Within: "ten blocks"},
+			wantErr: `invalid within "ten blocks"`},
+		{name: "negative duration", cond: RuleCondition{Type: ConditionVelocity, Within: "-1h"},
+			wantErr: `invalid within "-1h", expected a block count or a duration`},
+		{name: "unknown unit", cond: RuleCondition{Type: ConditionVelocity, Within: "2 days"},
+			wantErr: `invalid within "2 days", expected a block count or a duration`},
+		{name: "invalid repeated_call target", cond: RuleCondition{Type: ConditionRepeatedCall, Target: "withdraw", Within: "1h"},
+			wantErr: `repeated_call target must be a function selector or signature, got "withdraw"`},
+		{name: "invalid velocity target", cond: RuleCondition{Type: ConditionVelocity, Target: "value >", Within: "1h"},
+			wantErr: "invalid velocity target: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := testRule("windowed").parseWindowCondition(tt.cond)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("parseWindowCondition() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.span != tt.span || w.blocks != tt.blocks || w.ttl != tt.ttl || w.selector != tt.selector {
+				t.Errorf("parseWindowCondition() = span %d, blocks %v, ttl %v, selector %q, want span %d, blocks %v, ttl %v, selector %q",
+					w.span, w.blocks, w.ttl, w.selector, tt.span, tt.blocks, tt.ttl, tt.selector)
+			}
+			if !strings.HasPrefix(w.key, WindowKeyPrefix+"windowed:"+tt.cond.Type+":") {
+				t.Errorf("key = %s", w.key)
+			}
+		})
+	}
+}
+
+func TestWindowStoreMemory(t *testing.T) {
+	store := NewWindowStore(nil, zap.NewNop())
+	steps := []struct {
+		member string
+		score  int64
+		min    int64
+		want   int64
+	}{
+		{"0x1", 100, 90, 1},
+		{"0x2", 101, 91, 2},
+		{"0x2", 101, 91, 2}, // 同一交易重复记录不重复计数
+		{"0x3", 105, 95, 3},
+		{"0x4", 111, 101, 2}, // score <= 101 的成员移出窗口
+		{"0x5", 130, 120, 1},
+	}
+	for i, step := range steps {
+		if got := store.Add(context.Background(), "window:test", step.member, step.score, step.min, time.Hour); got != step.want {
+			t.Errorf("step %d: Add(%s) = %d, want %d", i, step.member, got, step.want)
+		}
+	}
+
+	// 过期的窗口重新计数
+	store.Add(context.Background(), "window:expiring", "0x1", 1, 0, -time.Second)
+	if got := store.Add(context.Background(), "window:expiring", "0x2", 2, 0, time.Hour); got != 1 {
+		t.Errorf("Add() on expired window = %d, want 1", got)
+	}
+}
+
+func TestWindowStoreSweep(t *testing.T) {
+	store := NewWindowStore(nil, zap.NewNop())
+	for i := 0; i < 10; i++ {
+		store.Add(context.Background(), fmt.Sprintf("window:expired:%d", i), "0x1", 1, 0, -time.Second)
+	}
+	store.Add(context.Background(), "window:live", "0x1", 1, 0, time.Hour)
+	if got := len(store.memory); got != 11 {
+		t.Fatalf("windows before sweep = %d, want 11", got)
+	}
+
+	// 每 windowSweepInterval 次写入清理一次过期的键
+	for i := store.writes; i < windowSweepInterval; i++ {
+		store.Add(context.Background(), "window:live", "0x1", 1, 0, time.Hour)
+	}
+	if _, ok := store.memory["window:live"]; !ok || len(store.memory) != 1 {
+		t.Errorf("windows after sweep = %d, want only window:live", len(store.memory))
+	}
+}
+
+// TestWindowStoreFallback Redis 不可用时退回内存窗口，计数在内存中继续
+func TestWindowStoreFallback(t *testing.T) {
+	redis := cache.NewRedisClient("127.0.0.1:1")
+	t.Cleanup(func() { redis.Close() })
+	store := NewWindowStore(redis, zap.NewNop())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	for i, want := range []int64{1, 2, 2} {
+		member := fmt.Sprintf("0x%d", min(i, 1))
+		if got := store.Add(ctx, "window:fallback", member, int64(i), -1, time.Hour); got != want {
+			t.Errorf("Add(%s) = %d, want %d", member, got, want)
+		}
+	}
+	if !store.fallback.Load() {
+		t.Error("fallback = false, want true")
+	}
+}
+
+// TestRepeatedCall repeated_call 只统计调用目标函数的交易，按发送方与合约分组
+func TestRepeatedCall(t *testing.T) {
+	rule := testRule("repeated-withdraw")
+	rule.Metadata.Enabled = true
+	rule.Triggers.Conditions = []RuleCondition{{
+		Type: ConditionRepeatedCall, Target: "withdraw(uint256)", Within: "10 blocks", Operator: ">=", Value: 3,
+	}}
+	programs := NewProgramCache()
+	if errs := programs.Reset([]*Rule{rule}); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	compiled, err := programs.Get(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewWindowStore(nil, zap.NewNop())
+	steps := []struct {
+		hash    string
+		from    string
+		to      string
+		input   string
+		block   int64
+		matched bool
+	}{
+		{"0x1", addrA, addrB, "0x2e1a7d4d01", 100, false},
+		{"0x2", addrA, addrB, "0xa9059cbb01", 101, false}, // 其他函数不计数
+		{"0x3", addrA, addrB, "0x2E1A7D4D02", 102, false},
+		{"0x3", addrA, addrB, "0x2e1a7d4d02", 102, false}, // 重复投递的交易不重复计数
+		{"0x4", addrA, addrC, "0x2e1a7d4d03", 103, false}, // 其他合约单独计数
+		{"0x5", addrD, addrB, "0x2e1a7d4d04", 104, false}, // 其他发送方单独计数
+		{"0x6", addrA, addrB, "0x2e1a7d4d05", 105, true},
+		{"0x7", addrA, addrB, "0xa9059cbb02", 106, false}, // 本交易不满足条件时不命中
+		{"0x8", addrA, addrB, "0x2e1a7d4d06", 112, false}, // 区块 100、101、102 移出窗口
+	}
+	for i, step := range steps {
+		ctx := NewEvaluationContext(&models.Transaction{
+			TxHash: step.hash, FromAddress: step.from, ToAddress: step.to, InputData: step.input, BlockNumber: step.block,
+		}, nil)
+		ctx.Windows = store
+		if err := programs.ObserveWindows(ctx, []*Rule{rule}); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		matched, err := compiled.Match(ctx)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if matched != step.matched {
+			t.Errorf("step %d (%s): Match() = %v, want %v", i, step.hash, matched, step.matched)
+		}
+	}
+}
+
+func TestVelocityTargetValidation(t *testing.T) {
+	rule := testRule("fast-drain")
+	rule.Extract.Transaction = []ExtractField{{Field: "value", As: "amount"}}
+	rule.Triggers.Conditions = []RuleCondition{
+		{Type: ConditionVelocity, Target: "value > 1 ether", Within: "1h", Value: 3},
+		{Type: ConditionVelocity, Target: "amount > 1 ether", Within: "1h", Value: 3},
+	}
+	var errs []string
+	for _, d := range rule.Validate() {
+		if d.Level == LevelError {
+			errs = append(errs, d.Path+": "+d.Message)
+		}
+	}
+	want := []string{`triggers.conditions[1].target: unknown variable "amount" (velocity target cannot use extracted variables)`}
+	if fmt.Sprint(errs) != fmt.Sprint(want) {
+		t.Errorf("Validate() = %q, want %q", errs, want)
+	}
+}
@@ -0,0 +1,11 @@
+-- 全局地址名单：allow 名单中的地址不参与规则检测，deny 名单中的地址一律升级为严重风险
+CREATE TABLE IF NOT EXISTS address_lists (
+    id BIGSERIAL PRIMARY KEY,
+    address VARCHAR(42) NOT NULL,
+    list_type VARCHAR(10) NOT NULL CHECK (list_type IN ('allow', 'deny')),
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (address, list_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_address_lists_type ON address_lists(list_type);
@@ -0,0 +1,2 @@
+-- 风险事件限流：记录该事件之前被抑制的同类命中次数
+ALTER TABLE risk_events ADD COLUMN IF NOT EXISTS suppressed_count INTEGER NOT NULL DEFAULT 0;
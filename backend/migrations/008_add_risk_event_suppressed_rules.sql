@@ -0,0 +1,2 @@
+-- 规则抑制关系：记录该事件抑制了哪些低优先级规则
+ALTER TABLE risk_events ADD COLUMN IF NOT EXISTS suppressed_rules TEXT[] NOT NULL DEFAULT '{}';
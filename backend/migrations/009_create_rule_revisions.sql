@@ -0,0 +1,15 @@
+-- 规则修订历史：每次加载到内容变化的规则文件（或回滚）时记录一个修订
+CREATE TABLE IF NOT EXISTS rule_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    rule_name VARCHAR(255) NOT NULL,
+    revision INTEGER NOT NULL,
+    version VARCHAR(50),
+    content_hash VARCHAR(64) NOT NULL,
+    content TEXT NOT NULL,
+    author VARCHAR(255),
+    source VARCHAR(20) NOT NULL DEFAULT 'file' CHECK (source IN ('file', 'rollback')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (rule_name, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_revisions_hash ON rule_revisions(content_hash);
@@ -0,0 +1,3 @@
+-- 产生风险事件的规则修订，用于复现调查
+ALTER TABLE risk_events ADD COLUMN IF NOT EXISTS rule_revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE risk_events ADD COLUMN IF NOT EXISTS rule_hash VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- 规则存储：detection_rules 保存完整的规则 YAML，作为规则的唯一来源；规则目录只在启动时导入
+ALTER TABLE detection_rules
+    ADD COLUMN IF NOT EXISTS version VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS content TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'file' CHECK (source IN ('file', 'api')),
+    ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- 通过 API 修改的规则也记录修订
+ALTER TABLE rule_revisions DROP CONSTRAINT IF EXISTS rule_revisions_source_check;
+ALTER TABLE rule_revisions ADD CONSTRAINT rule_revisions_source_check
+    CHECK (source IN ('file', 'api', 'rollback'));
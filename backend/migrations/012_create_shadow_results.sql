@@ -0,0 +1,15 @@
+-- 影子规则结果：影子规则（config.mode: shadow）的命中，以及被影子规则比较的线上规则的命中
+CREATE TABLE IF NOT EXISTS shadow_results (
+    id BIGSERIAL PRIMARY KEY,
+    rule_name VARCHAR(255) NOT NULL,
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('live', 'shadow')),
+    rule_revision INTEGER DEFAULT 0,
+    rule_hash VARCHAR(64) DEFAULT '',
+    tx_hash VARCHAR(66) NOT NULL,
+    contract_address VARCHAR(42),
+    score INTEGER NOT NULL,
+    detected_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_results_rule ON shadow_results(rule_name, mode, detected_at);
@@ -0,0 +1,2 @@
+-- 影子比较中被抑制（suppressed）或在 stop_on_match 之后才评估（stopped）的命中，这些命中未执行动作
+ALTER TABLE shadow_results ADD COLUMN IF NOT EXISTS silenced VARCHAR(20) NOT NULL DEFAULT '';